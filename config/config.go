@@ -25,10 +25,14 @@ type AppConfig struct {
 	GoogleClientID     string
 	GoogleClientSecret string
 	TelegramBotToken   string
+	// Generic OAuth2 / OIDC providers (GitHub/Google above are folded in as built-ins)
+	OAuthProviders     []OAuthProvider
 	SigninRewardPoints int
 	RateLimitPerMinute int
 	AllowedOrigins     []string
 	OAuthRedirectBase  string
+	// Reverse proxies (IPs or CIDRs) whose forwarded client IP headers are honoured
+	TrustedProxies []string
 	// Country access control
 	AllowedCountry []string
 	DenyCountry    []string
@@ -78,10 +82,79 @@ type AppConfig struct {
 	RegisterAttemptCooldownSec    int
 	RegisterFailedMaxPerIPPerHour int
 	RegisterTempBanMinutes        int
+	// Login brute-force / credential-stuffing protection
+	LoginFailureWindowMinutes int
+	LoginCaptchaAfterFailures int
+	LoginLockoutThreshold     int
+	LoginIPFailureThreshold   int
+	LoginLockoutMinutes       int
+	LoginDelayStepMs          int
+	LoginMaxDelayMs           int
+	// Self-service account deletion
+	AccountDeletionGraceDays int
+	AccountGhostUsername     string
+	// Moderation
+	ModerationPublicLog bool // expose the anonymized moderation log at /api/v1/moderation/log
+	ReportHideThreshold int  // distinct trusted reporters needed to auto-hide content; negative disables
+	// Pre-moderation: content from members below these thresholds, or in these categories, waits for approval
+	PremodMinAccountDays int
+	PremodMinPoints      int
+	PremodCategories     []string
+	TrashRetentionDays   int // days deleted posts and comments stay restorable; negative keeps them forever
+	// Spam scoring: percentages of the combined classifier score; above 100 disables the route
+	SpamReviewScore  int
+	SpamBlockScore   int
+	SpamBayesEnabled bool // score with (and train) the local Bayesian filter
+	// Sign-in bonuses: extra points when the streak reaches a key (days), and for signing in every day of a month
+	SigninStreakBonuses  map[int]int
+	SigninFullMonthBonus int
+	// Make-up cards (补签卡): price in points (negative disables buying) and how far back a card can fill
+	SigninMakeupCardPrice    int
+	SigninMakeupLookbackDays int
+	// IANA zone in which sign-in days and daily point caps start; users can override it in their profile
+	SigninTimezone string
+	// Points rules keyed by event (post_created, reply_received, content_removed)
+	PointsRules map[string]PointsRule
+	// Points-gated hidden content: price of [hide=points] blocks and the highest price a block may ask
+	HiddenContentPrice    int
+	HiddenContentMaxPrice int
+	// Tips (打赏): per-tip bounds and the most a user may tip per day (negative = unlimited)
+	TipMin      int
+	TipMax      int
+	TipDailyCap int
 	// Admins
 	AdminUsernames []string
 }
 
+// PointsRule awards (Points > 0) or deducts (Points < 0) points for one forum event. DailyCap limits
+// the absolute points a user can get from the rule per day; 0 means no cap.
+type PointsRule struct {
+	Points   int
+	DailyCap int
+}
+
+// OAuthProvider describes a third-party login provider declared in config.json (oauth.Providers).
+// Endpoints come either from OIDC discovery (Issuer) or are given explicitly; claims map the
+// userinfo JSON onto local user fields and accept dotted paths such as "data.email".
+type OAuthProvider struct {
+	Name         string
+	DisplayName  string
+	ClientID     string
+	ClientSecret string
+	Issuer       string
+	AuthURL      string
+	TokenURL     string
+	UserInfoURL  string
+	// EmailsURL optionally lists emails when userinfo omits them (GitHub/Gitea style [{email,primary,verified}])
+	EmailsURL     string
+	Scopes        []string
+	IDClaim       string
+	UsernameClaim string
+	NameClaim     string
+	EmailClaim    string
+	AvatarClaim   string
+}
+
 var cfg AppConfig
 var loaded bool
 
@@ -103,6 +176,9 @@ func Load() AppConfig {
 	// 3) Override from environment variables when set
 	applyEnvOverrides(&cfg)
 
+	// 4) Fold legacy GitHub/Google credentials into the generic provider list
+	applyBuiltinOAuthProviders(&cfg)
+
 	if cfg.JWTSecret == "" {
 		log.Fatal("JWT_SECRET must be set in environment variables")
 	}
@@ -199,6 +275,9 @@ func loadJSONConfig(path string, out *AppConfig) error {
 		if list := getStringSlice(app, "AllowedOrigins"); len(list) > 0 {
 			out.AllowedOrigins = list
 		}
+		if list := getStringSlice(app, "TrustedProxies"); len(list) > 0 {
+			out.TrustedProxies = list
+		}
 		if list := getStringSlice(app, "AllowedCountry"); len(list) > 0 {
 			out.AllowedCountry = list
 		}
@@ -251,6 +330,37 @@ func loadJSONConfig(path string, out *AppConfig) error {
 		out.GoogleClientID = getString(oa, "GoogleClientID")
 		out.GoogleClientSecret = getString(oa, "GoogleClientSecret")
 		out.TelegramBotToken = getString(oa, "TelegramBotToken")
+		if arr, ok := oa["Providers"].([]any); ok {
+			for _, it := range arr {
+				pm, ok := it.(map[string]any)
+				if !ok {
+					continue
+				}
+				p := OAuthProvider{
+					Name:         strings.ToLower(strings.TrimSpace(getString(pm, "Name"))),
+					DisplayName:  getString(pm, "DisplayName"),
+					ClientID:     getString(pm, "ClientID"),
+					ClientSecret: getString(pm, "ClientSecret"),
+					Issuer:       strings.TrimRight(getString(pm, "Issuer"), "/"),
+					AuthURL:      getString(pm, "AuthURL"),
+					TokenURL:     getString(pm, "TokenURL"),
+					UserInfoURL:  getString(pm, "UserInfoURL"),
+					EmailsURL:    getString(pm, "EmailsURL"),
+					Scopes:       getStringSlice(pm, "Scopes"),
+				}
+				if claims, ok := pm["Claims"].(map[string]any); ok {
+					p.IDClaim = getString(claims, "ID")
+					p.UsernameClaim = getString(claims, "Username")
+					p.NameClaim = getString(claims, "Name")
+					p.EmailClaim = getString(claims, "Email")
+					p.AvatarClaim = getString(claims, "Avatar")
+				}
+				if p.Name == "" {
+					continue
+				}
+				out.OAuthProviders = append(out.OAuthProviders, p)
+			}
+		}
 	}
 
 	if ft, ok := raw["footer"].(map[string]any); ok {
@@ -359,6 +469,101 @@ func loadJSONConfig(path string, out *AppConfig) error {
 		}
 	}
 
+	// login protection section
+	if lg, ok := raw["login"].(map[string]any); ok {
+		if v := getInt(lg, "FailureWindowMinutes"); v != 0 {
+			out.LoginFailureWindowMinutes = v
+		}
+		if v := getInt(lg, "CaptchaAfterFailures"); v != 0 {
+			out.LoginCaptchaAfterFailures = v
+		}
+		if v := getInt(lg, "LockoutThreshold"); v != 0 {
+			out.LoginLockoutThreshold = v
+		}
+		if v := getInt(lg, "IPFailureThreshold"); v != 0 {
+			out.LoginIPFailureThreshold = v
+		}
+		if v := getInt(lg, "LockoutMinutes"); v != 0 {
+			out.LoginLockoutMinutes = v
+		}
+		if v := getInt(lg, "DelayStepMs"); v != 0 {
+			out.LoginDelayStepMs = v
+		}
+		if v := getInt(lg, "MaxDelayMs"); v != 0 {
+			out.LoginMaxDelayMs = v
+		}
+	}
+
+	// account section
+	if ac, ok := raw["account"].(map[string]any); ok {
+		if v := getInt(ac, "DeletionGraceDays"); v != 0 {
+			out.AccountDeletionGraceDays = v
+		}
+		if v := getString(ac, "GhostUsername"); v != "" {
+			out.AccountGhostUsername = v
+		}
+	}
+
+	// moderation section
+	if md, ok := raw["moderation"].(map[string]any); ok {
+		out.ModerationPublicLog = getBool(md, "PublicLog")
+		if v := getInt(md, "ReportHideThreshold"); v != 0 {
+			out.ReportHideThreshold = v
+		}
+		out.PremodMinAccountDays = getInt(md, "PremodMinAccountDays")
+		out.PremodMinPoints = getInt(md, "PremodMinPoints")
+		out.PremodCategories = getStringSlice(md, "PremodCategories")
+		if v := getInt(md, "TrashRetentionDays"); v != 0 {
+			out.TrashRetentionDays = v
+		}
+	}
+
+	// spam section
+	if sp, ok := raw["spam"].(map[string]any); ok {
+		if v := getInt(sp, "ReviewScore"); v != 0 {
+			out.SpamReviewScore = v
+		}
+		if v := getInt(sp, "BlockScore"); v != 0 {
+			out.SpamBlockScore = v
+		}
+		out.SpamBayesEnabled = getBool(sp, "BayesEnabled")
+	}
+
+	// signin section
+	if si, ok := raw["signin"].(map[string]any); ok {
+		if bonuses, ok := si["StreakBonuses"].(map[string]any); ok {
+			out.SigninStreakBonuses = map[int]int{}
+			for k := range bonuses {
+				if days, err := strconv.Atoi(k); err == nil && days > 0 {
+					out.SigninStreakBonuses[days] = getInt(bonuses, k)
+				}
+			}
+		}
+		out.SigninFullMonthBonus = getInt(si, "FullMonthBonus")
+		out.SigninMakeupCardPrice = getInt(si, "MakeupCardPrice")
+		out.SigninMakeupLookbackDays = getInt(si, "MakeupLookbackDays")
+		out.SigninTimezone = getString(si, "Timezone")
+	}
+
+	// points section
+	if pt, ok := raw["points"].(map[string]any); ok {
+		if rules, ok := pt["Rules"].(map[string]any); ok {
+			out.PointsRules = map[string]PointsRule{}
+			for event, v := range rules {
+				rm, ok := v.(map[string]any)
+				if !ok {
+					continue
+				}
+				out.PointsRules[strings.ToLower(event)] = PointsRule{Points: getInt(rm, "Points"), DailyCap: getInt(rm, "DailyCap")}
+			}
+		}
+		out.HiddenContentPrice = getInt(pt, "HiddenContentPrice")
+		out.HiddenContentMaxPrice = getInt(pt, "HiddenContentMaxPrice")
+		out.TipMin = getInt(pt, "TipMin")
+		out.TipMax = getInt(pt, "TipMax")
+		out.TipDailyCap = getInt(pt, "TipDailyCap")
+	}
+
 	// Also support reading flat keys directly for backward compatibility
 	if v, ok := raw["AppPort"]; ok && out.AppPort == "" {
 		out.AppPort = v.(string)
@@ -607,6 +812,10 @@ func applyDefaults(c *AppConfig) {
 	if len(c.AllowedOrigins) == 0 {
 		c.AllowedOrigins = []string{"*"}
 	}
+	if len(c.TrustedProxies) == 0 {
+		// A proxy on the same host or private network
+		c.TrustedProxies = []string{"127.0.0.0/8", "::1/128", "10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7"}
+	}
 	if c.OAuthRedirectBase == "" {
 		c.OAuthRedirectBase = "http://localhost:8080"
 	}
@@ -656,6 +865,80 @@ func applyDefaults(c *AppConfig) {
 	if c.RegisterTempBanMinutes == 0 {
 		c.RegisterTempBanMinutes = 60
 	}
+	// Login protection defaults
+	if c.LoginFailureWindowMinutes == 0 {
+		c.LoginFailureWindowMinutes = 15
+	}
+	if c.LoginCaptchaAfterFailures == 0 {
+		c.LoginCaptchaAfterFailures = 3
+	}
+	if c.LoginLockoutThreshold == 0 {
+		c.LoginLockoutThreshold = 10
+	}
+	if c.LoginIPFailureThreshold == 0 {
+		c.LoginIPFailureThreshold = 50
+	}
+	if c.LoginLockoutMinutes == 0 {
+		c.LoginLockoutMinutes = 15
+	}
+	if c.LoginDelayStepMs == 0 {
+		c.LoginDelayStepMs = 300
+	}
+	if c.LoginMaxDelayMs == 0 {
+		c.LoginMaxDelayMs = 3000
+	}
+	if c.AccountDeletionGraceDays == 0 {
+		c.AccountDeletionGraceDays = 7
+	}
+	if c.AccountGhostUsername == "" {
+		c.AccountGhostUsername = "已注销用户"
+	}
+	if c.ReportHideThreshold == 0 {
+		c.ReportHideThreshold = 3
+	}
+	if c.TrashRetentionDays == 0 {
+		c.TrashRetentionDays = 30
+	}
+	if c.SpamReviewScore == 0 {
+		c.SpamReviewScore = 60
+	}
+	if c.SpamBlockScore == 0 {
+		c.SpamBlockScore = 90
+	}
+	if c.SigninStreakBonuses == nil {
+		c.SigninStreakBonuses = map[int]int{7: 20, 30: 100, 100: 500}
+	}
+	if c.SigninMakeupCardPrice == 0 {
+		c.SigninMakeupCardPrice = 50
+	}
+	if c.SigninMakeupLookbackDays <= 0 {
+		c.SigninMakeupLookbackDays = 7
+	}
+	if c.SigninTimezone == "" {
+		c.SigninTimezone = "Asia/Shanghai"
+	}
+	if c.PointsRules == nil {
+		c.PointsRules = map[string]PointsRule{
+			"post_created":    {Points: 2, DailyCap: 10},
+			"reply_received":  {Points: 1, DailyCap: 20},
+			"content_removed": {Points: -5},
+		}
+	}
+	if c.HiddenContentPrice <= 0 {
+		c.HiddenContentPrice = 10
+	}
+	if c.HiddenContentMaxPrice <= 0 {
+		c.HiddenContentMaxPrice = 1000
+	}
+	if c.TipMin <= 0 {
+		c.TipMin = 1
+	}
+	if c.TipMax <= 0 {
+		c.TipMax = 500
+	}
+	if c.TipDailyCap == 0 {
+		c.TipDailyCap = 1000
+	}
 	if c.NoticeTitle == "" {
 		c.NoticeTitle = "公告"
 	}
@@ -715,15 +998,50 @@ func applyEnvOverrides(c *AppConfig) {
 	if v := getEnv("TELEGRAM_BOT_TOKEN", ""); v != "" {
 		c.TelegramBotToken = v
 	}
+	// Per-provider credentials: OAUTH_<NAME>_CLIENT_ID / OAUTH_<NAME>_CLIENT_SECRET
+	for i := range c.OAuthProviders {
+		prefix := "OAUTH_" + strings.ToUpper(strings.ReplaceAll(c.OAuthProviders[i].Name, "-", "_"))
+		if v := getEnv(prefix+"_CLIENT_ID", ""); v != "" {
+			c.OAuthProviders[i].ClientID = v
+		}
+		if v := getEnv(prefix+"_CLIENT_SECRET", ""); v != "" {
+			c.OAuthProviders[i].ClientSecret = v
+		}
+	}
 	if v := getEnv("SIGNIN_REWARD", ""); v != "" {
 		c.SigninRewardPoints = mustParseInt(v)
 	}
+	// SIGNIN_STREAK_BONUSES=7:20,30:100,100:500
+	if v := getEnv("SIGNIN_STREAK_BONUSES", ""); v != "" {
+		c.SigninStreakBonuses = map[int]int{}
+		for _, pair := range strings.Split(v, ",") {
+			days, pts, ok := strings.Cut(strings.TrimSpace(pair), ":")
+			if ok {
+				c.SigninStreakBonuses[mustParseInt(days)] = mustParseInt(pts)
+			}
+		}
+	}
+	if v := getEnv("SIGNIN_FULL_MONTH_BONUS", ""); v != "" {
+		c.SigninFullMonthBonus = mustParseInt(v)
+	}
+	if v := getEnv("SIGNIN_MAKEUP_CARD_PRICE", ""); v != "" {
+		c.SigninMakeupCardPrice = mustParseInt(v)
+	}
+	if v := getEnv("SIGNIN_MAKEUP_LOOKBACK_DAYS", ""); v != "" {
+		c.SigninMakeupLookbackDays = mustParseInt(v)
+	}
+	if v := getEnv("SIGNIN_TIMEZONE", ""); v != "" {
+		c.SigninTimezone = v
+	}
 	if v := getEnv("RATE_LIMIT_PER_MINUTE", ""); v != "" {
 		c.RateLimitPerMinute = mustParseInt(v)
 	}
 	if v := getEnv("CORS_ALLOWED_ORIGINS", ""); v != "" {
 		c.AllowedOrigins = readListEnv("CORS_ALLOWED_ORIGINS", c.AllowedOrigins)
 	}
+	if v := getEnv("TRUSTED_PROXIES", ""); v != "" {
+		c.TrustedProxies = readListEnv("TRUSTED_PROXIES", c.TrustedProxies)
+	}
 	if v := getEnv("ALLOWED_COUNTRY", ""); v != "" {
 		c.AllowedCountry = readListEnv("ALLOWED_COUNTRY", c.AllowedCountry)
 	}
@@ -840,6 +1158,87 @@ func applyEnvOverrides(c *AppConfig) {
 	if v := getEnv("REGISTER_TEMP_BAN_MINUTES", ""); v != "" {
 		c.RegisterTempBanMinutes = mustParseInt(v)
 	}
+	// Login protection env overrides
+	if v := getEnv("LOGIN_FAILURE_WINDOW_MINUTES", ""); v != "" {
+		c.LoginFailureWindowMinutes = mustParseInt(v)
+	}
+	if v := getEnv("LOGIN_CAPTCHA_AFTER_FAILURES", ""); v != "" {
+		c.LoginCaptchaAfterFailures = mustParseInt(v)
+	}
+	if v := getEnv("LOGIN_LOCKOUT_THRESHOLD", ""); v != "" {
+		c.LoginLockoutThreshold = mustParseInt(v)
+	}
+	if v := getEnv("LOGIN_IP_FAILURE_THRESHOLD", ""); v != "" {
+		c.LoginIPFailureThreshold = mustParseInt(v)
+	}
+	if v := getEnv("LOGIN_LOCKOUT_MINUTES", ""); v != "" {
+		c.LoginLockoutMinutes = mustParseInt(v)
+	}
+	if v := getEnv("LOGIN_DELAY_STEP_MS", ""); v != "" {
+		c.LoginDelayStepMs = mustParseInt(v)
+	}
+	if v := getEnv("LOGIN_MAX_DELAY_MS", ""); v != "" {
+		c.LoginMaxDelayMs = mustParseInt(v)
+	}
+	if v := getEnv("ACCOUNT_DELETION_GRACE_DAYS", ""); v != "" {
+		c.AccountDeletionGraceDays = mustParseInt(v)
+	}
+	if v := getEnv("MODERATION_PUBLIC_LOG", ""); v != "" {
+		c.ModerationPublicLog = v == "true"
+	}
+	if v := getEnv("MODERATION_REPORT_HIDE_THRESHOLD", ""); v != "" {
+		c.ReportHideThreshold = mustParseInt(v)
+	}
+	if v := getEnv("MODERATION_PREMOD_MIN_ACCOUNT_DAYS", ""); v != "" {
+		c.PremodMinAccountDays = mustParseInt(v)
+	}
+	if v := getEnv("MODERATION_PREMOD_MIN_POINTS", ""); v != "" {
+		c.PremodMinPoints = mustParseInt(v)
+	}
+	c.PremodCategories = readListEnv("MODERATION_PREMOD_CATEGORIES", c.PremodCategories)
+	if v := getEnv("MODERATION_TRASH_RETENTION_DAYS", ""); v != "" {
+		c.TrashRetentionDays = mustParseInt(v)
+	}
+	if v := getEnv("SPAM_REVIEW_SCORE", ""); v != "" {
+		c.SpamReviewScore = mustParseInt(v)
+	}
+	if v := getEnv("SPAM_BLOCK_SCORE", ""); v != "" {
+		c.SpamBlockScore = mustParseInt(v)
+	}
+	if v := getEnv("SPAM_BAYES_ENABLED", ""); v != "" {
+		c.SpamBayesEnabled = v == "true"
+	}
+	// POINTS_RULE_<EVENT>=points[,daily_cap], e.g. POINTS_RULE_POST_CREATED=2,10
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, "POINTS_RULE_") {
+			continue
+		}
+		name, val, _ := strings.Cut(strings.TrimPrefix(kv, "POINTS_RULE_"), "=")
+		pts, capStr, _ := strings.Cut(val, ",")
+		rule := PointsRule{Points: mustParseInt(strings.TrimSpace(pts))}
+		if capStr != "" {
+			rule.DailyCap = mustParseInt(strings.TrimSpace(capStr))
+		}
+		if c.PointsRules == nil {
+			c.PointsRules = map[string]PointsRule{}
+		}
+		c.PointsRules[strings.ToLower(name)] = rule
+	}
+	if v := getEnv("POINTS_HIDDEN_CONTENT_PRICE", ""); v != "" {
+		c.HiddenContentPrice = mustParseInt(v)
+	}
+	if v := getEnv("POINTS_HIDDEN_CONTENT_MAX_PRICE", ""); v != "" {
+		c.HiddenContentMaxPrice = mustParseInt(v)
+	}
+	if v := getEnv("POINTS_TIP_MIN", ""); v != "" {
+		c.TipMin = mustParseInt(v)
+	}
+	if v := getEnv("POINTS_TIP_MAX", ""); v != "" {
+		c.TipMax = mustParseInt(v)
+	}
+	if v := getEnv("POINTS_TIP_DAILY_CAP", ""); v != "" {
+		c.TipDailyCap = mustParseInt(v)
+	}
 	if v := getEnv("NOTICE_TITLE", ""); v != "" {
 		c.NoticeTitle = v
 	}
@@ -849,6 +1248,61 @@ func applyEnvOverrides(c *AppConfig) {
 	// (removed) uploads self-destruct env overrides
 }
 
+// applyBuiltinOAuthProviders registers GitHub and Google from the legacy credential fields
+// unless a provider with the same name is already declared in oauth.Providers.
+func applyBuiltinOAuthProviders(c *AppConfig) {
+	declared := map[string]bool{}
+	for _, p := range c.OAuthProviders {
+		declared[p.Name] = true
+	}
+	if !declared["github"] && c.GitHubClientID != "" && c.GitHubClientSecret != "" {
+		c.OAuthProviders = append(c.OAuthProviders, OAuthProvider{
+			Name:          "github",
+			DisplayName:   "GitHub",
+			ClientID:      c.GitHubClientID,
+			ClientSecret:  c.GitHubClientSecret,
+			AuthURL:       "https://github.com/login/oauth/authorize",
+			TokenURL:      "https://github.com/login/oauth/access_token",
+			UserInfoURL:   "https://api.github.com/user",
+			EmailsURL:     "https://api.github.com/user/emails",
+			Scopes:        []string{"read:user", "user:email"},
+			IDClaim:       "id",
+			UsernameClaim: "login",
+			NameClaim:     "name",
+			EmailClaim:    "email",
+			AvatarClaim:   "avatar_url",
+		})
+	}
+	if !declared["google"] && c.GoogleClientID != "" && c.GoogleClientSecret != "" {
+		c.OAuthProviders = append(c.OAuthProviders, OAuthProvider{
+			Name:          "google",
+			DisplayName:   "Google",
+			ClientID:      c.GoogleClientID,
+			ClientSecret:  c.GoogleClientSecret,
+			AuthURL:       "https://accounts.google.com/o/oauth2/auth",
+			TokenURL:      "https://oauth2.googleapis.com/token",
+			UserInfoURL:   "https://www.googleapis.com/oauth2/v2/userinfo",
+			Scopes:        []string{"openid", "profile", "email"},
+			IDClaim:       "id",
+			UsernameClaim: "email",
+			NameClaim:     "name",
+			EmailClaim:    "email",
+			AvatarClaim:   "picture",
+		})
+	}
+}
+
+// FindOAuthProvider returns the configured provider by (case-insensitive) name.
+func FindOAuthProvider(name string) (OAuthProvider, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, p := range Get().OAuthProviders {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return OAuthProvider{}, false
+}
+
 func mustParseInt(val string) int {
 	i, err := strconv.Atoi(val)
 	if err != nil {
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -97,11 +98,18 @@ func InitDatabase(modelDefs ...interface{}) *gorm.DB {
 				// Safe, additive migrations: add missing columns only
 				switch m := model.(type) {
 				case *models.User:
-					if !db.Migrator().HasColumn(&models.User{}, "Signature") {
-						if err := db.Migrator().AddColumn(&models.User{}, "Signature"); err != nil {
-							log.Printf("failed to add users.signature column: %v", err)
-						}
-					}
+					addMissingColumns(db, m, "users", "Signature", "DeletionRequestedAt", "DeletionMode", "MakeupCards", "Timezone", "TimezoneChangedAt", "Ghost")
+					flagGhostAccount(db)
+				case *models.Post:
+					addMissingColumns(db, m, "posts", "Locked", "Pinned", "Hidden", "Status", "SpamScore", "MovedTo", "DeletedAt", "DeletedBy", "DeletedReason")
+				case *models.Comment:
+					addMissingColumns(db, m, "comments", "Hidden", "Status", "SpamScore", "DeletedAt", "DeletedBy", "DeletedReason")
+				case *models.SignIn:
+					addMissingColumns(db, m, "sign_ins", "Makeup", "SigninDay")
+					backfillSigninDays(db)
+				case *models.Report:
+					addMissingColumns(db, m, "reports", "Active")
+					migrateReportActiveIndex(db)
 				default:
 					_ = m
 				}
@@ -109,9 +117,219 @@ func InitDatabase(modelDefs ...interface{}) *gorm.DB {
 		}
 	}
 
+	migrateLegacyIdentities(db)
+	seedRoles(db)
+	seedBadges(db)
+	bootstrapAdmins(db)
+	backfillPointsLedger(db)
+
 	return db
 }
 
+var roleDescriptions = map[string]string{
+	models.RoleAdmin:             "管理员：拥有全部权限",
+	models.RoleModerator:         "版主：管理全部分类的帖子与评论",
+	models.RoleCategoryModerator: "分区版主：仅管理被指派分类的帖子与评论",
+	models.RoleTrusted:           "可信用户",
+}
+
+// seedRoles creates the built-in roles and permissions and adds any missing role-permission links.
+// Existing rows are left untouched so operators can extend roles without them being reset.
+func seedRoles(db *gorm.DB) {
+	if !db.Migrator().HasTable(&models.Role{}) || !db.Migrator().HasTable(&models.Permission{}) {
+		return
+	}
+	for roleName, perms := range models.DefaultRolePermissions {
+		role := models.Role{Name: roleName}
+		if err := db.Where(models.Role{Name: roleName}).Attrs(models.Role{Description: roleDescriptions[roleName]}).FirstOrCreate(&role).Error; err != nil {
+			log.Printf("failed to seed role %s: %v", roleName, err)
+			continue
+		}
+		for _, permName := range perms {
+			perm := models.Permission{Name: permName}
+			if err := db.Where(models.Permission{Name: permName}).FirstOrCreate(&perm).Error; err != nil {
+				log.Printf("failed to seed permission %s: %v", permName, err)
+				continue
+			}
+			if err := db.Exec("INSERT IGNORE INTO role_permissions (role_id, permission_id) VALUES (?, ?)", role.ID, perm.ID).Error; err != nil {
+				log.Printf("failed to link %s -> %s: %v", roleName, permName, err)
+			}
+		}
+	}
+}
+
+// defaultBadges are created on first start; operators can edit or disable them afterwards.
+var defaultBadges = []models.Badge{
+	{Slug: "first-post", Name: "初来乍到", Description: "发布第一篇帖子", Icon: "📝", Rule: models.BadgeRulePostCount, Threshold: 1},
+	{Slug: "streak-100", Name: "百日坚持", Description: "连续签到 100 天", Icon: "🔥", Rule: models.BadgeRuleSigninStreak, Threshold: 100},
+	{Slug: "member-1y", Name: "一周年", Description: "注册满 1 年", Icon: "🎂", Rule: models.BadgeRuleMembershipYears, Threshold: 1},
+	{Slug: "member-3y", Name: "三周年", Description: "注册满 3 年", Icon: "🏅", Rule: models.BadgeRuleMembershipYears, Threshold: 3},
+}
+
+// seedBadges creates the default badges that do not exist yet, matched by slug.
+func seedBadges(db *gorm.DB) {
+	if !db.Migrator().HasTable(&models.Badge{}) {
+		return
+	}
+	for _, badge := range defaultBadges {
+		badge.Enabled = true
+		if err := db.Where(models.Badge{Slug: badge.Slug}).Attrs(badge).FirstOrCreate(&models.Badge{}).Error; err != nil {
+			log.Printf("failed to seed badge %s: %v", badge.Slug, err)
+		}
+	}
+}
+
+// bootstrapAdmins grants the admin role to AdminUsernames, but only while no one holds it yet.
+// After that, admins are managed through the role endpoints and the config list is ignored,
+// so renaming users or editing config.json no longer changes privileges.
+func bootstrapAdmins(db *gorm.DB) {
+	if !db.Migrator().HasTable(&models.UserRole{}) {
+		return
+	}
+	var admin models.Role
+	if err := db.Where("name = ?", models.RoleAdmin).First(&admin).Error; err != nil {
+		return
+	}
+	var count int64
+	if err := db.Model(&models.UserRole{}).Where("role_id = ?", admin.ID).Count(&count).Error; err != nil || count > 0 {
+		return
+	}
+	for _, name := range Get().AdminUsernames {
+		var user models.User
+		if err := db.Where("username = ?", strings.TrimSpace(name)).First(&user).Error; err != nil {
+			continue
+		}
+		if err := db.Create(&models.UserRole{UserID: user.ID, RoleID: admin.ID}).Error; err != nil {
+			log.Printf("failed to bootstrap admin %s: %v", user.Username, err)
+			continue
+		}
+		log.Printf("granted admin role to %s (bootstrap from AdminUsernames)", user.Username)
+	}
+}
+
+// backfillPointsLedger writes an opening-balance entry pair for every user whose points predate the
+// ledger, so the sum of a user's entries always equals users.points. Users with entries are skipped.
+func backfillPointsLedger(db *gorm.DB) {
+	if !db.Migrator().HasTable(&models.PointsTransaction{}) {
+		return
+	}
+	var users []models.User
+	err := db.Select("id", "points").
+		Where("points <> 0 AND NOT EXISTS (SELECT 1 FROM points_transactions t WHERE t.user_id = users.id)").
+		Find(&users).Error
+	if err != nil {
+		log.Printf("failed to scan points for ledger backfill: %v", err)
+		return
+	}
+	for _, u := range users {
+		txnID := "opening-" + strconv.FormatUint(uint64(u.ID), 10)
+		entries := []models.PointsTransaction{
+			{TxnID: txnID, UserID: u.ID, CounterpartyID: models.SystemAccountID, Amount: u.Points, BalanceAfter: u.Points, Reason: models.PointsReasonOpening},
+			{TxnID: txnID, UserID: models.SystemAccountID, CounterpartyID: u.ID, Amount: -u.Points, Reason: models.PointsReasonOpening},
+		}
+		if err := db.Create(&entries).Error; err != nil {
+			log.Printf("failed to write opening balance for user %d: %v", u.ID, err)
+		}
+	}
+	if len(users) > 0 {
+		log.Printf("points ledger: opening balances written for %d users", len(users))
+	}
+}
+
+// backfillSigninDays fills signin_day for sign-ins recorded before the column existed, using the
+// server-local date of signin_date as earlier versions did, then adds the (user_id, signin_day) index.
+func backfillSigninDays(db *gorm.DB) {
+	if !db.Migrator().HasColumn(&models.SignIn{}, "SigninDay") {
+		return
+	}
+	res := db.Exec("UPDATE sign_ins SET signin_day = DATE(signin_date) WHERE signin_day IS NULL")
+	if res.Error != nil {
+		log.Printf("failed to backfill sign_ins.signin_day: %v", res.Error)
+		return
+	}
+	if res.RowsAffected > 0 {
+		log.Printf("sign-ins: signin_day backfilled for %d rows", res.RowsAffected)
+	}
+	if !db.Migrator().HasIndex(&models.SignIn{}, "idx_signin_user_day") {
+		if err := db.Migrator().CreateIndex(&models.SignIn{}, "idx_signin_user_day"); err != nil {
+			log.Printf("failed to create idx_signin_user_day: %v", err)
+		}
+	}
+}
+
+// migrateReportActiveIndex replaces the unique (reporter, target) index of earlier versions, which kept
+// members from reporting a target again once their report was closed, with idx_report_active.
+func migrateReportActiveIndex(db *gorm.DB) {
+	if !db.Migrator().HasColumn(&models.Report{}, "Active") {
+		return
+	}
+	if err := db.Model(&models.Report{}).Where("active IS NULL AND status IN ?", []string{models.ReportStatusOpen, models.ReportStatusClaimed}).
+		Update("active", true).Error; err != nil {
+		log.Printf("failed to backfill reports.active: %v", err)
+		return
+	}
+	if db.Migrator().HasIndex(&models.Report{}, "idx_report_reporter_target") {
+		if err := db.Migrator().DropIndex(&models.Report{}, "idx_report_reporter_target"); err != nil {
+			log.Printf("failed to drop idx_report_reporter_target: %v", err)
+		}
+	}
+	if !db.Migrator().HasIndex(&models.Report{}, "idx_report_active") {
+		if err := db.Migrator().CreateIndex(&models.Report{}, "idx_report_active"); err != nil {
+			log.Printf("failed to create idx_report_active: %v", err)
+		}
+	}
+}
+
+// flagGhostAccount marks the ghost account created before users.ghost existed. Earlier versions
+// found it by name alone; it is told apart from a user who registered that name by its "system"
+// register IP.
+func flagGhostAccount(db *gorm.DB) {
+	if !db.Migrator().HasColumn(&models.User{}, "Ghost") {
+		return
+	}
+	var n int64
+	if err := db.Model(&models.User{}).Where("ghost = ?", true).Count(&n).Error; err != nil || n > 0 {
+		return
+	}
+	res := db.Model(&models.User{}).Where("username = ? AND register_ip = ?", Get().AccountGhostUsername, "system").Update("ghost", true)
+	if res.Error != nil {
+		log.Printf("failed to flag the ghost account: %v", res.Error)
+	}
+}
+
+// addMissingColumns adds the given struct fields as columns when the table lacks them.
+func addMissingColumns(db *gorm.DB, model interface{}, table string, fields ...string) {
+	for _, field := range fields {
+		if db.Migrator().HasColumn(model, field) {
+			continue
+		}
+		if err := db.Migrator().AddColumn(model, field); err != nil {
+			log.Printf("failed to add %s.%s column: %v", table, field, err)
+		}
+	}
+}
+
+// migrateLegacyIdentities copies users.provider/provider_id into user_identities once.
+// It is idempotent: rows already present in user_identities are skipped.
+func migrateLegacyIdentities(db *gorm.DB) {
+	if !db.Migrator().HasTable(&models.UserIdentity{}) {
+		return
+	}
+	res := db.Exec(`INSERT INTO user_identities (user_id, provider, provider_id, email, created_at, updated_at)
+SELECT u.id, u.provider, u.provider_id, COALESCE(u.email, ''), u.created_at, NOW()
+FROM users u
+WHERE u.provider IS NOT NULL AND u.provider <> '' AND u.provider_id IS NOT NULL AND u.provider_id <> ''
+  AND u.deleted_at IS NULL
+  AND NOT EXISTS (SELECT 1 FROM user_identities i WHERE i.provider = u.provider AND i.provider_id = u.provider_id)`)
+	if res.Error != nil {
+		log.Printf("legacy identity migration failed: %v", res.Error)
+		return
+	}
+	if res.RowsAffected > 0 {
+		log.Printf("migrated %d legacy provider identities into user_identities", res.RowsAffected)
+	}
+}
+
 // toGormLogLevel maps application LogLevel to GORM's logger level.
 func toGormLogLevel(level string) logger.LogLevel {
 	switch level {
@@ -0,0 +1,398 @@
+package controllers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/cppla/aibbs/config"
+	"github.com/cppla/aibbs/models"
+	"github.com/cppla/aibbs/utils"
+)
+
+const (
+	deletionModeReassign = "reassign" // keep posts/comments under the ghost account
+	deletionModeDelete   = "delete"   // remove posts/comments together with the account
+)
+
+// accountExport is the data bundle written into the export archive.
+type accountExport struct {
+	ExportedAt time.Time                  `json:"exported_at"`
+	Profile    gin.H                      `json:"profile"`
+	Identities []models.UserIdentity      `json:"identities"`
+	Posts      []models.Post              `json:"posts"`
+	Comments   []models.Comment           `json:"comments"`
+	SignIns    []models.SignIn            `json:"sign_ins"`
+	Points     []models.PointsTransaction `json:"points_history"`
+	Uploads    []string                   `json:"uploads"`
+	Logins     []models.LoginEvent        `json:"login_history"`
+}
+
+var exportHTML = template.Must(template.New("export").Parse(`<!doctype html>
+<html lang="zh-CN"><head><meta charset="utf-8"><title>AIBBS 数据导出 - {{index .Profile "username"}}</title>
+<style>body{font-family:-apple-system,Segoe UI,Roboto,Helvetica,Arial,sans-serif;max-width:960px;margin:24px auto;padding:0 16px;color:#222}table{border-collapse:collapse;width:100%;margin:8px 0 24px}td,th{border:1px solid #ddd;padding:6px 8px;text-align:left;vertical-align:top;font-size:14px}th{background:#f5f5f5}.content{white-space:pre-wrap}</style>
+</head><body>
+<h1>AIBBS 数据导出</h1>
+<p>导出时间：{{.ExportedAt.Format "2006-01-02 15:04:05"}}</p>
+<h2>个人资料</h2>
+<table>{{range $k, $v := .Profile}}<tr><th>{{$k}}</th><td>{{$v}}</td></tr>{{end}}</table>
+<h2>登录方式</h2>
+<table><tr><th>提供方</th><th>ID</th><th>绑定时间</th></tr>{{range .Identities}}<tr><td>{{.Provider}}</td><td>{{.ProviderID}}</td><td>{{.CreatedAt.Format "2006-01-02 15:04"}}</td></tr>{{end}}</table>
+<h2>帖子（{{len .Posts}}）</h2>
+<table><tr><th>ID</th><th>标题</th><th>分类</th><th>内容</th><th>发布时间</th></tr>{{range .Posts}}<tr><td>{{.ID}}</td><td>{{.Title}}</td><td>{{.Category}}</td><td class="content">{{.Content}}</td><td>{{.CreatedAt.Format "2006-01-02 15:04"}}</td></tr>{{end}}</table>
+<h2>评论（{{len .Comments}}）</h2>
+<table><tr><th>ID</th><th>帖子</th><th>内容</th><th>时间</th></tr>{{range .Comments}}<tr><td>{{.ID}}</td><td>{{.PostID}}</td><td class="content">{{.Content}}</td><td>{{.CreatedAt.Format "2006-01-02 15:04"}}</td></tr>{{end}}</table>
+<h2>签到（{{len .SignIns}}）</h2>
+<table><tr><th>日期</th><th>时间</th><th>积分</th><th>连续天数</th></tr>{{range .SignIns}}<tr><td>{{.SigninDay.Format "2006-01-02"}}</td><td>{{.SigninDate.Format "2006-01-02 15:04"}}</td><td>{{.PointsAwarded}}</td><td>{{.StreakAchieved}}</td></tr>{{end}}</table>
+<h2>积分明细（{{len .Points}}）</h2>
+<table><tr><th>时间</th><th>变动</th><th>余额</th><th>原因</th><th>备注</th></tr>{{range .Points}}<tr><td>{{.CreatedAt.Format "2006-01-02 15:04"}}</td><td>{{.Amount}}</td><td>{{.BalanceAfter}}</td><td>{{.Reason}}</td><td>{{.Note}}</td></tr>{{end}}</table>
+<h2>上传文件（{{len .Uploads}}）</h2>
+<ul>{{range .Uploads}}<li>{{.}}</li>{{end}}</ul>
+<h2>登录记录（{{len .Logins}}）</h2>
+<table><tr><th>时间</th><th>方式</th><th>IP</th><th>国家/地区</th><th>结果</th></tr>{{range .Logins}}<tr><td>{{.CreatedAt.Format "2006-01-02 15:04"}}</td><td>{{.Method}}</td><td>{{.IP}}</td><td>{{.Country}}</td><td>{{.Outcome}}</td></tr>{{end}}</table>
+</body></html>`))
+
+// ExportAccount builds a ZIP (JSON + HTML) of everything the forum stores about the current user.
+func (a *AuthController) ExportAccount(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		utils.Error(ctx, http.StatusUnauthorized, 40110, "unauthorized")
+		return
+	}
+	var user models.User
+	if err := a.db.First(&user, userID).Error; err != nil {
+		utils.Error(ctx, http.StatusNotFound, 40401, "user not found")
+		return
+	}
+
+	data := accountExport{ExportedAt: time.Now(), Profile: sanitizeUserResponse(user)}
+	queries := []struct {
+		dest  interface{}
+		order string
+	}{
+		{&data.Identities, "created_at ASC"},
+		{&data.Posts, "created_at ASC"},
+		{&data.Comments, "created_at ASC"},
+		{&data.SignIns, "signin_day ASC, id ASC"},
+		{&data.Points, "id ASC"},
+		{&data.Logins, "created_at DESC"},
+	}
+	for _, q := range queries {
+		// Unscoped: posts and comments in the trash are still the user's data
+		if err := a.db.Unscoped().Where("user_id = ?", userID).Order(q.order).Find(q.dest).Error; err != nil {
+			utils.Error(ctx, http.StatusInternalServerError, 50100, "failed to collect account data")
+			return
+		}
+	}
+	// Uploads live in external object storage; their URLs are referenced from posts.attachments
+	for _, p := range data.Posts {
+		var urls []string
+		if strings.TrimSpace(p.Attachments) != "" && json.Unmarshal([]byte(p.Attachments), &urls) == nil {
+			data.Uploads = append(data.Uploads, urls...)
+		}
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	files := map[string]interface{}{
+		"profile.json":        data.Profile,
+		"identities.json":     data.Identities,
+		"posts.json":          data.Posts,
+		"comments.json":       data.Comments,
+		"sign_ins.json":       data.SignIns,
+		"points_history.json": data.Points,
+		"uploads.json":        data.Uploads,
+		"login_history.json":  data.Logins,
+	}
+	for name, v := range files {
+		w, err := zw.Create(name)
+		if err == nil {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(v)
+		}
+		if err != nil {
+			utils.Error(ctx, http.StatusInternalServerError, 50101, "failed to build export archive")
+			return
+		}
+	}
+	w, err := zw.Create("index.html")
+	if err == nil {
+		err = exportHTML.Execute(w, data)
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50101, "failed to build export archive")
+		return
+	}
+
+	filename := fmt.Sprintf("aibbs-export-%d-%s.zip", user.ID, time.Now().Format("20060102150405"))
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	ctx.Data(http.StatusOK, "application/zip", buf.Bytes())
+}
+
+// RequestAccountDeletion schedules the current account for deletion after the grace period.
+func (a *AuthController) RequestAccountDeletion(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		utils.Error(ctx, http.StatusUnauthorized, 40110, "unauthorized")
+		return
+	}
+	var req struct {
+		Password string `json:"password"`
+		Confirm  string `json:"confirm"`
+		Mode     string `json:"mode"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.Error(ctx, http.StatusBadRequest, 40095, "invalid request payload")
+		return
+	}
+	mode := strings.ToLower(strings.TrimSpace(req.Mode))
+	if mode == "" {
+		mode = deletionModeReassign
+	}
+	if mode != deletionModeReassign && mode != deletionModeDelete {
+		utils.Error(ctx, http.StatusBadRequest, 40096, "mode 仅支持 reassign 或 delete")
+		return
+	}
+
+	var user models.User
+	if err := a.db.First(&user, userID).Error; err != nil {
+		utils.Error(ctx, http.StatusNotFound, 40401, "user not found")
+		return
+	}
+	// Re-authenticate: password accounts confirm with the password, OAuth-only accounts with the username
+	if user.PasswordHash != "" {
+		if !utils.CheckPassword(user.PasswordHash, req.Password) {
+			utils.Error(ctx, http.StatusForbidden, 40310, "密码错误")
+			return
+		}
+	} else if strings.TrimSpace(req.Confirm) != user.Username {
+		utils.Error(ctx, http.StatusForbidden, 40311, "请输入用户名以确认注销")
+		return
+	}
+
+	now := time.Now()
+	if err := a.db.Model(&user).Updates(map[string]interface{}{"deletion_requested_at": now, "deletion_mode": mode}).Error; err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50102, "failed to schedule deletion")
+		return
+	}
+	purgeAt := now.AddDate(0, 0, config.Get().AccountDeletionGraceDays)
+	utils.Success(ctx, gin.H{
+		"message":  "account deletion scheduled",
+		"mode":     mode,
+		"purge_at": purgeAt,
+	})
+}
+
+// CancelAccountDeletion withdraws a pending deletion request during the grace period.
+func (a *AuthController) CancelAccountDeletion(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		utils.Error(ctx, http.StatusUnauthorized, 40110, "unauthorized")
+		return
+	}
+	res := a.db.Model(&models.User{}).Where("id = ? AND deletion_requested_at IS NOT NULL", userID).
+		Updates(map[string]interface{}{"deletion_requested_at": nil, "deletion_mode": ""})
+	if res.Error != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50103, "failed to cancel deletion")
+		return
+	}
+	if res.RowsAffected == 0 {
+		utils.Error(ctx, http.StatusBadRequest, 40097, "no pending deletion request")
+		return
+	}
+	utils.Success(ctx, gin.H{"message": "account deletion cancelled"})
+}
+
+// StartAccountPurgeJob periodically purges accounts whose deletion grace period has elapsed.
+func StartAccountPurgeJob(db *gorm.DB, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			PurgeDeletedAccounts(db)
+			<-ticker.C
+		}
+	}()
+}
+
+// PurgeDeletedAccounts anonymizes and soft-deletes accounts past their grace period.
+func PurgeDeletedAccounts(db *gorm.DB) {
+	cutoff := time.Now().AddDate(0, 0, -config.Get().AccountDeletionGraceDays)
+	var users []models.User
+	if err := db.Where("deletion_requested_at IS NOT NULL AND deletion_requested_at <= ?", cutoff).Find(&users).Error; err != nil {
+		if utils.Sugar != nil {
+			utils.Sugar.Warnf("account purge query failed: %v", err)
+		}
+		return
+	}
+	for _, u := range users {
+		if err := purgeAccount(db, u); err != nil {
+			if utils.Sugar != nil {
+				utils.Sugar.Errorf("account purge failed user=%d err=%v", u.ID, err)
+			}
+			continue
+		}
+		if utils.Sugar != nil {
+			utils.Sugar.Infof("account purged user=%d mode=%s", u.ID, u.DeletionMode)
+		}
+	}
+	if len(users) > 0 {
+		utils.InvalidateByPrefix("cache:user:public:")
+		utils.InvalidateByPrefix("cache:posts:list:")
+		utils.InvalidateByPrefix("cache:post:detail:")
+	}
+}
+
+func purgeAccount(db *gorm.DB, u models.User) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if u.DeletionMode == deletionModeDelete {
+			if err := eraseContent(tx, u.ID); err != nil {
+				return err
+			}
+		} else {
+			ghost, err := ghostAccount(tx)
+			if err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Model(&models.Post{}).Where("user_id = ?", u.ID).Update("user_id", ghost.ID).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Model(&models.Comment{}).Where("user_id = ?", u.ID).Update("user_id", ghost.ID).Error; err != nil {
+				return err
+			}
+		}
+		for _, m := range []interface{}{&models.UserIdentity{}, &models.LoginEvent{}, &models.SignIn{}, &models.UserRole{}, &models.UserBadge{}, &models.HiddenContentPurchase{}} {
+			if err := tx.Where("user_id = ?", u.ID).Delete(m).Error; err != nil {
+				return err
+			}
+		}
+		// The user's own ledger entries stay (the retained row keeps the books balanced); the sign-ins
+		// they point at are gone
+		if err := tx.Model(&models.PointsTransaction{}).Where("user_id = ? AND ref_type = ?", u.ID, "signin").
+			Update("ref_id", 0).Error; err != nil {
+			return err
+		}
+		// Scrub personal data before the soft delete so the retained row holds nothing identifying
+		if err := tx.Model(&models.User{}).Where("id = ?", u.ID).Updates(map[string]interface{}{
+			"username":              "deleted-" + strconv.FormatUint(uint64(u.ID), 10),
+			"email":                 "",
+			"password_hash":         "",
+			"provider":              "",
+			"provider_id":           "",
+			"register_ip":           "",
+			"avatar_url":            "",
+			"signature":             "",
+			"deletion_requested_at": nil,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.User{}, u.ID).Error
+	})
+}
+
+// eraseContent hard-deletes the posts and comments of a user erased in delete mode, including those in
+// the trash and other users' comments under the posts, and detaches what referred to them. Tips and
+// ledger entries are the counterparties' history too, so they are kept with their content references
+// cleared; the user's tip messages are removed.
+func eraseContent(tx *gorm.DB, userID uint) error {
+	var postIDs, commentIDs []uint
+	if err := tx.Unscoped().Model(&models.Post{}).Where("user_id = ?", userID).Pluck("id", &postIDs).Error; err != nil {
+		return err
+	}
+	comments := tx.Unscoped().Model(&models.Comment{}).Where("user_id = ?", userID)
+	if len(postIDs) > 0 {
+		comments = comments.Or("post_id IN ?", postIDs)
+	}
+	if err := comments.Pluck("id", &commentIDs).Error; err != nil {
+		return err
+	}
+	// Reports, tips and ledger entries refer to content by type and id; GORM renders an empty IN as NULL
+	refs := func(typeCol, idCol string) *gorm.DB {
+		return tx.Where("("+typeCol+" = ? AND "+idCol+" IN ?) OR ("+typeCol+" = ? AND "+idCol+" IN ?)",
+			models.ReportTargetPost, postIDs, models.ReportTargetComment, commentIDs)
+	}
+	if len(postIDs)+len(commentIDs) > 0 {
+		if err := tx.Where(refs("target_type", "target_id")).Delete(&models.Report{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Tip{}).Where(refs("target_type", "target_id")).
+			Updates(map[string]interface{}{"target_id": 0, "post_id": 0}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.PointsTransaction{}).Where(refs("ref_type", "ref_id")).Update("ref_id", 0).Error; err != nil {
+			return err
+		}
+	}
+	if len(postIDs) > 0 {
+		if err := tx.Where("post_id IN ?", postIDs).Delete(&models.HiddenContentPurchase{}).Error; err != nil {
+			return err
+		}
+	}
+	// Tip messages are the user's words; the transfer note repeats them
+	if err := tx.Model(&models.PointsTransaction{}).
+		Where("reason = ? AND txn_id IN (?)", models.PointsReasonTip, tx.Model(&models.Tip{}).Select("txn_id").Where("from_user_id = ?", userID)).
+		Update("note", "").Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.Tip{}).Where("from_user_id = ?", userID).Update("message", "").Error; err != nil {
+		return err
+	}
+	if len(commentIDs) > 0 {
+		if err := tx.Unscoped().Where("id IN ?", commentIDs).Delete(&models.Comment{}).Error; err != nil {
+			return err
+		}
+	}
+	if len(postIDs) > 0 {
+		return tx.Unscoped().Where("id IN ?", postIDs).Delete(&models.Post{}).Error
+	}
+	return nil
+}
+
+// reservedUsername reports whether name belongs to accounts the system manages: the ghost account and
+// the "deleted-<id>" names given to purged accounts.
+func reservedUsername(name string) bool {
+	name = strings.TrimSpace(name)
+	return strings.EqualFold(name, config.Get().AccountGhostUsername) || strings.HasPrefix(strings.ToLower(name), "deleted-")
+}
+
+// ghostAccount returns (creating on first use) the shared account that keeps reassigned content. It
+// is found by its ghost flag, never by name, so a user holding the configured name cannot receive it;
+// in that case the ghost takes the first free "<name>-N".
+func ghostAccount(tx *gorm.DB) (*models.User, error) {
+	var ghost models.User
+	err := tx.Where("ghost = ?", true).First(&ghost).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		base := config.Get().AccountGhostUsername
+		name := base
+		for i := 1; ; i++ {
+			var n int64
+			if err := tx.Unscoped().Model(&models.User{}).Where("username = ?", name).Count(&n).Error; err != nil {
+				return nil, err
+			}
+			if n == 0 {
+				break
+			}
+			name = base + "-" + strconv.Itoa(i)
+		}
+		ghost = models.User{Username: name, RegisterIP: "system", Ghost: true}
+		err = tx.Create(&ghost).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ghost, nil
+}
@@ -6,7 +6,6 @@ import (
 	"crypto/sha256"
 	"crypto/subtle"
 	"encoding/hex"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -18,8 +17,6 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/github"
-	"golang.org/x/oauth2/google"
 	"gorm.io/gorm"
 
 	"github.com/cppla/aibbs/config"
@@ -32,6 +29,9 @@ type AuthController struct {
 	db *gorm.DB
 }
 
+// timezoneChangeCooldown is the minimum time between two timezone changes of a profile.
+const timezoneChangeCooldown = 30 * 24 * time.Hour
+
 // ListUsers returns paginated users including register IP
 func (a *AuthController) ListUsers(ctx *gin.Context) {
 	var users []models.User
@@ -92,6 +92,7 @@ func (a *AuthController) GetUserPublic(ctx *gin.Context) {
 		return
 	}
 	payload := sanitizeUserResponse(user)
+	payload["badges"] = userBadges(a.db, user.ID)
 	// cache wrapper for consistency
 	wrapper := struct {
 		Code    int         `json:"code"`
@@ -124,6 +125,7 @@ func (a *AuthController) GetUserPublicByUsername(ctx *gin.Context) {
 		return
 	}
 	payload := sanitizeUserResponse(user)
+	payload["badges"] = userBadges(a.db, user.ID)
 	wrapper := struct {
 		Code    int         `json:"code"`
 		Message string      `json:"message"`
@@ -180,9 +182,15 @@ func (a *AuthController) Register(ctx *gin.Context) {
 		utils.Error(ctx, http.StatusBadRequest, 40002, "用户名仅允许中文、英文、数字及 '-'")
 		return
 	}
+	// Usernames cannot be masked, so replace rules reject them just like block rules
+	nameCheck := utils.FilterText(a.db, models.FilterFieldUsername, req.Username)
+	if nameCheck.Action == models.FilterActionBlock || nameCheck.Action == models.FilterActionReplace {
+		utils.Error(ctx, http.StatusBadRequest, 40002, "用户名包含违禁词")
+		return
+	}
 
 	var existing models.User
-	if err := a.db.Where("username = ?", req.Username).First(&existing).Error; err == nil {
+	if reservedUsername(req.Username) || a.db.Where("username = ?", req.Username).First(&existing).Error == nil {
 		utils.Error(ctx, http.StatusConflict, 40901, "username already exists")
 		return
 	}
@@ -251,6 +259,9 @@ func (a *AuthController) Register(ctx *gin.Context) {
 
 	// record success for per-day limit
 	utils.RegistrationDailyIncrement(ip)
+	if nameCheck.Action == models.FilterActionReview {
+		fileHeldReport(a.db, models.ReportTargetUser, user.ID, nameCheck.Matches, spamVerdict{})
+	}
 
 	token, err := utils.GenerateToken(user.ID, user.Username, 72*time.Hour)
 	if err != nil {
@@ -364,10 +375,14 @@ func validPassword(s string) bool {
 }
 
 // Login verifies user credentials and issues a JWT.
+// Failures are counted per username and per IP; unknown usernames get exactly the same
+// responses (and bcrypt cost) as wrong passwords so accounts cannot be enumerated.
 func (a *AuthController) Login(ctx *gin.Context) {
 	type request struct {
-		Username string `json:"username" binding:"required"`
-		Password string `json:"password" binding:"required"`
+		Username      string `json:"username" binding:"required"`
+		Password      string `json:"password" binding:"required"`
+		CaptchaID     string `json:"captcha_id"`
+		CaptchaAnswer string `json:"captcha_answer"`
 	}
 
 	var req request
@@ -376,16 +391,47 @@ func (a *AuthController) Login(ctx *gin.Context) {
 		return
 	}
 
-	var user models.User
-	if err := a.db.Where("username = ?", req.Username).First(&user).Error; err != nil {
-		utils.Error(ctx, http.StatusUnauthorized, 40106, "invalid username or password")
+	ip := ctx.ClientIP()
+	if utils.LoginIsLocked(req.Username, ip) {
+		utils.Error(ctx, http.StatusTooManyRequests, 42930, "登录失败次数过多，请稍后再试")
+		var locked models.User
+		if a.db.Where("username = ?", req.Username).First(&locked).Error == nil {
+			recordLoginEvent(a.db, ctx, &locked, "password", loginOutcomeLocked)
+		}
 		return
 	}
 
-	if !utils.CheckPassword(user.PasswordHash, req.Password) {
-		utils.Error(ctx, http.StatusUnauthorized, 40106, "invalid username or password")
+	userFails, ipFails := utils.LoginFailures(req.Username, ip)
+	if utils.LoginCaptchaRequired(userFails, ipFails) {
+		if !utils.VerifyCaptcha(strings.TrimSpace(req.CaptchaID), strings.TrimSpace(req.CaptchaAnswer)) {
+			utils.Respond(ctx, http.StatusBadRequest, 40090, "请输入正确的验证码", gin.H{"captcha_required": true})
+			return
+		}
+	}
+	time.Sleep(utils.LoginDelay(max(userFails, ipFails)))
+
+	var user models.User
+	found := a.db.Where("username = ?", req.Username).First(&user).Error == nil
+	if !found {
+		utils.LoginDummyCheck(req.Password)
+	}
+	if !found || !utils.CheckPassword(user.PasswordHash, req.Password) {
+		userFails, ipFails, lockedNow := utils.LoginFailRecord(req.Username, ip)
+		if found {
+			recordLoginEvent(a.db, ctx, &user, "password", loginOutcomeFailure)
+			if lockedNow {
+				notifyLoginLockout(user, ip)
+			}
+		}
+		utils.Respond(ctx, http.StatusUnauthorized, 40106, "invalid username or password",
+			gin.H{"captcha_required": utils.LoginCaptchaRequired(userFails, ipFails)})
 		return
 	}
+	utils.LoginSuccessReset(req.Username)
+	if a.loginBanned(ctx, &user, "password") {
+		return
+	}
+	recordLoginEvent(a.db, ctx, &user, "password", loginOutcomeSuccess)
 
 	// No-op: we no longer use last_login_at for daily active metrics
 
@@ -401,6 +447,21 @@ func (a *AuthController) Login(ctx *gin.Context) {
 	})
 }
 
+// notifyLoginLockout emails the account owner when repeated failures lock the account.
+func notifyLoginLockout(user models.User, ip string) {
+	if strings.TrimSpace(user.Email) == "" {
+		return
+	}
+	minutes := config.Get().LoginLockoutMinutes
+	go func() {
+		subject := "AIBBS 账号安全提醒"
+		body := fmt.Sprintf("您的账号 %s 在短时间内多次登录失败（最近来源 IP：%s），已被临时锁定 %d 分钟。\n如非本人操作，建议尽快修改密码。", user.Username, ip, minutes)
+		if err := utils.SendMail(user.Email, subject, body); err != nil && utils.Sugar != nil {
+			utils.Sugar.Warnf("login lockout mail failed user=%d err=%v", user.ID, err)
+		}
+	}()
+}
+
 // Logout invalidates the token by blacklisting it until expiration.
 func (a *AuthController) Logout(ctx *gin.Context) {
 	authHeader := ctx.GetHeader("Authorization")
@@ -426,10 +487,22 @@ func (a *AuthController) Logout(ctx *gin.Context) {
 	utils.Success(ctx, gin.H{"message": "logged out"})
 }
 
+// OAuthProviders lists configured third-party login providers for the frontend.
+func (a *AuthController) OAuthProviders(ctx *gin.Context) {
+	items := []gin.H{}
+	for _, p := range config.Get().OAuthProviders {
+		if p.ClientID == "" || p.ClientSecret == "" {
+			continue
+		}
+		items = append(items, gin.H{"name": p.Name, "display_name": fallback(p.DisplayName, p.Name)})
+	}
+	utils.Success(ctx, gin.H{"items": items})
+}
+
 // OAuthRedirect generates a provider-specific authorization URL.
 func (a *AuthController) OAuthRedirect(ctx *gin.Context) {
 	provider := ctx.Param("provider")
-	cfg, err := a.oauthConfig(provider)
+	cfg, _, err := a.oauthConfig(ctx.Request.Context(), provider)
 	if err != nil {
 		utils.Error(ctx, http.StatusBadRequest, 40004, err.Error())
 		return
@@ -453,34 +526,54 @@ func (a *AuthController) OAuthCallback(ctx *gin.Context) {
 		return
 	}
 
-	if !utils.ConsumeState(state) {
+	stateValue, ok := utils.ConsumeStateValue(state)
+	if !ok {
 		utils.Error(ctx, http.StatusBadRequest, 40006, "invalid or expired state")
 		return
 	}
+	linkUserID, nonce, isLink := parseLinkState(stateValue)
+	if isLink && !checkLinkNonce(ctx, nonce) {
+		utils.Error(ctx, http.StatusForbidden, 40380, "link must be completed in the browser that started it")
+		return
+	}
 
-	cfg, err := a.oauthConfig(provider)
+	cfg, p, err := a.oauthConfig(ctx.Request.Context(), provider)
 	if err != nil {
 		utils.Error(ctx, http.StatusBadRequest, 40004, err.Error())
 		return
 	}
 
-	token, err := cfg.Exchange(context.Background(), code)
+	token, err := cfg.Exchange(ctx.Request.Context(), code)
 	if err != nil {
 		utils.Error(ctx, http.StatusBadRequest, 40007, "failed to exchange code")
 		return
 	}
 
-	userInfo, err := a.fetchOAuthUser(provider, token)
+	userInfo, err := a.fetchOAuthUser(ctx.Request.Context(), p, token)
 	if err != nil {
 		utils.Error(ctx, http.StatusInternalServerError, 50005, err.Error())
 		return
 	}
 
-	user, err := a.findOrCreateOAuthUser(provider, userInfo)
+	// State issued by OAuthLink: attach the identity to that account instead of logging in
+	if isLink {
+		a.respondLinkIdentity(ctx, linkUserID, p.Name, userInfo)
+		return
+	}
+
+	user, err := a.findOrCreateOAuthUser(p.Name, userInfo)
+	if errors.Is(err, errAccountDeleted) {
+		utils.Error(ctx, http.StatusForbidden, 40312, "this login belongs to a deleted account")
+		return
+	}
 	if err != nil {
 		utils.Error(ctx, http.StatusInternalServerError, 50006, "failed to persist user")
 		return
 	}
+	if a.loginBanned(ctx, user, p.Name) {
+		return
+	}
+	recordLoginEvent(a.db, ctx, user, p.Name, loginOutcomeSuccess)
 
 	// No-op: we no longer use last_login_at for daily active metrics
 
@@ -501,16 +594,48 @@ func (a *AuthController) TelegramLogin(ctx *gin.Context) {
 		return
 	}
 
+	userInfo, ok := a.telegramIdentity(ctx, req)
+	if !ok {
+		return
+	}
+
+	user, err := a.findOrCreateOAuthUser("telegram", userInfo)
+	if errors.Is(err, errAccountDeleted) {
+		utils.Error(ctx, http.StatusForbidden, 40312, "this login belongs to a deleted account")
+		return
+	}
+	if err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50006, "failed to persist user")
+		return
+	}
+	if a.loginBanned(ctx, user, "telegram") {
+		return
+	}
+	recordLoginEvent(a.db, ctx, user, "telegram", loginOutcomeSuccess)
+
+	// No-op: we no longer use last_login_at for daily active metrics
+
+	token, err := utils.GenerateToken(user.ID, user.Username, 72*time.Hour)
+	if err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50004, "failed to generate token")
+		return
+	}
+
+	utils.Success(ctx, gin.H{"token": token, "user": sanitizeUserResponseWithAdmin(*user)})
+}
+
+// telegramIdentity verifies the widget payload and converts it; it writes the error response on failure.
+func (a *AuthController) telegramIdentity(ctx *gin.Context, req telegramLoginRequest) (*oauthUser, bool) {
 	cfg := config.Get()
 	if !verifyTelegramSignature(cfg.TelegramBotToken, req) {
 		utils.Error(ctx, http.StatusUnauthorized, 40108, "invalid telegram signature")
-		return
+		return nil, false
 	}
 
 	authTime := time.Unix(req.AuthDate, 0)
 	if time.Since(authTime) > 5*time.Minute {
 		utils.Error(ctx, http.StatusUnauthorized, 40109, "telegram login expired")
-		return
+		return nil, false
 	}
 
 	displayName := strings.TrimSpace(req.FirstName + " " + req.LastName)
@@ -518,29 +643,13 @@ func (a *AuthController) TelegramLogin(ctx *gin.Context) {
 		displayName = req.Username
 	}
 
-	userInfo := oauthUser{
+	return &oauthUser{
 		ID:          req.ID,
 		Username:    req.Username,
 		DisplayName: displayName,
 		Email:       "",
 		AvatarURL:   req.PhotoURL,
-	}
-
-	user, err := a.findOrCreateOAuthUser("telegram", &userInfo)
-	if err != nil {
-		utils.Error(ctx, http.StatusInternalServerError, 50006, "failed to persist user")
-		return
-	}
-
-	// No-op: we no longer use last_login_at for daily active metrics
-
-	token, err := utils.GenerateToken(user.ID, user.Username, 72*time.Hour)
-	if err != nil {
-		utils.Error(ctx, http.StatusInternalServerError, 50004, "failed to generate token")
-		return
-	}
-
-	utils.Success(ctx, gin.H{"token": token, "user": sanitizeUserResponseWithAdmin(*user)})
+	}, true
 }
 
 // Me returns the current authenticated user's information.
@@ -557,7 +666,16 @@ func (a *AuthController) Me(ctx *gin.Context) {
 		return
 	}
 
-	utils.Success(ctx, sanitizeUserResponseWithAdmin(user))
+	resp := sanitizeUserResponseWithAdmin(user)
+	resp["sanctions"] = sanctionSummary(utils.ActiveSanctions(a.db, user.ID))
+	if user.DeletionRequestedAt != nil {
+		resp["deletion"] = gin.H{
+			"requested_at": user.DeletionRequestedAt,
+			"mode":         user.DeletionMode,
+			"purge_at":     user.DeletionRequestedAt.AddDate(0, 0, config.Get().AccountDeletionGraceDays),
+		}
+	}
+	utils.Success(ctx, resp)
 }
 
 // UpdateProfile allows the authenticated user to update basic profile fields.
@@ -567,10 +685,14 @@ func (a *AuthController) UpdateProfile(ctx *gin.Context) {
 		utils.Error(ctx, http.StatusUnauthorized, 40108, "unauthorized")
 		return
 	}
+	if sanctionBlocks(ctx, a.db, models.SanctionSuspend) {
+		return
+	}
 
 	var req struct {
-		Email     string `json:"email"`
-		Signature string `json:"signature"`
+		Email     string  `json:"email"`
+		Signature string  `json:"signature"`
+		Timezone  *string `json:"timezone"` // IANA name; "" falls back to the site default
 	}
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		utils.Error(ctx, http.StatusBadRequest, 40030, "invalid request payload")
@@ -583,6 +705,7 @@ func (a *AuthController) UpdateProfile(ctx *gin.Context) {
 		return
 	}
 
+	var held []utils.FilterMatch
 	if strings.TrimSpace(req.Email) != "" {
 		user.Email = strings.TrimSpace(req.Email)
 	}
@@ -591,6 +714,10 @@ func (a *AuthController) UpdateProfile(ctx *gin.Context) {
 		sig := strings.TrimSpace(req.Signature)
 		// Sanitize to avoid XSS; only keep safe subset if HTML is provided
 		sig = utils.Sanitize(sig)
+		var ok bool
+		if sig, held, ok = filterInput(ctx, a.db, models.FilterFieldSignature, sig); !ok {
+			return
+		}
 		// Limit length to 255 runes
 		if len([]rune(sig)) > 255 {
 			rs := []rune(sig)
@@ -598,11 +725,28 @@ func (a *AuthController) UpdateProfile(ctx *gin.Context) {
 		}
 		user.Signature = sig
 	}
+	if req.Timezone != nil {
+		tz := strings.TrimSpace(*req.Timezone)
+		if tz != "" && utils.LoadLocation(tz) == nil {
+			utils.Error(ctx, http.StatusBadRequest, 40037, "unknown timezone")
+			return
+		}
+		if tz != user.Timezone {
+			// Moving east starts the next sign-in day early, so changes are rate-limited
+			if user.TimezoneChangedAt != nil && time.Since(*user.TimezoneChangedAt) < timezoneChangeCooldown {
+				utils.Error(ctx, http.StatusTooManyRequests, 42940, "timezone can only be changed once every 30 days")
+				return
+			}
+			now := time.Now()
+			user.Timezone, user.TimezoneChangedAt = tz, &now
+		}
+	}
 
 	if err := a.db.Save(&user).Error; err != nil {
 		utils.Error(ctx, http.StatusInternalServerError, 50031, "failed to update profile")
 		return
 	}
+	fileHeldReport(a.db, models.ReportTargetUser, user.ID, held, spamVerdict{})
 	// Invalidate user public cache by id and username
 	utils.InvalidateByPrefix("cache:user:public:" + strconv.Itoa(int(user.ID)))
 	utils.InvalidateByPrefix("cache:user:public:uname:" + user.Username)
@@ -610,47 +754,30 @@ func (a *AuthController) UpdateProfile(ctx *gin.Context) {
 	utils.Success(ctx, sanitizeUserResponseWithAdmin(user))
 }
 
-func (a *AuthController) oauthConfig(provider string) (*oauth2.Config, error) {
-	cfg := config.Get()
-	switch strings.ToLower(provider) {
-	case "github":
-		if cfg.GitHubClientID == "" || cfg.GitHubClientSecret == "" {
-			return nil, fmt.Errorf("github oauth not configured")
-		}
-		return &oauth2.Config{
-			ClientID:     cfg.GitHubClientID,
-			ClientSecret: cfg.GitHubClientSecret,
-			RedirectURL:  fmt.Sprintf("%s/api/v1/auth/oauth/github/callback", cfg.OAuthRedirectBase),
-			Scopes:       []string{"read:user", "user:email"},
-			Endpoint:     github.Endpoint,
-		}, nil
-	case "google":
-		if cfg.GoogleClientID == "" || cfg.GoogleClientSecret == "" {
-			return nil, fmt.Errorf("google oauth not configured")
-		}
-		return &oauth2.Config{
-			ClientID:     cfg.GoogleClientID,
-			ClientSecret: cfg.GoogleClientSecret,
-			RedirectURL:  fmt.Sprintf("%s/api/v1/auth/oauth/google/callback", cfg.OAuthRedirectBase),
-			Scopes:       []string{"openid", "profile", "email"},
-			Endpoint:     google.Endpoint,
-		}, nil
-	case "telegram":
-		return nil, fmt.Errorf("telegram login uses dedicated endpoint")
-	default:
-		return nil, fmt.Errorf("unsupported provider: %s", provider)
-	}
-}
-
-func (a *AuthController) fetchOAuthUser(provider string, token *oauth2.Token) (*oauthUser, error) {
-	switch strings.ToLower(provider) {
-	case "github":
-		return fetchGitHubUser(token)
-	case "google":
-		return fetchGoogleUser(token)
-	default:
-		return nil, fmt.Errorf("unsupported provider: %s", provider)
+func (a *AuthController) oauthConfig(ctx context.Context, provider string) (*oauth2.Config, *config.OAuthProvider, error) {
+	if strings.EqualFold(provider, "telegram") {
+		return nil, nil, fmt.Errorf("telegram login uses dedicated endpoint")
+	}
+	p, err := utils.ResolveOAuthProvider(ctx, provider)
+	if err != nil {
+		return nil, nil, err
+	}
+	redirect := fmt.Sprintf("%s/api/v1/auth/oauth/%s/callback", config.Get().OAuthRedirectBase, p.Name)
+	return utils.OAuth2Config(p, redirect), p, nil
+}
+
+func (a *AuthController) fetchOAuthUser(ctx context.Context, p *config.OAuthProvider, token *oauth2.Token) (*oauthUser, error) {
+	identity, err := utils.FetchOAuthIdentity(ctx, p, token)
+	if err != nil {
+		return nil, err
 	}
+	return &oauthUser{
+		ID:          identity.ID,
+		Username:    identity.Username,
+		DisplayName: identity.DisplayName,
+		Email:       identity.Email,
+		AvatarURL:   identity.AvatarURL,
+	}, nil
 }
 
 type oauthUser struct {
@@ -663,143 +790,60 @@ type oauthUser struct {
 
 func (a *AuthController) findOrCreateOAuthUser(provider string, data *oauthUser) (*models.User, error) {
 	var user models.User
-	err := a.db.Where("provider = ? AND provider_id = ?", provider, data.ID).First(&user).Error
-	if err != nil {
+	var identity models.UserIdentity
+	err := a.db.Where("provider = ? AND provider_id = ?", provider, data.ID).First(&identity).Error
+	if err == nil {
+		err = a.db.First(&user, identity.UserID).Error
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			email := strings.TrimSpace(data.Email)
-			username := a.ensureUniqueUsername(data.Username, provider, data.ID)
-			user = models.User{
-				Username:   username,
-				Email:      email,
-				Provider:   provider,
-				ProviderID: data.ID,
-				AvatarURL:  data.AvatarURL,
-				RegisterIP: "oauth",
-			}
-
-			if err := a.db.Create(&user).Error; err != nil {
-				return nil, err
-			}
-		} else {
-			return nil, err
+			// The identity still belongs to a deleted account; it cannot sign up a new one
+			return nil, errAccountDeleted
 		}
-	} else {
-		updates := map[string]interface{}{
-			"email":      strings.TrimSpace(data.Email),
-			"avatar_url": data.AvatarURL,
+	} else if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Accounts created before user_identities existed still carry the legacy columns
+		err = a.db.Where("provider = ? AND provider_id = ?", provider, data.ID).First(&user).Error
+		if err == nil {
+			_ = a.db.Create(&models.UserIdentity{UserID: user.ID, Provider: provider, ProviderID: data.ID, Email: strings.TrimSpace(data.Email)}).Error
 		}
-		_ = a.db.Model(&user).Updates(updates)
-	}
-
-	return &user, nil
-}
-
-func fetchGitHubUser(token *oauth2.Token) (*oauthUser, error) {
-	client := http.Client{}
-	req, _ := http.NewRequest("GET", "https://api.github.com/user", nil)
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token.AccessToken))
-	req.Header.Set("Accept", "application/vnd.github+json")
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("github user info request failed: %s", resp.Status)
 	}
-
-	var payload struct {
-		ID        int64  `json:"id"`
-		Login     string `json:"login"`
-		Name      string `json:"name"`
-		AvatarURL string `json:"avatar_url"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
-		return nil, err
-	}
-
-	email, _ := fetchGitHubEmail(token.AccessToken)
-
-	return &oauthUser{
-		ID:          fmt.Sprintf("%d", payload.ID),
-		Username:    payload.Login,
-		DisplayName: fallback(payload.Name, payload.Login),
-		Email:       email,
-		AvatarURL:   payload.AvatarURL,
-	}, nil
-}
-
-func fetchGitHubEmail(accessToken string) (string, error) {
-	req, _ := http.NewRequest("GET", "https://api.github.com/user/emails", nil)
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
-	req.Header.Set("Accept", "application/vnd.github+json")
-
-	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("github emails request failed: %s", resp.Status)
-	}
-
-	var emails []struct {
-		Email    string `json:"email"`
-		Primary  bool   `json:"primary"`
-		Verified bool   `json:"verified"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&emails); err != nil {
-		return "", err
-	}
-
-	for _, email := range emails {
-		if email.Primary && email.Verified {
-			return email.Email, nil
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		email := strings.TrimSpace(data.Email)
+		username := a.ensureUniqueUsername(data.Username, provider, data.ID)
+		user = models.User{
+			Username:   username,
+			Email:      email,
+			Provider:   provider,
+			ProviderID: data.ID,
+			AvatarURL:  data.AvatarURL,
+			RegisterIP: "oauth",
 		}
-	}
-
-	if len(emails) > 0 {
-		return emails[0].Email, nil
-	}
-
-	return "", nil
-}
-
-func fetchGoogleUser(token *oauth2.Token) (*oauthUser, error) {
-	req, _ := http.NewRequest("GET", "https://www.googleapis.com/oauth2/v2/userinfo", nil)
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token.AccessToken))
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
+		err = a.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+			return tx.Create(&models.UserIdentity{UserID: user.ID, Provider: provider, ProviderID: data.ID, Email: email}).Error
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &user, nil
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("google user info request failed: %s", resp.Status)
+	updates := map[string]interface{}{}
+	if email := strings.TrimSpace(data.Email); email != "" && user.Email == "" {
+		updates["email"] = email
 	}
-
-	var payload struct {
-		ID      string `json:"id"`
-		Email   string `json:"email"`
-		Name    string `json:"name"`
-		Picture string `json:"picture"`
+	if data.AvatarURL != "" {
+		updates["avatar_url"] = data.AvatarURL
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
-		return nil, err
+	if len(updates) > 0 {
+		_ = a.db.Model(&user).Updates(updates)
 	}
 
-	return &oauthUser{
-		ID:          payload.ID,
-		Username:    payload.Email,
-		DisplayName: payload.Name,
-		Email:       payload.Email,
-		AvatarURL:   payload.Picture,
-	}, nil
+	return &user, nil
 }
 
 func fallback(values ...string) string {
@@ -874,6 +918,10 @@ func sanitizeUsername(input string) string {
 
 func (a *AuthController) ensureUniqueUsername(base, provider, id string) string {
 	base = sanitizeUsername(base)
+	if reservedUsername(base) || utils.FilterText(a.db, models.FilterFieldUsername, base).Action != "" {
+		// Fall back to the generated name rather than importing a filtered one
+		base = ""
+	}
 	if base == "" {
 		base = sanitizeUsername(fmt.Sprintf("%s_%s", provider, id))
 		if base == "" {
@@ -911,24 +959,39 @@ func sanitizeUserResponse(user models.User) gin.H {
 	}
 }
 
-// isAdminUsername checks whether given username is configured as an admin (case-insensitive)
-func isAdminUsername(username string) bool {
-	uname := strings.TrimSpace(username)
-	if uname == "" {
-		return false
-	}
-	cfg := config.Get()
-	for _, u := range cfg.AdminUsernames {
-		if strings.EqualFold(strings.TrimSpace(u), uname) {
-			return true
-		}
-	}
-	return false
-}
-
-// sanitizeUserResponseWithAdmin includes is_admin for authenticated responses
+// sanitizeUserResponseWithAdmin adds roles and permissions (plus the legacy is_admin flag) for authenticated responses
 func sanitizeUserResponseWithAdmin(user models.User) gin.H {
 	m := sanitizeUserResponse(user)
-	m["is_admin"] = isAdminUsername(user.Username)
+	m["timezone"] = user.Timezone
+	grants, _ := utils.UserGrants(config.DB(), user.ID)
+	roles := []gin.H{}
+	seenRole := map[string]bool{}
+	permissions := []string{}
+	seenPerm := map[string]bool{}
+	isAdmin := false
+	for _, g := range grants {
+		if key := g.Role + "|" + g.Category; !seenRole[key] {
+			seenRole[key] = true
+			roles = append(roles, gin.H{"name": g.Role, "category": g.Category})
+		}
+		if g.Role == models.RoleAdmin {
+			isAdmin = true
+		}
+		if g.Permission == "" {
+			continue
+		}
+		// Category-scoped permissions are reported as "<perm>@<category>"
+		perm := g.Permission
+		if g.Category != "" {
+			perm += "@" + g.Category
+		}
+		if !seenPerm[perm] {
+			seenPerm[perm] = true
+			permissions = append(permissions, perm)
+		}
+	}
+	m["roles"] = roles
+	m["permissions"] = permissions
+	m["is_admin"] = isAdmin
 	return m
 }
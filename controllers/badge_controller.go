@@ -0,0 +1,375 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/cppla/aibbs/models"
+	"github.com/cppla/aibbs/utils"
+)
+
+// BadgeController manages badge definitions and the badges held by users.
+type BadgeController struct {
+	db *gorm.DB
+}
+
+// NewBadgeController builds a BadgeController.
+func NewBadgeController(db *gorm.DB) *BadgeController {
+	return &BadgeController{db: db}
+}
+
+type badgeRequest struct {
+	Slug        *string `json:"slug"`
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	Icon        *string `json:"icon"`
+	Rule        *string `json:"rule"`
+	Threshold   *int    `json:"threshold"`
+	Enabled     *bool   `json:"enabled"`
+}
+
+// ListBadges returns the enabled badges with their award rules.
+func (b *BadgeController) ListBadges(ctx *gin.Context) {
+	var items []models.Badge
+	if err := b.db.Where("enabled = ?", true).Order("id ASC").Find(&items).Error; err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50193, "failed to list badges")
+		return
+	}
+	utils.Success(ctx, gin.H{"items": items})
+}
+
+// AdminListBadges returns every badge, including disabled ones, with the number of current holders.
+func (b *BadgeController) AdminListBadges(ctx *gin.Context) {
+	var items []models.Badge
+	if err := b.db.Order("id ASC").Find(&items).Error; err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50193, "failed to list badges")
+		return
+	}
+	var counts []struct {
+		BadgeID uint
+		Holders int64
+	}
+	b.db.Model(&models.UserBadge{}).Select("badge_id, COUNT(*) AS holders").
+		Where("revoked_at IS NULL").Group("badge_id").Scan(&counts)
+	holders := make(map[uint]int64, len(counts))
+	for _, c := range counts {
+		holders[c.BadgeID] = c.Holders
+	}
+	out := make([]gin.H, 0, len(items))
+	for _, badge := range items {
+		out = append(out, gin.H{"badge": badge, "holders": holders[badge.ID]})
+	}
+	utils.Success(ctx, gin.H{"items": out})
+}
+
+// CreateBadge adds a badge definition; a badge with a rule is awarded to existing users by the next award run.
+func (b *BadgeController) CreateBadge(ctx *gin.Context) {
+	var req badgeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil || req.Slug == nil || req.Name == nil {
+		utils.Error(ctx, http.StatusBadRequest, 40039, "slug and name are required")
+		return
+	}
+	badge := models.Badge{Enabled: true}
+	if !applyBadgeRequest(ctx, &badge, req) {
+		return
+	}
+	var count int64
+	b.db.Model(&models.Badge{}).Where("slug = ?", badge.Slug).Count(&count)
+	if count > 0 {
+		utils.Error(ctx, http.StatusConflict, 40966, "badge slug already exists")
+		return
+	}
+	if err := b.db.Create(&badge).Error; err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50194, "failed to create badge")
+		return
+	}
+	recordModeration(ctx, b.db, "badge.create", "badge", badge.ID, "", nil)
+	utils.Success(ctx, gin.H{"badge": badge})
+}
+
+// UpdateBadge edits a badge; omitted fields are left unchanged.
+func (b *BadgeController) UpdateBadge(ctx *gin.Context) {
+	var badge models.Badge
+	if err := b.db.First(&badge, ctx.Param("id")).Error; err != nil {
+		utils.Error(ctx, http.StatusNotFound, 40463, "badge not found")
+		return
+	}
+	before := badge
+	var req badgeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.Error(ctx, http.StatusBadRequest, 40039, "invalid request payload")
+		return
+	}
+	if !applyBadgeRequest(ctx, &badge, req) {
+		return
+	}
+	if err := b.db.Model(&badge).Select("slug", "name", "description", "icon", "rule", "threshold", "enabled").Updates(&badge).Error; err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50194, "failed to update badge")
+		return
+	}
+	recordModeration(ctx, b.db, "badge.update", "badge", badge.ID, "", before)
+	utils.InvalidateByPrefix("cache:user:public:")
+	utils.Success(ctx, gin.H{"badge": badge})
+}
+
+// DeleteBadge removes a badge definition together with every award of it.
+func (b *BadgeController) DeleteBadge(ctx *gin.Context) {
+	var badge models.Badge
+	if err := b.db.First(&badge, ctx.Param("id")).Error; err != nil {
+		utils.Error(ctx, http.StatusNotFound, 40463, "badge not found")
+		return
+	}
+	err := b.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("badge_id = ?", badge.ID).Delete(&models.UserBadge{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&badge).Error
+	})
+	if err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50194, "failed to delete badge")
+		return
+	}
+	recordModeration(ctx, b.db, "badge.delete", "badge", badge.ID, moderationReason(ctx), badge)
+	utils.InvalidateByPrefix("cache:user:public:")
+	utils.Success(ctx, gin.H{"message": "badge deleted"})
+}
+
+// GrantBadge gives a badge to a user ({"badge_id":1,"note":"..."}), restoring it if it was revoked.
+func (b *BadgeController) GrantBadge(ctx *gin.Context) {
+	var req struct {
+		BadgeID uint   `json:"badge_id" binding:"required"`
+		Note    string `json:"note"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.Error(ctx, http.StatusBadRequest, 40039, "badge_id is required")
+		return
+	}
+	user, ok := b.loadUser(ctx)
+	if !ok {
+		return
+	}
+	var badge models.Badge
+	if err := b.db.First(&badge, req.BadgeID).Error; err != nil {
+		utils.Error(ctx, http.StatusNotFound, 40463, "badge not found")
+		return
+	}
+	actorID, _ := getUserID(ctx)
+	note := strings.TrimSpace(req.Note)
+	var held models.UserBadge
+	err := b.db.Where("user_id = ? AND badge_id = ?", user.ID, badge.ID).First(&held).Error
+	switch {
+	case err == nil && held.RevokedAt == nil:
+		utils.Error(ctx, http.StatusConflict, 40966, "user already holds this badge")
+		return
+	case err == nil:
+		err = b.db.Model(&held).Updates(map[string]interface{}{"revoked_at": nil, "granted_by": actorID, "note": note}).Error
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		err = b.db.Create(&models.UserBadge{UserID: user.ID, BadgeID: badge.ID, GrantedBy: actorID, Note: note}).Error
+	}
+	if err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50195, "failed to grant badge")
+		return
+	}
+	recordModeration(ctx, b.db, "user.badge_grant", "user", user.ID, note, gin.H{"badge": badge.Slug})
+	notifyBadge(b.db, user, badge)
+	invalidateUserPublic(user)
+	utils.Success(ctx, gin.H{"badges": userBadges(b.db, user.ID)})
+}
+
+// RevokeBadge takes a badge away from a user. The award is kept as revoked so rules do not re-award it.
+func (b *BadgeController) RevokeBadge(ctx *gin.Context) {
+	user, ok := b.loadUser(ctx)
+	if !ok {
+		return
+	}
+	res := b.db.Model(&models.UserBadge{}).
+		Where("user_id = ? AND badge_id = ? AND revoked_at IS NULL", user.ID, ctx.Param("badgeId")).
+		Update("revoked_at", time.Now())
+	if res.Error != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50195, "failed to revoke badge")
+		return
+	}
+	if res.RowsAffected == 0 {
+		utils.Error(ctx, http.StatusNotFound, 40463, "user does not hold this badge")
+		return
+	}
+	recordModeration(ctx, b.db, "user.badge_revoke", "user", user.ID, moderationReason(ctx), gin.H{"badge_id": ctx.Param("badgeId")})
+	invalidateUserPublic(user)
+	utils.Success(ctx, gin.H{"badges": userBadges(b.db, user.ID)})
+}
+
+func (b *BadgeController) loadUser(ctx *gin.Context) (models.User, bool) {
+	var user models.User
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		utils.Error(ctx, http.StatusBadRequest, 40031, "invalid user id")
+		return user, false
+	}
+	if err := b.db.Select("id", "username").First(&user, id).Error; err != nil {
+		utils.Error(ctx, http.StatusNotFound, 40462, "user not found")
+		return user, false
+	}
+	return user, true
+}
+
+// applyBadgeRequest validates and copies the provided fields onto badge.
+func applyBadgeRequest(ctx *gin.Context, badge *models.Badge, req badgeRequest) bool {
+	if req.Slug != nil {
+		badge.Slug = strings.ToLower(strings.TrimSpace(*req.Slug))
+	}
+	if req.Name != nil {
+		badge.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Description != nil {
+		badge.Description = strings.TrimSpace(*req.Description)
+	}
+	if req.Icon != nil {
+		badge.Icon = strings.TrimSpace(*req.Icon)
+	}
+	if req.Rule != nil {
+		badge.Rule = strings.ToLower(strings.TrimSpace(*req.Rule))
+	}
+	if req.Threshold != nil {
+		badge.Threshold = *req.Threshold
+	}
+	if req.Enabled != nil {
+		badge.Enabled = *req.Enabled
+	}
+
+	if badge.Slug == "" || len(badge.Slug) > 64 || badge.Name == "" || len([]rune(badge.Name)) > 64 {
+		utils.Error(ctx, http.StatusBadRequest, 40039, "slug and name must be 1-64 characters")
+		return false
+	}
+	if len([]rune(badge.Description)) > 255 || len(badge.Icon) > 512 {
+		utils.Error(ctx, http.StatusBadRequest, 40039, "description or icon too long")
+		return false
+	}
+	if !containsString(models.BadgeRules, badge.Rule) {
+		utils.Error(ctx, http.StatusBadRequest, 40047, "rule must be empty, post_count, signin_streak or membership_years")
+		return false
+	}
+	if badge.Rule != models.BadgeRuleManual && badge.Threshold < 1 {
+		utils.Error(ctx, http.StatusBadRequest, 40047, "threshold must be at least 1")
+		return false
+	}
+	return true
+}
+
+// userBadges returns the enabled, unrevoked badges held by the user, oldest first.
+func userBadges(db *gorm.DB, userID uint) []gin.H {
+	var held []models.UserBadge
+	db.Joins("Badge").
+		Where("user_badges.user_id = ? AND user_badges.revoked_at IS NULL AND Badge.enabled = ?", userID, true).
+		Order("user_badges.created_at ASC").Find(&held)
+	out := make([]gin.H, 0, len(held))
+	for _, h := range held {
+		out = append(out, gin.H{
+			"id":          h.Badge.ID,
+			"slug":        h.Badge.Slug,
+			"name":        h.Badge.Name,
+			"description": h.Badge.Description,
+			"icon":        h.Badge.Icon,
+			"awarded_at":  h.CreatedAt,
+		})
+	}
+	return out
+}
+
+// awardBadges checks the enabled badges with the given rule for one user, e.g. after a post or a
+// sign-in, and awards those now earned.
+func awardBadges(db *gorm.DB, userID uint, rule string) {
+	var badges []models.Badge
+	if err := db.Where("enabled = ? AND rule = ?", true, rule).Find(&badges).Error; err != nil {
+		return
+	}
+	for _, badge := range badges {
+		awardEligible(db, badge, userID)
+	}
+}
+
+// AwardBadges runs every automatic rule for all users. Membership badges only change over time, so
+// they rely on this run; the others are also checked when the relevant event happens.
+func AwardBadges(db *gorm.DB) {
+	var badges []models.Badge
+	if err := db.Where("enabled = ? AND rule <> ?", true, models.BadgeRuleManual).Find(&badges).Error; err != nil {
+		if utils.Sugar != nil {
+			utils.Sugar.Warnf("badge award run failed: %v", err)
+		}
+		return
+	}
+	for _, badge := range badges {
+		awardEligible(db, badge, 0)
+	}
+}
+
+// StartBadgeAwardJob periodically runs AwardBadges.
+func StartBadgeAwardJob(db *gorm.DB, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			AwardBadges(db)
+			<-ticker.C
+		}
+	}()
+}
+
+// awardEligible awards badge to every user (or only userID when non-zero) who meets its rule and has
+// never held it.
+func awardEligible(db *gorm.DB, badge models.Badge, userID uint) {
+	var (
+		q   *gorm.DB
+		col string
+	)
+	switch badge.Rule {
+	case models.BadgeRulePostCount:
+		q, col = db.Model(&models.Post{}).Where("status = ? AND moved_to = 0", models.StatusPublished).
+			Group("posts.user_id").Having("COUNT(*) >= ?", badge.Threshold), "posts.user_id"
+	case models.BadgeRuleSigninStreak:
+		q, col = db.Model(&models.SignIn{}).Where("streak_achieved >= ?", badge.Threshold).Group("sign_ins.user_id"), "sign_ins.user_id"
+	case models.BadgeRuleMembershipYears:
+		q, col = db.Model(&models.User{}).Where("created_at <= ?", time.Now().AddDate(-badge.Threshold, 0, 0)), "users.id"
+	default:
+		// manual badges
+		return
+	}
+	if userID != 0 {
+		q = q.Where(col+" = ?", userID)
+	}
+	q = q.Where("NOT EXISTS (SELECT 1 FROM user_badges ub WHERE ub.user_id = "+col+" AND ub.badge_id = ?)", badge.ID)
+	var ids []uint
+	if err := q.Pluck(col, &ids).Error; err != nil {
+		if utils.Sugar != nil {
+			utils.Sugar.Warnf("badge %s eligibility check failed: %v", badge.Slug, err)
+		}
+		return
+	}
+	for _, id := range ids {
+		res := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.UserBadge{UserID: id, BadgeID: badge.ID})
+		if res.Error != nil || res.RowsAffected == 0 {
+			continue
+		}
+		var user models.User
+		if db.Select("id", "username").First(&user, id).Error == nil {
+			notifyBadge(db, user, badge)
+			invalidateUserPublic(user)
+		}
+	}
+}
+
+func notifyBadge(db *gorm.DB, user models.User, badge models.Badge) {
+	notify(db, user.ID, models.NotificationBadgeAwarded, "你获得了徽章「"+badge.Name+"」", badge.Description, "/personal/"+user.Username)
+}
+
+// invalidateUserPublic drops the cached public profile of user, by id and by username.
+func invalidateUserPublic(user models.User) {
+	utils.InvalidateByPrefix("cache:user:public:" + strconv.Itoa(int(user.ID)))
+	utils.InvalidateByPrefix("cache:user:public:uname:" + user.Username)
+}
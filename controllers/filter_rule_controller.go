@@ -0,0 +1,216 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/cppla/aibbs/models"
+	"github.com/cppla/aibbs/utils"
+)
+
+// FilterRuleController manages the prohibited word list.
+type FilterRuleController struct {
+	db *gorm.DB
+}
+
+// NewFilterRuleController builds a FilterRuleController.
+func NewFilterRuleController(db *gorm.DB) *FilterRuleController {
+	return &FilterRuleController{db: db}
+}
+
+type filterRuleRequest struct {
+	Pattern *string  `json:"pattern"`
+	Kind    *string  `json:"kind"`
+	Action  *string  `json:"action"`
+	Fields  []string `json:"fields"` // empty means all fields
+	Enabled *bool    `json:"enabled"`
+	Note    *string  `json:"note"`
+}
+
+// ListFilterRules returns rules filterable by ?kind=, ?action= and ?q=.
+func (f *FilterRuleController) ListFilterRules(ctx *gin.Context) {
+	page, pageSize := parsePagination(ctx.Query("page"), ctx.Query("page_size"))
+	q := f.db.Model(&models.FilterRule{})
+	if v := strings.TrimSpace(ctx.Query("kind")); v != "" {
+		q = q.Where("kind = ?", v)
+	}
+	if v := strings.TrimSpace(ctx.Query("action")); v != "" {
+		q = q.Where("action = ?", v)
+	}
+	if v := strings.TrimSpace(ctx.Query("q")); v != "" {
+		q = q.Where("pattern LIKE ? OR note LIKE ?", "%"+v+"%", "%"+v+"%")
+	}
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50160, "failed to count filter rules")
+		return
+	}
+	var items []models.FilterRule
+	if err := q.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&items).Error; err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50161, "failed to list filter rules")
+		return
+	}
+	utils.Success(ctx, gin.H{
+		"items": items,
+		"pagination": gin.H{
+			"page":        page,
+			"page_size":   pageSize,
+			"total":       total,
+			"total_pages": int((total + int64(pageSize) - 1) / int64(pageSize)),
+		},
+	})
+}
+
+// CreateFilterRule adds a word, regex or pinyin rule.
+func (f *FilterRuleController) CreateFilterRule(ctx *gin.Context) {
+	var req filterRuleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil || req.Pattern == nil {
+		utils.Error(ctx, http.StatusBadRequest, 40011, "invalid request payload")
+		return
+	}
+	rule := models.FilterRule{Kind: models.FilterKindLiteral, Action: models.FilterActionBlock, Enabled: true}
+	rule.CreatedBy, _ = getUserID(ctx)
+	if !applyFilterRuleRequest(ctx, &rule, req) {
+		return
+	}
+	if err := f.db.Create(&rule).Error; err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50162, "failed to create filter rule")
+		return
+	}
+	utils.ContentFiltersChanged(f.db)
+	utils.Success(ctx, gin.H{"rule": rule})
+}
+
+// UpdateFilterRule edits a rule; omitted fields are left unchanged.
+func (f *FilterRuleController) UpdateFilterRule(ctx *gin.Context) {
+	var rule models.FilterRule
+	if err := f.db.First(&rule, ctx.Param("id")).Error; err != nil {
+		utils.Error(ctx, http.StatusNotFound, 40460, "filter rule not found")
+		return
+	}
+	var req filterRuleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.Error(ctx, http.StatusBadRequest, 40011, "invalid request payload")
+		return
+	}
+	if !applyFilterRuleRequest(ctx, &rule, req) {
+		return
+	}
+	if err := f.db.Model(&rule).Select("pattern", "kind", "action", "fields", "enabled", "note").Updates(&rule).Error; err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50163, "failed to update filter rule")
+		return
+	}
+	utils.ContentFiltersChanged(f.db)
+	utils.Success(ctx, gin.H{"rule": rule})
+}
+
+// DeleteFilterRule removes a rule.
+func (f *FilterRuleController) DeleteFilterRule(ctx *gin.Context) {
+	res := f.db.Delete(&models.FilterRule{}, ctx.Param("id"))
+	if res.Error != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50164, "failed to delete filter rule")
+		return
+	}
+	if res.RowsAffected == 0 {
+		utils.Error(ctx, http.StatusNotFound, 40460, "filter rule not found")
+		return
+	}
+	utils.ContentFiltersChanged(f.db)
+	utils.Success(ctx, gin.H{"message": "filter rule deleted"})
+}
+
+// TestFilter runs the current rules over a sample text without saving anything.
+func (f *FilterRuleController) TestFilter(ctx *gin.Context) {
+	var req struct {
+		Field string `json:"field"`
+		Text  string `json:"text" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.Error(ctx, http.StatusBadRequest, 40011, "invalid request payload")
+		return
+	}
+	field := strings.TrimSpace(req.Field)
+	if field == "" {
+		field = models.FilterFieldContent
+	}
+	res := utils.FilterText(f.db, field, req.Text)
+	utils.Success(ctx, gin.H{"action": res.Action, "text": res.Text, "matches": res.Matches})
+}
+
+// applyFilterRuleRequest validates and copies the provided fields onto rule.
+func applyFilterRuleRequest(ctx *gin.Context, rule *models.FilterRule, req filterRuleRequest) bool {
+	if req.Pattern != nil {
+		rule.Pattern = strings.TrimSpace(*req.Pattern)
+	}
+	if req.Kind != nil {
+		rule.Kind = strings.ToLower(strings.TrimSpace(*req.Kind))
+	}
+	if req.Action != nil {
+		rule.Action = strings.ToLower(strings.TrimSpace(*req.Action))
+	}
+	if req.Fields != nil {
+		fields := make([]string, 0, len(req.Fields))
+		for _, v := range req.Fields {
+			v = strings.ToLower(strings.TrimSpace(v))
+			if !containsString(models.FilterFields, v) {
+				utils.Error(ctx, http.StatusBadRequest, 40012, "invalid field: "+v)
+				return false
+			}
+			if !containsString(fields, v) {
+				fields = append(fields, v)
+			}
+		}
+		rule.Fields = strings.Join(fields, ",")
+	}
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+	if req.Note != nil {
+		rule.Note = strings.TrimSpace(*req.Note)
+	}
+
+	if rule.Pattern == "" || len([]rune(rule.Pattern)) > 255 {
+		utils.Error(ctx, http.StatusBadRequest, 40013, "pattern must be 1-255 characters")
+		return false
+	}
+	if rule.Kind != models.FilterKindLiteral && rule.Kind != models.FilterKindRegex && rule.Kind != models.FilterKindPinyin {
+		utils.Error(ctx, http.StatusBadRequest, 40012, "kind must be literal, regex or pinyin")
+		return false
+	}
+	if rule.Action != models.FilterActionBlock && rule.Action != models.FilterActionReplace && rule.Action != models.FilterActionReview {
+		utils.Error(ctx, http.StatusBadRequest, 40012, "action must be block, replace or review")
+		return false
+	}
+	if err := utils.ValidateFilterPattern(rule.Kind, rule.Pattern); err != nil {
+		utils.Error(ctx, http.StatusBadRequest, 40014, "invalid pattern: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// filterInput runs the content filter over one submitted field. It returns the text with
+// replace-rule matches masked and, when a review rule matched, the matches that hold the item
+// for review. When a block rule matches it writes a 400 response and ok is false.
+func filterInput(ctx *gin.Context, db *gorm.DB, field, text string) (out string, matches []utils.FilterMatch, ok bool) {
+	res := utils.FilterText(db, field, text)
+	if res.Action == models.FilterActionBlock {
+		utils.Respond(ctx, http.StatusBadRequest, 40010, "content contains prohibited words", gin.H{"field": field})
+		return "", nil, false
+	}
+	if res.Action == models.FilterActionReview {
+		matches = res.Matches
+	}
+	return res.Text, matches, true
+}
+
+// filterDetail summarizes the rules that held an item, for the moderation queue.
+func filterDetail(matches []utils.FilterMatch) string {
+	parts := make([]string, 0, len(matches))
+	for _, m := range matches {
+		parts = append(parts, m.Pattern)
+	}
+	return "content filter: " + strings.Join(parts, ", ")
+}
@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/cppla/aibbs/middleware"
+	"github.com/cppla/aibbs/models"
+	"github.com/cppla/aibbs/utils"
+)
+
+var errAlreadyPurchased = errors.New("hidden content already unlocked")
+
+// applyHiddenContent renders post's hidden blocks for the current viewer and returns what the client
+// needs to offer unlocking, or nil when the post has no hidden blocks. The author and users who may
+// delete the post see everything; reply blocks open to commenters and points blocks to buyers.
+func applyHiddenContent(ctx *gin.Context, db *gorm.DB, post *models.Post) gin.H {
+	blocks, price := utils.HiddenSummary(post.Content)
+	if blocks == 0 {
+		return nil
+	}
+	uid, _ := getUserID(ctx)
+	full := uid != 0 && (uid == post.UserID || middleware.HasPermission(ctx, models.PermPostDeleteAny, post.Category))
+	replied, purchased := full, full
+	if !full && uid != 0 {
+		var n int64
+		db.Model(&models.Comment{}).Where("post_id = ? AND user_id = ? AND status = ? AND hidden = ?", post.ID, uid, models.StatusPublished, false).Count(&n)
+		replied = n > 0
+		if price > 0 {
+			db.Model(&models.HiddenContentPurchase{}).Where("post_id = ? AND user_id = ?", post.ID, uid).Count(&n)
+			purchased = n > 0
+		}
+	}
+	rendered := utils.RenderHiddenContent(post.Content, replied, purchased)
+	locked := rendered != post.Content
+	post.Content = rendered
+	return gin.H{
+		"blocks":    blocks,
+		"locked":    locked,
+		"price":     price,
+		"replied":   replied,
+		"purchased": purchased,
+	}
+}
+
+// lockHiddenContent hides every hidden block of listed posts; lists are shared and cached, so they
+// never show hidden content.
+func lockHiddenContent(posts []models.Post) {
+	for i := range posts {
+		posts[i].Content = utils.RenderHiddenContent(posts[i].Content, false, false)
+	}
+}
+
+// PurchaseHiddenContent pays the post's hidden content price to its author and unlocks the post's
+// points blocks for the current user.
+func (p *PostController) PurchaseHiddenContent(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		utils.Error(ctx, http.StatusUnauthorized, 40110, "unauthorized")
+		return
+	}
+	if sanctionBlocks(ctx, p.db, models.SanctionSuspend) {
+		return
+	}
+	var post models.Post
+	if err := p.db.Select("id", "user_id", "content", "status", "hidden").
+		Where("status = ? AND hidden = ?", models.StatusPublished, false).First(&post, ctx.Param("id")).Error; err != nil {
+		utils.Error(ctx, http.StatusNotFound, 40401, "post not found")
+		return
+	}
+	_, price := utils.HiddenSummary(post.Content)
+	if price <= 0 {
+		utils.Error(ctx, http.StatusBadRequest, 40049, "post has no points-gated content")
+		return
+	}
+	if post.UserID == userID {
+		utils.Error(ctx, http.StatusConflict, 40967, "authors can always see their own content")
+		return
+	}
+	var balance int
+	err := utils.Transaction(p.db, func(tx *gorm.DB) error {
+		var n int64
+		if err := tx.Model(&models.HiddenContentPurchase{}).Where("post_id = ? AND user_id = ?", post.ID, userID).Count(&n).Error; err != nil {
+			return err
+		}
+		if n > 0 {
+			return errAlreadyPurchased
+		}
+		var (
+			txnID string
+			err   error
+		)
+		balance, txnID, err = utils.TransferPoints(tx, utils.PointsTransfer{
+			FromID:  userID,
+			ToID:    post.UserID,
+			Amount:  price,
+			Reason:  models.PointsReasonHiddenContent,
+			RefType: models.ReportTargetPost,
+			RefID:   post.ID,
+		})
+		if err != nil {
+			return err
+		}
+		// The unique index turns a concurrent second purchase into an error, rolling back its transfer
+		return tx.Create(&models.HiddenContentPurchase{PostID: post.ID, UserID: userID, Price: price, TxnID: txnID}).Error
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, errAlreadyPurchased):
+			utils.Error(ctx, http.StatusConflict, 40967, err.Error())
+		case errors.Is(err, utils.ErrInsufficientPoints):
+			utils.Error(ctx, http.StatusConflict, 40963, "not enough points")
+		default:
+			utils.Error(ctx, http.StatusInternalServerError, 50196, "failed to unlock hidden content")
+		}
+		return
+	}
+	notify(p.db, post.UserID, models.NotificationHiddenPurchased, "有人支付积分查看了你的隐藏内容",
+		"获得 "+strconv.Itoa(price)+" 积分", "/post-"+strconv.Itoa(int(post.ID))+"-1")
+	utils.Success(ctx, gin.H{"price": price, "balance": balance})
+}
@@ -0,0 +1,214 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+
+	"github.com/cppla/aibbs/models"
+	"github.com/cppla/aibbs/utils"
+)
+
+const (
+	linkStatePrefix = "link:"
+	// linkNonceCookie binds a link flow to the browser that started it: the callback must come with
+	// the nonce stored in the state, so a victim cannot be made to finish someone else's link.
+	linkNonceCookie = "aibbs_link_nonce"
+	linkStateTTL    = 10 * time.Minute
+)
+
+var (
+	errIdentityTaken   = errors.New("identity already linked to another account")
+	errLastLoginMethod = errors.New("cannot remove the last login method")
+	errAccountDeleted  = errors.New("identity belongs to a deleted account")
+)
+
+// ListIdentities returns the login identities linked to the current account.
+func (a *AuthController) ListIdentities(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		utils.Error(ctx, http.StatusUnauthorized, 40110, "unauthorized")
+		return
+	}
+	var user models.User
+	if err := a.db.First(&user, userID).Error; err != nil {
+		utils.Error(ctx, http.StatusNotFound, 40401, "user not found")
+		return
+	}
+	var identities []models.UserIdentity
+	if err := a.db.Where("user_id = ?", userID).Order("created_at ASC").Find(&identities).Error; err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50080, "failed to load identities")
+		return
+	}
+	utils.Success(ctx, gin.H{
+		"items":        identities,
+		"has_password": user.PasswordHash != "",
+	})
+}
+
+// OAuthLink starts an OAuth flow whose callback links the provider to the current account.
+func (a *AuthController) OAuthLink(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		utils.Error(ctx, http.StatusUnauthorized, 40110, "unauthorized")
+		return
+	}
+	cfg, _, err := a.oauthConfig(ctx.Request.Context(), ctx.Param("provider"))
+	if err != nil {
+		utils.Error(ctx, http.StatusBadRequest, 40004, err.Error())
+		return
+	}
+
+	state, nonce := uuid.NewString(), uuid.NewString()
+	utils.SaveStateValue(state, linkStatePrefix+strconv.FormatUint(uint64(userID), 10)+":"+nonce, linkStateTTL)
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie(linkNonceCookie, nonce, int(linkStateTTL.Seconds()), "/", "", ctx.Request.TLS != nil, true)
+
+	url := cfg.AuthCodeURL(state, oauth2.AccessTypeOffline)
+	utils.Success(ctx, gin.H{"authorization_url": url, "state": state})
+}
+
+// LinkTelegram links a Telegram account (login widget payload) to the current account.
+func (a *AuthController) LinkTelegram(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		utils.Error(ctx, http.StatusUnauthorized, 40110, "unauthorized")
+		return
+	}
+	var req telegramLoginRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.Error(ctx, http.StatusBadRequest, 40008, "invalid request payload")
+		return
+	}
+	userInfo, ok := a.telegramIdentity(ctx, req)
+	if !ok {
+		return
+	}
+	a.respondLinkIdentity(ctx, userID, "telegram", userInfo)
+}
+
+// UnlinkIdentity removes a linked identity, refusing to remove the account's last login method.
+func (a *AuthController) UnlinkIdentity(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		utils.Error(ctx, http.StatusUnauthorized, 40110, "unauthorized")
+		return
+	}
+	identityID := strings.TrimSpace(ctx.Param("id"))
+
+	err := a.db.Transaction(func(tx *gorm.DB) error {
+		var identity models.UserIdentity
+		if err := tx.Where("id = ? AND user_id = ?", identityID, userID).First(&identity).Error; err != nil {
+			return err
+		}
+		var user models.User
+		if err := tx.First(&user, userID).Error; err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&models.UserIdentity{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count <= 1 && user.PasswordHash == "" {
+			return errLastLoginMethod
+		}
+		if err := tx.Delete(&identity).Error; err != nil {
+			return err
+		}
+		// Clear legacy columns too, otherwise the fallback lookup would silently re-link them
+		if user.Provider == identity.Provider && user.ProviderID == identity.ProviderID {
+			return tx.Model(&user).Updates(map[string]interface{}{"provider": "", "provider_id": ""}).Error
+		}
+		return nil
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			utils.Error(ctx, http.StatusNotFound, 40480, "identity not found")
+		case errors.Is(err, errLastLoginMethod):
+			utils.Error(ctx, http.StatusBadRequest, 40081, "至少需要保留一种登录方式，请先设置密码或绑定其他账号")
+		default:
+			utils.Error(ctx, http.StatusInternalServerError, 50081, "failed to unlink identity")
+		}
+		return
+	}
+	utils.Success(ctx, gin.H{"message": "identity unlinked"})
+}
+
+// respondLinkIdentity links the identity to userID and writes the API response.
+func (a *AuthController) respondLinkIdentity(ctx *gin.Context, userID uint, provider string, data *oauthUser) {
+	identity, err := a.linkIdentity(userID, provider, data)
+	if err != nil {
+		if errors.Is(err, errIdentityTaken) {
+			utils.Error(ctx, http.StatusConflict, 40980, "该第三方账号已绑定其他用户")
+			return
+		}
+		utils.Error(ctx, http.StatusInternalServerError, 50082, "failed to link identity")
+		return
+	}
+	utils.Success(ctx, gin.H{"identity": identity})
+}
+
+func (a *AuthController) linkIdentity(userID uint, provider string, data *oauthUser) (*models.UserIdentity, error) {
+	var identity models.UserIdentity
+	err := a.db.Where("provider = ? AND provider_id = ?", provider, data.ID).First(&identity).Error
+	if err == nil {
+		if identity.UserID != userID {
+			return nil, errIdentityTaken
+		}
+		return &identity, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	// An un-migrated legacy account may still own this provider id through users.provider_id
+	var legacy models.User
+	if err := a.db.Where("provider = ? AND provider_id = ?", provider, data.ID).First(&legacy).Error; err == nil && legacy.ID != userID {
+		return nil, errIdentityTaken
+	}
+
+	identity = models.UserIdentity{
+		UserID:     userID,
+		Provider:   provider,
+		ProviderID: data.ID,
+		Email:      strings.TrimSpace(data.Email),
+	}
+	if err := a.db.Create(&identity).Error; err != nil {
+		// A concurrent link of the same identity won the unique index
+		if utils.IsDuplicateKey(err) {
+			return nil, errIdentityTaken
+		}
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// parseLinkState reports whether a state payload was issued by OAuthLink and returns the account
+// being linked and the browser nonce the callback must present.
+func parseLinkState(value string) (uint, string, bool) {
+	if !strings.HasPrefix(value, linkStatePrefix) {
+		return 0, "", false
+	}
+	idPart, nonce, _ := strings.Cut(strings.TrimPrefix(value, linkStatePrefix), ":")
+	id, err := strconv.ParseUint(idPart, 10, 64)
+	if err != nil || id == 0 {
+		return 0, "", false
+	}
+	return uint(id), nonce, true
+}
+
+// checkLinkNonce verifies and clears the link nonce cookie of the browser finishing the callback.
+func checkLinkNonce(ctx *gin.Context, nonce string) bool {
+	cookie, _ := ctx.Cookie(linkNonceCookie)
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie(linkNonceCookie, "", -1, "/", "", ctx.Request.TLS != nil, true)
+	return nonce != "" && subtle.ConstantTimeCompare([]byte(cookie), []byte(nonce)) == 1
+}
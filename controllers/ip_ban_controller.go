@@ -0,0 +1,165 @@
+package controllers
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/cppla/aibbs/models"
+	"github.com/cppla/aibbs/utils"
+)
+
+// IPBanController manages the persistent IP / CIDR ban list.
+type IPBanController struct {
+	db *gorm.DB
+}
+
+// NewIPBanController builds an IPBanController.
+func NewIPBanController(db *gorm.DB) *IPBanController {
+	return &IPBanController{db: db}
+}
+
+type ipBanRequest struct {
+	CIDR          string  `json:"cidr"`
+	Scope         *string `json:"scope"`
+	Note          *string `json:"note"`
+	DurationHours *int    `json:"duration_hours"` // <= 0 means permanent
+}
+
+// ListIPBans returns bans, optionally including expired ones (?all=1) or filtered by ?q=.
+func (b *IPBanController) ListIPBans(ctx *gin.Context) {
+	page, pageSize := parsePagination(ctx.Query("page"), ctx.Query("page_size"))
+	q := b.db.Model(&models.IPBan{})
+	if ctx.Query("all") != "1" {
+		q = q.Where("expires_at IS NULL OR expires_at > ?", time.Now())
+	}
+	if v := strings.TrimSpace(ctx.Query("q")); v != "" {
+		q = q.Where("cidr LIKE ? OR note LIKE ?", "%"+v+"%", "%"+v+"%")
+	}
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50150, "failed to count ip bans")
+		return
+	}
+	var items []models.IPBan
+	if err := q.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&items).Error; err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50151, "failed to list ip bans")
+		return
+	}
+	utils.Success(ctx, gin.H{
+		"items": items,
+		"pagination": gin.H{
+			"page":        page,
+			"page_size":   pageSize,
+			"total":       total,
+			"total_pages": int((total + int64(pageSize) - 1) / int64(pageSize)),
+		},
+	})
+}
+
+// CreateIPBan adds an address or CIDR range to the ban list.
+func (b *IPBanController) CreateIPBan(ctx *gin.Context) {
+	var req ipBanRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.Error(ctx, http.StatusBadRequest, 40043, "invalid request payload")
+		return
+	}
+	cidr, err := utils.NormalizeCIDR(req.CIDR)
+	if err != nil {
+		utils.Error(ctx, http.StatusBadRequest, 40044, err.Error())
+		return
+	}
+	ban := models.IPBan{CIDR: cidr, Scope: models.IPBanScopeAll}
+	ban.CreatedBy, _ = getUserID(ctx)
+	if !applyIPBanRequest(ctx, &ban, req) {
+		return
+	}
+
+	var existing int64
+	b.db.Model(&models.IPBan{}).Where("cidr = ?", cidr).Count(&existing)
+	if existing > 0 {
+		utils.Error(ctx, http.StatusConflict, 40950, "ip ban already exists")
+		return
+	}
+	if err := b.db.Create(&ban).Error; err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50152, "failed to create ip ban")
+		return
+	}
+	utils.IPBansChanged(b.db)
+	if utils.Sugar != nil {
+		utils.Sugar.Infof("ip ban created cidr=%s scope=%s by=%d", ban.CIDR, ban.Scope, ban.CreatedBy)
+	}
+	utils.Success(ctx, gin.H{"ban": ban})
+}
+
+// UpdateIPBan changes scope, note or expiry of a ban.
+func (b *IPBanController) UpdateIPBan(ctx *gin.Context) {
+	var ban models.IPBan
+	if err := b.db.First(&ban, ctx.Param("id")).Error; err != nil {
+		utils.Error(ctx, http.StatusNotFound, 40450, "ip ban not found")
+		return
+	}
+	var req ipBanRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.Error(ctx, http.StatusBadRequest, 40043, "invalid request payload")
+		return
+	}
+	if !applyIPBanRequest(ctx, &ban, req) {
+		return
+	}
+	if err := b.db.Model(&ban).Select("scope", "note", "expires_at").Updates(&ban).Error; err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50153, "failed to update ip ban")
+		return
+	}
+	utils.IPBansChanged(b.db)
+	utils.Success(ctx, gin.H{"ban": ban})
+}
+
+// DeleteIPBan lifts a ban.
+func (b *IPBanController) DeleteIPBan(ctx *gin.Context) {
+	res := b.db.Delete(&models.IPBan{}, ctx.Param("id"))
+	if res.Error != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50154, "failed to delete ip ban")
+		return
+	}
+	if res.RowsAffected == 0 {
+		utils.Error(ctx, http.StatusNotFound, 40450, "ip ban not found")
+		return
+	}
+	utils.IPBansChanged(b.db)
+	utils.Success(ctx, gin.H{"message": "ip ban deleted"})
+}
+
+// applyIPBanRequest copies optional fields onto ban and refuses bans covering the caller's own IP.
+func applyIPBanRequest(ctx *gin.Context, ban *models.IPBan, req ipBanRequest) bool {
+	if req.Scope != nil {
+		scope := strings.ToLower(strings.TrimSpace(*req.Scope))
+		if scope != models.IPBanScopeAll && scope != models.IPBanScopeWrite {
+			utils.Error(ctx, http.StatusBadRequest, 40045, "scope must be all or write")
+			return false
+		}
+		ban.Scope = scope
+	}
+	if req.Note != nil {
+		ban.Note = strings.TrimSpace(*req.Note)
+	}
+	if req.DurationHours != nil {
+		ban.ExpiresAt = nil
+		if *req.DurationHours > 0 {
+			expires := time.Now().Add(time.Duration(*req.DurationHours) * time.Hour)
+			ban.ExpiresAt = &expires
+		}
+	}
+	// Guard against an admin locking themselves out
+	if _, network, err := net.ParseCIDR(ban.CIDR); err == nil {
+		if ip := net.ParseIP(ctx.ClientIP()); ip != nil && network.Contains(ip) {
+			utils.Error(ctx, http.StatusBadRequest, 40046, "ban would include your own ip")
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/cppla/aibbs/models"
+	"github.com/cppla/aibbs/utils"
+)
+
+// LeaderboardController serves the user rankings kept in Redis.
+type LeaderboardController struct {
+	db *gorm.DB
+}
+
+// NewLeaderboardController builds a LeaderboardController.
+func NewLeaderboardController(db *gorm.DB) *LeaderboardController {
+	return &LeaderboardController{db: db}
+}
+
+// GetLeaderboard returns the top users of a kind (points, streak, posts) for
+// ?window=all|month|week (default all); ?limit= caps the list (default 20, max 100).
+// Signed-in callers also get their own rank as "me".
+func (lc *LeaderboardController) GetLeaderboard(ctx *gin.Context) {
+	kind := ctx.Param("kind")
+	window := ctx.DefaultQuery("window", utils.LeaderboardAllTime)
+	if !containsString(utils.LeaderboardKinds, kind) || !containsString(utils.LeaderboardWindows, window) {
+		utils.Error(ctx, http.StatusBadRequest, 40038, "unknown leaderboard kind or window")
+		return
+	}
+	limit, _ := strconv.Atoi(ctx.Query("limit"))
+	if limit <= 0 {
+		limit = 20
+	} else if limit > 100 {
+		limit = 100
+	}
+	entries, err := utils.TopLeaderboard(kind, window, limit)
+	if err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50037, "failed to load leaderboard")
+		return
+	}
+
+	ids := make([]uint, 0, len(entries))
+	for _, e := range entries {
+		ids = append(ids, e.UserID)
+	}
+	var users []models.User
+	if len(ids) > 0 {
+		if err := lc.db.Select("id", "username", "avatar_url").Where("id IN ?", ids).Find(&users).Error; err != nil {
+			utils.Error(ctx, http.StatusInternalServerError, 50037, "failed to load leaderboard")
+			return
+		}
+	}
+	byID := make(map[uint]models.User, len(users))
+	for _, u := range users {
+		byID[u.ID] = u
+	}
+	items := make([]gin.H, 0, len(entries))
+	for _, e := range entries {
+		u, ok := byID[e.UserID]
+		if !ok {
+			// deleted accounts drop out until the next rebuild
+			continue
+		}
+		items = append(items, gin.H{
+			"rank":       e.Rank,
+			"user_id":    e.UserID,
+			"username":   u.Username,
+			"avatar_url": u.AvatarURL,
+			"score":      e.Score,
+		})
+	}
+
+	period, _ := utils.LeaderboardPeriod(window, time.Now())
+	resp := gin.H{"kind": kind, "window": window, "period": period, "items": items}
+	if userID, ok := getUserID(ctx); ok {
+		rank, score := utils.LeaderboardRank(kind, window, userID)
+		resp["me"] = gin.H{"rank": rank, "score": score}
+	}
+	utils.Success(ctx, resp)
+}
+
+// countPost adds delta to the author's post leaderboards when the post is a published, non-stub post.
+func countPost(post *models.Post, delta int) {
+	if post.Status == models.StatusPublished && post.MovedTo == 0 {
+		utils.LeaderboardPostCounted(post.UserID, post.CreatedAt, delta)
+	}
+}
+
+// StartLeaderboardRebuildJob periodically recomputes the leaderboards from MySQL.
+func StartLeaderboardRebuildJob(db *gorm.DB, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := utils.RebuildLeaderboards(db); err != nil && utils.Sugar != nil {
+				utils.Sugar.Warnf("leaderboard rebuild failed: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/cppla/aibbs/models"
+	"github.com/cppla/aibbs/utils"
+)
+
+const (
+	loginOutcomeSuccess = "success"
+	loginOutcomeFailure = "failure"
+	loginOutcomeLocked  = "locked"
+	loginOutcomeBanned  = "banned"
+)
+
+// recordLoginEvent stores a login attempt asynchronously and, for successful logins from a
+// never-seen country or device, emails the account owner. Attempts against unknown usernames
+// have no owner to show them to and are not stored.
+func recordLoginEvent(db *gorm.DB, ctx *gin.Context, user *models.User, method, outcome string) {
+	if user == nil || user.ID == 0 {
+		return
+	}
+	u := *user
+	ip := ctx.ClientIP()
+	ua := ctx.Request.UserAgent()
+	if len(ua) > 512 {
+		ua = ua[:512]
+	}
+	go func() {
+		c, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		country, _ := utils.GetIPCountry(c, ip)
+
+		event := models.LoginEvent{
+			UserID:     u.ID,
+			Method:     method,
+			IP:         ip,
+			Country:    country,
+			UserAgent:  ua,
+			DeviceHash: deviceHash(ua),
+			Outcome:    outcome,
+		}
+
+		newCountry, newDevice := false, false
+		if outcome == loginOutcomeSuccess {
+			var seen int64
+			if err := db.Model(&models.LoginEvent{}).Where("user_id = ? AND outcome = ?", u.ID, loginOutcomeSuccess).Count(&seen).Error; err == nil && seen > 0 {
+				var n int64
+				if country != "" {
+					db.Model(&models.LoginEvent{}).Where("user_id = ? AND outcome = ? AND country = ?", u.ID, loginOutcomeSuccess, country).Count(&n)
+					newCountry = n == 0
+				}
+				db.Model(&models.LoginEvent{}).Where("user_id = ? AND outcome = ? AND device_hash = ?", u.ID, loginOutcomeSuccess, event.DeviceHash).Count(&n)
+				newDevice = n == 0
+			}
+		}
+
+		if err := db.Create(&event).Error; err != nil {
+			if utils.Sugar != nil {
+				utils.Sugar.Warnf("record login event failed user=%d err=%v", u.ID, err)
+			}
+			return
+		}
+		if (newCountry || newDevice) && strings.TrimSpace(u.Email) != "" {
+			subject := "AIBBS 新设备登录提醒"
+			body := fmt.Sprintf("您的账号 %s 于 %s 在新的%s登录：\n方式：%s\nIP：%s\n国家/地区：%s\n设备：%s\n如非本人操作，请尽快修改密码并检查已绑定的登录方式。",
+				u.Username, event.CreatedAt.Format("2006-01-02 15:04:05"), newLoginWhat(newCountry, newDevice),
+				method, ip, fallback(country, "未知"), fallback(ua, "未知"))
+			if err := utils.SendMail(u.Email, subject, body); err != nil && utils.Sugar != nil {
+				utils.Sugar.Warnf("new device mail failed user=%d err=%v", u.ID, err)
+			}
+		}
+	}()
+}
+
+func newLoginWhat(newCountry, newDevice bool) string {
+	switch {
+	case newCountry && newDevice:
+		return "国家/地区和设备上"
+	case newCountry:
+		return "国家/地区"
+	default:
+		return "设备上"
+	}
+}
+
+func deviceHash(userAgent string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(userAgent)))
+	return hex.EncodeToString(sum[:])
+}
+
+// LoginHistory returns the current user's recent login attempts.
+func (a *AuthController) LoginHistory(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		utils.Error(ctx, http.StatusUnauthorized, 40110, "unauthorized")
+		return
+	}
+	page, pageSize := parsePagination(ctx.Query("page"), ctx.Query("page_size"))
+
+	var total int64
+	var events []models.LoginEvent
+	q := a.db.Model(&models.LoginEvent{}).Where("user_id = ?", userID)
+	if err := q.Count(&total).Error; err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50090, "failed to count login history")
+		return
+	}
+	if err := q.Order("created_at DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&events).Error; err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50091, "failed to load login history")
+		return
+	}
+	utils.Success(ctx, gin.H{
+		"items": events,
+		"pagination": gin.H{
+			"page":        page,
+			"page_size":   pageSize,
+			"total":       total,
+			"total_pages": int((total + int64(pageSize) - 1) / int64(pageSize)),
+		},
+	})
+}
@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/cppla/aibbs/config"
+	"github.com/cppla/aibbs/middleware"
+	"github.com/cppla/aibbs/models"
+	"github.com/cppla/aibbs/utils"
+)
+
+// ModerationController exposes the moderation audit log.
+type ModerationController struct {
+	db *gorm.DB
+}
+
+// NewModerationController builds a ModerationController.
+func NewModerationController(db *gorm.DB) *ModerationController {
+	return &ModerationController{db: db}
+}
+
+// SearchActions lets moderators filter the full audit log (actor, action, target, time range).
+func (m *ModerationController) SearchActions(ctx *gin.Context) {
+	page, pageSize := parsePagination(ctx.Query("page"), ctx.Query("page_size"))
+	q := m.db.Model(&models.ModerationAction{})
+	if v := strings.TrimSpace(ctx.Query("actor_id")); v != "" {
+		q = q.Where("actor_id = ?", v)
+	}
+	if v := strings.TrimSpace(ctx.Query("actor")); v != "" {
+		q = q.Where("actor_name = ?", v)
+	}
+	if v := strings.TrimSpace(ctx.Query("action")); v != "" {
+		q = q.Where("action = ?", v)
+	}
+	if v := strings.TrimSpace(ctx.Query("target_type")); v != "" {
+		q = q.Where("target_type = ?", v)
+	}
+	if v := strings.TrimSpace(ctx.Query("target_id")); v != "" {
+		q = q.Where("target_id = ?", v)
+	}
+	if v := strings.TrimSpace(ctx.Query("q")); v != "" {
+		q = q.Where("reason LIKE ?", "%"+v+"%")
+	}
+	if t, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(ctx.Query("from")), time.Local); err == nil {
+		q = q.Where("created_at >= ?", t)
+	}
+	if t, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(ctx.Query("to")), time.Local); err == nil {
+		q = q.Where("created_at < ?", t.AddDate(0, 0, 1))
+	}
+
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50120, "failed to count moderation actions")
+		return
+	}
+	var items []models.ModerationAction
+	if err := q.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&items).Error; err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50121, "failed to list moderation actions")
+		return
+	}
+	utils.Success(ctx, gin.H{
+		"items": items,
+		"pagination": gin.H{
+			"page":        page,
+			"page_size":   pageSize,
+			"total":       total,
+			"total_pages": int((total + int64(pageSize) - 1) / int64(pageSize)),
+		},
+	})
+}
+
+// PublicLog is the transparency view: what was done and why, without actor identity, IP or snapshots.
+func (m *ModerationController) PublicLog(ctx *gin.Context) {
+	if !config.Get().ModerationPublicLog {
+		utils.Error(ctx, http.StatusNotFound, 40400, "api route not found")
+		return
+	}
+	page, pageSize := parsePagination(ctx.Query("page"), ctx.Query("page_size"))
+	var total int64
+	if err := m.db.Model(&models.ModerationAction{}).Count(&total).Error; err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50120, "failed to count moderation actions")
+		return
+	}
+	var rows []models.ModerationAction
+	if err := m.db.Select("id", "action", "target_type", "target_id", "reason", "created_at").
+		Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&rows).Error; err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50121, "failed to list moderation actions")
+		return
+	}
+	items := make([]gin.H, 0, len(rows))
+	for _, r := range rows {
+		items = append(items, gin.H{
+			"id":          r.ID,
+			"action":      r.Action,
+			"target_type": r.TargetType,
+			"target_id":   r.TargetID,
+			"reason":      r.Reason,
+			"created_at":  r.CreatedAt,
+		})
+	}
+	utils.Success(ctx, gin.H{
+		"items": items,
+		"pagination": gin.H{
+			"page":        page,
+			"page_size":   pageSize,
+			"total":       total,
+			"total_pages": int((total + int64(pageSize) - 1) / int64(pageSize)),
+		},
+	})
+}
+
+// recordModeration appends an audit entry; before is the target's state prior to the action.
+// Failures are logged rather than surfaced so the moderation action itself still succeeds.
+func recordModeration(ctx *gin.Context, db *gorm.DB, action, targetType string, targetID uint, reason string, before interface{}) {
+	actorID, _ := getUserID(ctx)
+	actorName, _ := ctx.Get(middleware.ContextUsernameKey)
+	entry := models.ModerationAction{
+		ActorID:    actorID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Reason:     reason,
+		IP:         ctx.ClientIP(),
+	}
+	entry.ActorName, _ = actorName.(string)
+	if before != nil {
+		if b, err := json.Marshal(before); err == nil {
+			entry.Snapshot = string(b)
+		}
+	}
+	if err := db.Create(&entry).Error; err != nil && utils.Sugar != nil {
+		utils.Sugar.Errorf("record moderation failed action=%s target=%s:%d err=%v", action, targetType, targetID, err)
+	}
+}
+
+// moderationReason reads the optional reason from ?reason= or a JSON body {"reason": "..."}.
+func moderationReason(ctx *gin.Context) string {
+	if v := strings.TrimSpace(ctx.Query("reason")); v != "" {
+		return v
+	}
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if ctx.Request.ContentLength != 0 {
+		_ = ctx.ShouldBindJSON(&body)
+	}
+	return strings.TrimSpace(body.Reason)
+}
@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/cppla/aibbs/models"
+	"github.com/cppla/aibbs/utils"
+)
+
+// NotificationController serves the current user's in-site notifications.
+type NotificationController struct {
+	db *gorm.DB
+}
+
+// NewNotificationController builds a NotificationController.
+func NewNotificationController(db *gorm.DB) *NotificationController {
+	return &NotificationController{db: db}
+}
+
+// ListNotifications returns the user's notifications, newest first; ?unread=1 limits to unread ones.
+func (n *NotificationController) ListNotifications(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		utils.Error(ctx, http.StatusUnauthorized, 40131, "unauthorized")
+		return
+	}
+	page, pageSize := parsePagination(ctx.Query("page"), ctx.Query("page_size"))
+	q := n.db.Model(&models.Notification{}).Where("user_id = ?", userID)
+	if ctx.Query("unread") == "1" {
+		q = q.Where("read_at IS NULL")
+	}
+	var total, unread int64
+	if err := q.Count(&total).Error; err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50170, "failed to count notifications")
+		return
+	}
+	n.db.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Count(&unread)
+	var items []models.Notification
+	if err := q.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&items).Error; err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50171, "failed to list notifications")
+		return
+	}
+	utils.Success(ctx, gin.H{
+		"items":  items,
+		"unread": unread,
+		"pagination": gin.H{
+			"page":        page,
+			"page_size":   pageSize,
+			"total":       total,
+			"total_pages": int((total + int64(pageSize) - 1) / int64(pageSize)),
+		},
+	})
+}
+
+// MarkNotificationRead marks one notification as read.
+func (n *NotificationController) MarkNotificationRead(ctx *gin.Context) {
+	userID, _ := getUserID(ctx)
+	res := n.db.Model(&models.Notification{}).
+		Where("id = ? AND user_id = ? AND read_at IS NULL", ctx.Param("id"), userID).
+		Update("read_at", time.Now())
+	if res.Error != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50172, "failed to update notification")
+		return
+	}
+	utils.Success(ctx, gin.H{"updated": res.RowsAffected})
+}
+
+// MarkAllNotificationsRead marks every unread notification as read.
+func (n *NotificationController) MarkAllNotificationsRead(ctx *gin.Context) {
+	userID, _ := getUserID(ctx)
+	res := n.db.Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Update("read_at", time.Now())
+	if res.Error != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50172, "failed to update notification")
+		return
+	}
+	utils.Success(ctx, gin.H{"updated": res.RowsAffected})
+}
+
+// notify stores a notification for userID; failures are logged and otherwise ignored.
+func notify(db *gorm.DB, userID uint, kind, title, body, link string) {
+	if userID == 0 {
+		return
+	}
+	if rs := []rune(body); len(rs) > 1000 {
+		body = string(rs[:1000])
+	}
+	n := models.Notification{UserID: userID, Type: kind, Title: title, Body: body, Link: link}
+	if err := db.Create(&n).Error; err != nil && utils.Sugar != nil {
+		utils.Sugar.Warnf("notify user=%d type=%s failed: %v", userID, kind, err)
+	}
+}
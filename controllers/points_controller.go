@@ -0,0 +1,153 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/cppla/aibbs/models"
+	"github.com/cppla/aibbs/utils"
+)
+
+// PointsController serves the points ledger.
+type PointsController struct {
+	db *gorm.DB
+}
+
+// NewPointsController builds a PointsController.
+func NewPointsController(db *gorm.DB) *PointsController {
+	return &PointsController{db: db}
+}
+
+// PointsHistory returns the current user's ledger entries, newest first; ?reason= filters by reason code.
+func (pc *PointsController) PointsHistory(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		utils.Error(ctx, http.StatusUnauthorized, 40132, "unauthorized")
+		return
+	}
+	pc.history(ctx, userID)
+}
+
+// UserPointsHistory returns any user's ledger entries for admins.
+func (pc *PointsController) UserPointsHistory(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		utils.Error(ctx, http.StatusBadRequest, 40031, "invalid user id")
+		return
+	}
+	pc.history(ctx, uint(id))
+}
+
+func (pc *PointsController) history(ctx *gin.Context, userID uint) {
+	var user models.User
+	if err := pc.db.Select("id", "points").First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.Error(ctx, http.StatusNotFound, 40462, "user not found")
+			return
+		}
+		utils.Error(ctx, http.StatusInternalServerError, 50190, "failed to load user")
+		return
+	}
+	page, pageSize := parsePagination(ctx.Query("page"), ctx.Query("page_size"))
+	q := pc.db.Model(&models.PointsTransaction{}).Where("user_id = ?", userID)
+	if reason := strings.TrimSpace(ctx.Query("reason")); reason != "" {
+		q = q.Where("reason = ?", reason)
+	}
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50191, "failed to list points history")
+		return
+	}
+	var items []models.PointsTransaction
+	if err := q.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&items).Error; err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50191, "failed to list points history")
+		return
+	}
+	utils.Success(ctx, gin.H{
+		"balance": user.Points,
+		"items":   items,
+		"pagination": gin.H{
+			"page":        page,
+			"page_size":   pageSize,
+			"total":       total,
+			"total_pages": int((total + int64(pageSize) - 1) / int64(pageSize)),
+		},
+	})
+}
+
+// AdjustPoints credits or debits a user's points with a mandatory note; balances cannot go below zero.
+func (pc *PointsController) AdjustPoints(ctx *gin.Context) {
+	var req struct {
+		Amount int    `json:"amount" binding:"required"`
+		Note   string `json:"note" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Note) == "" {
+		utils.Error(ctx, http.StatusBadRequest, 40033, "amount and note are required")
+		return
+	}
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		utils.Error(ctx, http.StatusBadRequest, 40031, "invalid user id")
+		return
+	}
+	actorID, _ := getUserID(ctx)
+	note := strings.TrimSpace(req.Note)
+	var balance int
+	err = utils.Transaction(pc.db, func(tx *gorm.DB) error {
+		var err error
+		balance, err = utils.ApplyPoints(tx, utils.PointsChange{
+			UserID:  uint(id),
+			Amount:  req.Amount,
+			Reason:  models.PointsReasonAdjust,
+			ActorID: actorID,
+			Note:    note,
+		})
+		return err
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			utils.Error(ctx, http.StatusNotFound, 40462, "user not found")
+		case errors.Is(err, utils.ErrInsufficientPoints):
+			utils.Error(ctx, http.StatusConflict, 40963, "balance cannot go below zero")
+		default:
+			utils.Error(ctx, http.StatusInternalServerError, 50192, "failed to adjust points")
+		}
+		return
+	}
+	recordModeration(ctx, pc.db, "user.points_adjust", "user", uint(id), note, gin.H{"amount": req.Amount, "balance_after": balance})
+	utils.Success(ctx, gin.H{"balance": balance})
+}
+
+// awardPostCreated applies the post_created rule once a post is publicly visible.
+func awardPostCreated(db *gorm.DB, post *models.Post) {
+	if post.Status == models.StatusPublished && !post.Hidden {
+		utils.AwardPointsForEvent(db, utils.PointsEventPostCreated, post.UserID, models.ReportTargetPost, post.ID)
+	}
+}
+
+// awardReplyReceived applies the reply_received rule to the post author for a visible comment by someone else.
+func awardReplyReceived(db *gorm.DB, cmt *models.Comment) {
+	if cmt.Status != models.StatusPublished || cmt.Hidden {
+		return
+	}
+	var post models.Post
+	if err := db.Select("id", "user_id").First(&post, cmt.PostID).Error; err != nil || post.UserID == cmt.UserID {
+		return
+	}
+	utils.AwardPointsForEvent(db, utils.PointsEventReplyReceived, post.UserID, models.ReportTargetComment, cmt.ID)
+}
+
+// settleDeletedPoints claws back the rule rewards earned by deleted content and, when a moderator
+// removed it, applies the content_removed rule to its author.
+func settleDeletedPoints(db *gorm.DB, targetType string, id, ownerID uint, moderated bool) {
+	utils.ClawbackPoints(db, targetType, id)
+	if moderated {
+		utils.AwardPointsForEvent(db, utils.PointsEventContentRemoved, ownerID, targetType, id)
+	}
+}
@@ -16,7 +16,6 @@ import (
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 
-	"github.com/cppla/aibbs/config"
 	"github.com/cppla/aibbs/middleware"
 	"github.com/cppla/aibbs/models"
 	"github.com/cppla/aibbs/utils"
@@ -57,16 +56,12 @@ func (p *PostController) CreatePost(ctx *gin.Context) {
 	if category == "" {
 		category = "综合"
 	}
-	// Validate category
-	validCategories := []string{"综合", "评测", "技术", "线报", "推广", "交易"}
-	isValid := false
-	for _, c := range validCategories {
-		if category == c {
-			isValid = true
-			break
-		}
+	if err := utils.ValidateHiddenContent(content); err != nil {
+		utils.Error(ctx, http.StatusBadRequest, 40048, err.Error())
+		return
 	}
-	if !isValid {
+	// Validate category
+	if !isValidCategory(category) {
 		utils.Error(ctx, http.StatusBadRequest, 40022, "invalid category")
 		return
 	}
@@ -76,6 +71,22 @@ func (p *PostController) CreatePost(ctx *gin.Context) {
 		utils.Error(ctx, http.StatusUnauthorized, 40110, "unauthorized")
 		return
 	}
+	if sanctionBlocks(ctx, p.db, models.SanctionSuspend) {
+		return
+	}
+	title, titleHits, ok := filterInput(ctx, p.db, models.FilterFieldTitle, title)
+	if !ok {
+		return
+	}
+	content, contentHits, ok := filterInput(ctx, p.db, models.FilterFieldContent, content)
+	if !ok {
+		return
+	}
+	held := append(titleHits, contentHits...)
+	spam, ok := spamCheck(ctx, p.db, models.ReportTargetPost, userID, category, title, content)
+	if !ok {
+		return
+	}
 
 	post := models.Post{
 		UserID:      userID,
@@ -83,12 +94,21 @@ func (p *PostController) CreatePost(ctx *gin.Context) {
 		Content:     content,
 		Category:    category,
 		Attachments: req.Attachments,
+		Hidden:      len(held) > 0 || spam.held,
+		Status:      initialStatus(ctx, p.db, userID, category),
+		SpamScore:   spam.score,
 	}
 
 	if err := p.db.Create(&post).Error; err != nil {
 		utils.Error(ctx, http.StatusInternalServerError, 50020, "failed to create post")
 		return
 	}
+	fileHeldReport(p.db, models.ReportTargetPost, post.ID, held, spam)
+	awardPostCreated(p.db, &post)
+	countPost(&post, 1)
+	if post.Status == models.StatusPublished {
+		awardBadges(p.db, post.UserID, models.BadgeRulePostCount)
+	}
 
 	// Invalidate lists cache (homepage and categories)
 	utils.InvalidateByPrefix("cache:posts:list:")
@@ -116,9 +136,11 @@ func (p *PostController) ListPosts(ctx *gin.Context) {
 	var posts []models.Post
 	var total int64
 
-	query := p.db.Preload("User").Order("created_at DESC")
+	query := p.db.Preload("User").Where("hidden = ? AND status = ?", false, models.StatusPublished).Order("pinned DESC, created_at DESC")
 	if search != "" {
-		query = query.Where("title LIKE ? OR content LIKE ?", "%"+search+"%", "%"+search+"%")
+		// Hidden blocks are stripped before matching, otherwise results would reveal what they contain
+		query = query.Where("title LIKE ? OR REGEXP_REPLACE(content, ?, '') LIKE ?",
+			"%"+search+"%", utils.HiddenBlockSQLPattern, "%"+search+"%")
 	}
 	if category != "" {
 		query = query.Where("category = ?", category)
@@ -135,6 +157,7 @@ func (p *PostController) ListPosts(ctx *gin.Context) {
 	}
 
 	// 兼容说明：JSON 中包含 author（关联的 User），前端也兼容 user 字段读取。
+	lockHiddenContent(posts)
 
 	payload := gin.H{
 		"items": posts,
@@ -160,7 +183,13 @@ func (p *PostController) ListPosts(ctx *gin.Context) {
 
 // GetPost returns a single post with comments.
 func (p *PostController) GetPost(ctx *gin.Context) {
-	postID := ctx.Param("id")
+	// The cache key is the canonical id so that invalidating it by post.ID always hits
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		utils.Error(ctx, http.StatusNotFound, 40401, "post not found")
+		return
+	}
+	postID := strconv.FormatUint(id, 10)
 
 	// Try cache first
 	if b, ok := utils.CacheGetBytes("cache:post:detail:" + postID); ok {
@@ -169,7 +198,7 @@ func (p *PostController) GetPost(ctx *gin.Context) {
 	}
 
 	var post models.Post
-	if err := p.db.Preload("User").First(&post, postID).Error; err != nil {
+	if err := p.db.Preload("User").Where("hidden = ?", false).First(&post, postID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			utils.Error(ctx, http.StatusNotFound, 40401, "post not found")
 			return
@@ -177,13 +206,28 @@ func (p *PostController) GetPost(ctx *gin.Context) {
 		utils.Error(ctx, http.StatusInternalServerError, 50023, "failed to load post")
 		return
 	}
+	// Unpublished posts are only shown to their author and to moderators, and never cached
+	published := post.Status == models.StatusPublished
+	if !published {
+		uid, _ := getUserID(ctx)
+		if uid == 0 || (uid != post.UserID && !middleware.HasPermission(ctx, models.PermContentApprove, post.Category)) {
+			utils.Error(ctx, http.StatusNotFound, 40401, "post not found")
+			return
+		}
+	}
 
 	// Load comments separately for better error handling
 	var comments []models.Comment
-	if err := p.db.Model(&post).Association("Comments").Find(&comments); err != nil {
+	// Deleted comments stay in the thread as placeholders so replies keep their context
+	if err := p.db.Unscoped().Where("post_id = ? AND hidden = ? AND status = ?", post.ID, false, models.StatusPublished).Order("created_at ASC, id ASC").Find(&comments).Error; err != nil {
 		// Log the error but don't fail the whole request
 		fmt.Println("Failed to load comments:", err)
 	} else {
+		for i := range comments {
+			if comments[i].DeletedAt.Valid {
+				comments[i].Content, comments[i].DeletedReason = "", ""
+			}
+		}
 		post.Comments = comments
 	}
 
@@ -212,7 +256,16 @@ func (p *PostController) GetPost(ctx *gin.Context) {
 		}
 	}
 
-	payload := gin.H{"post": post}
+	// Posts with hidden blocks are rendered per viewer, so like unpublished posts they are never cached
+	hidden := applyHiddenContent(ctx, p.db, &post)
+	payload := gin.H{"post": post, "tips": tipSummary(p.db, post.ID)}
+	if hidden != nil {
+		payload["hidden_content"] = hidden
+	}
+	if !published || hidden != nil {
+		utils.Success(ctx, payload)
+		return
+	}
 	wrapper := struct {
 		Code    int         `json:"code"`
 		Message string      `json:"message"`
@@ -268,7 +321,7 @@ func (p *PostController) ListUserPosts(ctx *gin.Context) {
 	}
 	var posts []models.Post
 	var total int64
-	q := p.db.Where("user_id = ?", userID).Preload("User").Order("created_at DESC")
+	q := p.db.Where("user_id = ? AND hidden = ? AND status = ?", userID, false, models.StatusPublished).Preload("User").Order("created_at DESC")
 	if err := q.Model(&models.Post{}).Count(&total).Error; err != nil {
 		utils.Error(ctx, http.StatusInternalServerError, 50060, "failed to count user posts")
 		return
@@ -277,6 +330,7 @@ func (p *PostController) ListUserPosts(ctx *gin.Context) {
 		utils.Error(ctx, http.StatusInternalServerError, 50061, "failed to list user posts")
 		return
 	}
+	lockHiddenContent(posts)
 	payload := gin.H{
 		"items": posts,
 		"pagination": gin.H{
@@ -328,17 +382,41 @@ func (p *PostController) CreateComment(ctx *gin.Context) {
 		utils.Error(ctx, http.StatusUnauthorized, 40110, "unauthorized")
 		return
 	}
+	if sanctionBlocks(ctx, p.db, models.SanctionSuspend, models.SanctionMute) {
+		return
+	}
+	if post.Status != models.StatusPublished && post.UserID != userID {
+		utils.Error(ctx, http.StatusNotFound, 40402, "post not found")
+		return
+	}
+	if post.Locked && !middleware.HasPermission(ctx, models.PermPostLock, post.Category) {
+		utils.Error(ctx, http.StatusForbidden, 40332, "post is locked")
+		return
+	}
+	content, held, ok := filterInput(ctx, p.db, models.FilterFieldComment, content)
+	if !ok {
+		return
+	}
+	spam, ok := spamCheck(ctx, p.db, models.ReportTargetComment, userID, post.Category, "", content)
+	if !ok {
+		return
+	}
 
 	comment := models.Comment{
-		PostID:  post.ID,
-		UserID:  userID,
-		Content: content,
+		PostID:    post.ID,
+		UserID:    userID,
+		Content:   content,
+		Hidden:    len(held) > 0 || spam.held,
+		Status:    initialStatus(ctx, p.db, userID, post.Category),
+		SpamScore: spam.score,
 	}
 
 	if err := p.db.Create(&comment).Error; err != nil {
 		utils.Error(ctx, http.StatusInternalServerError, 50025, "failed to create comment")
 		return
 	}
+	fileHeldReport(p.db, models.ReportTargetComment, comment.ID, held, spam)
+	awardReplyReceived(p.db, &comment)
 
 	if err := p.db.Preload("User").First(&comment, comment.ID).Error; err != nil {
 		utils.Error(ctx, http.StatusInternalServerError, 50026, "failed to load comment")
@@ -346,13 +424,13 @@ func (p *PostController) CreateComment(ctx *gin.Context) {
 	}
 
 	// Invalidate post detail cache on new comment
-	utils.InvalidateByPrefix("cache:post:detail:" + strconv.Itoa(int(post.ID)))
+	utils.CacheDelete("cache:post:detail:" + strconv.Itoa(int(post.ID)))
 	// Invalidate user posts cache for post author (comments don't change user list, skip)
 
 	utils.Success(ctx, gin.H{"comment": comment})
 }
 
-// DeleteComment allows the comment owner or admin to delete a comment
+// DeleteComment allows the comment owner, or a moderator of the post's category, to delete a comment
 func (p *PostController) DeleteComment(ctx *gin.Context) {
 	// comment id from path
 	cid := strings.TrimSpace(ctx.Param("commentId"))
@@ -375,16 +453,33 @@ func (p *PostController) DeleteComment(ctx *gin.Context) {
 		utils.Error(ctx, http.StatusUnauthorized, 40120, "unauthorized")
 		return
 	}
-	if cmt.UserID != uid && !isAdmin(ctx) {
-		utils.Error(ctx, http.StatusForbidden, 40320, "you can only delete your own comment")
-		return
+	moderated := cmt.UserID != uid
+	reason := moderationReason(ctx)
+	if moderated {
+		var post models.Post
+		if err := p.db.Select("id", "category").First(&post, cmt.PostID).Error; err != nil && err != gorm.ErrRecordNotFound {
+			utils.Error(ctx, http.StatusInternalServerError, 50070, "failed to load comment")
+			return
+		}
+		if !middleware.HasPermission(ctx, models.PermCommentDeleteAny, post.Category) {
+			utils.Error(ctx, http.StatusForbidden, 40320, "you can only delete your own comment")
+			return
+		}
+		if reason == "" {
+			utils.Error(ctx, http.StatusBadRequest, 40073, "reason is required when deleting others' content")
+			return
+		}
 	}
-	if err := p.db.Delete(&cmt).Error; err != nil {
+	if err := trashRecord(p.db, &cmt, uid, reason); err != nil {
 		utils.Error(ctx, http.StatusInternalServerError, 50071, "failed to delete comment")
 		return
 	}
+	if moderated {
+		recordModeration(ctx, p.db, "comment.delete", "comment", cmt.ID, reason, cmt)
+	}
+	settleDeletedPoints(p.db, models.ReportTargetComment, cmt.ID, cmt.UserID, moderated)
 	// Invalidate post cache
-	utils.InvalidateByPrefix("cache:post:detail:" + strconv.Itoa(int(cmt.PostID)))
+	utils.CacheDelete("cache:post:detail:" + strconv.Itoa(int(cmt.PostID)))
 	utils.Success(ctx, gin.H{"message": "comment deleted"})
 }
 
@@ -413,16 +508,12 @@ func (p *PostController) UpdatePost(ctx *gin.Context) {
 	if category == "" {
 		category = "综合"
 	}
-	// Validate category
-	validCategories := []string{"综合", "评测", "技术", "线报", "推广", "交易"}
-	isValid := false
-	for _, c := range validCategories {
-		if category == c {
-			isValid = true
-			break
-		}
+	if err := utils.ValidateHiddenContent(content); err != nil {
+		utils.Error(ctx, http.StatusBadRequest, 40048, err.Error())
+		return
 	}
-	if !isValid {
+	// Validate category
+	if !isValidCategory(category) {
 		utils.Error(ctx, http.StatusBadRequest, 40026, "invalid category")
 		return
 	}
@@ -448,25 +539,61 @@ func (p *PostController) UpdatePost(ctx *gin.Context) {
 		utils.Error(ctx, http.StatusForbidden, 40301, "you can only update your own posts")
 		return
 	}
+	if sanctionBlocks(ctx, p.db, models.SanctionSuspend) {
+		return
+	}
+	if post.Locked {
+		utils.Error(ctx, http.StatusForbidden, 40332, "post is locked")
+		return
+	}
+	title, titleHits, ok := filterInput(ctx, p.db, models.FilterFieldTitle, title)
+	if !ok {
+		return
+	}
+	content, contentHits, ok := filterInput(ctx, p.db, models.FilterFieldContent, content)
+	if !ok {
+		return
+	}
+	held := append(titleHits, contentHits...)
+	before := post
+	// Edits are scored like new posts, otherwise links could be added after a clean first version
+	spam, ok := spamCheck(ctx, p.db, models.ReportTargetPost, userID, category, title, content)
+	if !ok {
+		return
+	}
 
 	post.Title = title
 	post.Content = content
 	post.Category = category
 	post.Attachments = req.Attachments
+	post.SpamScore = spam.score
+	if len(held) > 0 || spam.held {
+		// An edit that trips a review rule or the spam scorer takes the post offline until it is reviewed again
+		post.Hidden = true
+	}
+	if post.Status == models.StatusRejected || needsPremoderation(ctx, p.db, userID, category) {
+		// Rejected posts go back into the queue once edited; held authors' edits are reviewed too
+		post.Status = models.StatusPending
+	}
 	if err := p.db.Save(&post).Error; err != nil {
 		utils.Error(ctx, http.StatusInternalServerError, 50026, "failed to update post")
 		return
 	}
+	fileHeldReport(p.db, models.ReportTargetPost, post.ID, held, spam)
+	if before.Status == models.StatusPublished && post.Status != models.StatusPublished {
+		// Back in the review queue: approval counts the post again
+		countPost(&before, -1)
+	}
 
 	// Invalidate caches for lists and detail
 	utils.InvalidateByPrefix("cache:posts:list:")
-	utils.InvalidateByPrefix("cache:post:detail:" + postID)
+	utils.CacheDelete("cache:post:detail:" + strconv.Itoa(int(post.ID)))
 	utils.InvalidateByPrefix("cache:user:" + strconv.Itoa(int(post.UserID)) + ":posts:")
 
 	utils.Success(ctx, gin.H{"post": post})
 }
 
-// DeletePost allows the author to delete their post.
+// DeletePost allows the author, or a moderator of the post's category, to delete a post.
 func (p *PostController) DeletePost(ctx *gin.Context) {
 	postID := ctx.Param("id")
 	var post models.Post
@@ -485,19 +612,30 @@ func (p *PostController) DeletePost(ctx *gin.Context) {
 		return
 	}
 
-	if post.UserID != userID && !isAdmin(ctx) {
+	moderated := post.UserID != userID
+	if moderated && !middleware.HasPermission(ctx, models.PermPostDeleteAny, post.Category) {
 		utils.Error(ctx, http.StatusForbidden, 40302, "you can only delete your own posts")
 		return
 	}
+	reason := moderationReason(ctx)
+	if moderated && reason == "" {
+		utils.Error(ctx, http.StatusBadRequest, 40073, "reason is required when deleting others' content")
+		return
+	}
 
-	if err := p.db.Delete(&post).Error; err != nil {
+	if err := trashRecord(p.db, &post, userID, reason); err != nil {
 		utils.Error(ctx, http.StatusInternalServerError, 50028, "failed to delete post")
 		return
 	}
+	if moderated {
+		recordModeration(ctx, p.db, "post.delete", "post", post.ID, reason, post)
+	}
+	settleDeletedPoints(p.db, models.ReportTargetPost, post.ID, post.UserID, moderated)
+	countPost(&post, -1)
 
 	// Invalidate lists and detail cache
 	utils.InvalidateByPrefix("cache:posts:list:")
-	utils.InvalidateByPrefix("cache:post:detail:" + postID)
+	utils.CacheDelete("cache:post:detail:" + strconv.Itoa(int(post.ID)))
 	utils.InvalidateByPrefix("cache:user:" + strconv.Itoa(int(post.UserID)) + ":posts:")
 
 	utils.Success(ctx, gin.H{"message": "post deleted"})
@@ -511,6 +649,9 @@ func (p *PostController) UploadAttachment(ctx *gin.Context) {
 		utils.Error(ctx, http.StatusUnauthorized, 40113, "unauthorized")
 		return
 	}
+	if sanctionBlocks(ctx, p.db, models.SanctionSuspend) {
+		return
+	}
 
 	// Accept common field name 'file' or fallback to 'f'
 	file, header, err := ctx.Request.FormFile("file")
@@ -599,6 +740,18 @@ func (p *PostController) UploadAttachment(ctx *gin.Context) {
 	utils.Success(ctx, gin.H{"url": urlVal})
 }
 
+// postCategories lists the categories a post may belong to.
+var postCategories = []string{"综合", "评测", "技术", "线报", "推广", "交易"}
+
+func isValidCategory(category string) bool {
+	for _, c := range postCategories {
+		if category == c {
+			return true
+		}
+	}
+	return false
+}
+
 func parsePagination(pageStr, sizeStr string) (int, int) {
 	page := 1
 	pageSize := 10
@@ -630,21 +783,3 @@ func getUserID(ctx *gin.Context) (uint, bool) {
 		return 0, false
 	}
 }
-
-func isAdmin(ctx *gin.Context) bool {
-	unameVal, exists := ctx.Get(middleware.ContextUsernameKey)
-	if !exists {
-		return false
-	}
-	uname, _ := unameVal.(string)
-	if uname == "" {
-		return false
-	}
-	cfg := config.Get()
-	for _, u := range cfg.AdminUsernames {
-		if strings.EqualFold(strings.TrimSpace(u), uname) {
-			return true
-		}
-	}
-	return false
-}
@@ -0,0 +1,312 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/cppla/aibbs/middleware"
+	"github.com/cppla/aibbs/models"
+	"github.com/cppla/aibbs/utils"
+)
+
+// LockPost stops edits and new comments on a post.
+func (p *PostController) LockPost(ctx *gin.Context) {
+	p.setPostFlag(ctx, models.PermPostLock, "locked", true, "post.lock")
+}
+
+// UnlockPost reopens a locked post.
+func (p *PostController) UnlockPost(ctx *gin.Context) {
+	p.setPostFlag(ctx, models.PermPostLock, "locked", false, "post.unlock")
+}
+
+// PinPost lists a post ahead of the others.
+func (p *PostController) PinPost(ctx *gin.Context) {
+	p.setPostFlag(ctx, models.PermPostPin, "pinned", true, "post.pin")
+}
+
+// UnpinPost removes a post from the pinned set.
+func (p *PostController) UnpinPost(ctx *gin.Context) {
+	p.setPostFlag(ctx, models.PermPostPin, "pinned", false, "post.unpin")
+}
+
+// MovePost changes a post's category. Category moderators may move posts out of the categories they manage.
+// With "redirect" a locked stub linking to the post is left behind in the old category.
+func (p *PostController) MovePost(ctx *gin.Context) {
+	var req struct {
+		Category string `json:"category" binding:"required"`
+		Redirect bool   `json:"redirect"`
+		Reason   string `json:"reason"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.Error(ctx, http.StatusBadRequest, 40071, "invalid request payload")
+		return
+	}
+	target := strings.TrimSpace(req.Category)
+	if !isValidCategory(target) {
+		utils.Error(ctx, http.StatusBadRequest, 40072, "invalid category")
+		return
+	}
+	post, ok := p.loadModeratedPost(ctx, models.PermPostMove)
+	if !ok {
+		return
+	}
+	if post.Category == target {
+		utils.Success(ctx, gin.H{"post": post})
+		return
+	}
+	before := *post
+	var stub *models.Post
+	err := p.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(post).Update("category", target).Error; err != nil {
+			return err
+		}
+		if req.Redirect {
+			stub = redirectStub(&before, post.ID)
+			return tx.Create(stub).Error
+		}
+		return nil
+	})
+	if err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50072, "failed to update post")
+		return
+	}
+	invalidatePostCaches(post)
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		reason = before.Category + " -> " + target
+	}
+	recordModeration(ctx, p.db, "post.move", "post", post.ID, reason, before)
+	resp := gin.H{"post": post}
+	if stub != nil {
+		resp["redirect"] = stub
+	}
+	utils.Success(ctx, resp)
+}
+
+// MergePost folds thread source_id into the :id thread. The source's opening post becomes a comment,
+// and its comments are re-parented with their original timestamps. The source is then trashed,
+// or with "redirect" turned into a locked stub pointing at the target.
+func (p *PostController) MergePost(ctx *gin.Context) {
+	var req struct {
+		SourceID uint   `json:"source_id" binding:"required"`
+		Redirect bool   `json:"redirect"`
+		Reason   string `json:"reason"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.Error(ctx, http.StatusBadRequest, 40071, "invalid request payload")
+		return
+	}
+	post, ok := p.loadModeratedPost(ctx, models.PermPostMove)
+	if !ok {
+		return
+	}
+	if req.SourceID == post.ID {
+		utils.Error(ctx, http.StatusBadRequest, 40027, "cannot merge a post into itself")
+		return
+	}
+	var source models.Post
+	if err := p.db.First(&source, req.SourceID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			utils.Error(ctx, http.StatusNotFound, 40461, "source post not found")
+			return
+		}
+		utils.Error(ctx, http.StatusInternalServerError, 50073, "failed to load post")
+		return
+	}
+	if !middleware.HasPermission(ctx, models.PermPostMove, source.Category) {
+		utils.Error(ctx, http.StatusForbidden, 40331, "you do not moderate this category")
+		return
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		reason = fmt.Sprintf("merged into #%d", post.ID)
+	}
+	uid, _ := getUserID(ctx)
+	before := source
+	var moved int64
+	err := p.db.Transaction(func(tx *gorm.DB) error {
+		// Comments have no unlock step, so hidden blocks of the source stay locked for good
+		opening := models.Comment{
+			PostID:    post.ID,
+			UserID:    source.UserID,
+			Content:   "**" + source.Title + "**\n\n" + utils.RenderHiddenContent(source.Content, false, false),
+			Hidden:    source.Hidden,
+			Status:    source.Status,
+			CreatedAt: source.CreatedAt,
+			UpdatedAt: source.UpdatedAt,
+		}
+		if err := tx.Create(&opening).Error; err != nil {
+			return err
+		}
+		// UpdateColumn leaves updated_at alone; trashed comments follow so they can still be restored
+		res := tx.Unscoped().Model(&models.Comment{}).Where("post_id = ?", source.ID).UpdateColumn("post_id", post.ID)
+		if res.Error != nil {
+			return res.Error
+		}
+		moved = res.RowsAffected
+		if req.Redirect {
+			stub := redirectStub(&source, post.ID)
+			return tx.Model(&source).Updates(map[string]interface{}{"content": stub.Content, "locked": true, "moved_to": post.ID}).Error
+		}
+		return trashRecord(tx, &source, uid, reason)
+	})
+	if err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50175, "failed to merge posts")
+		return
+	}
+	invalidatePostCaches(post)
+	invalidatePostCaches(&source)
+	recordModeration(ctx, p.db, "post.merge", "post", source.ID, reason, before)
+	utils.Success(ctx, gin.H{"post": post, "merged_comments": moved})
+}
+
+// SplitPost moves the selected comments of the :id thread into a new thread. The earliest selected
+// comment becomes the opening post, keeping its author and timestamp.
+func (p *PostController) SplitPost(ctx *gin.Context) {
+	var req struct {
+		CommentIDs []uint `json:"comment_ids" binding:"required"`
+		Title      string `json:"title" binding:"required"`
+		Category   string `json:"category"`
+		Reason     string `json:"reason"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.Error(ctx, http.StatusBadRequest, 40071, "invalid request payload")
+		return
+	}
+	title := strings.TrimSpace(req.Title)
+	ids := utils.UniqueUint(req.CommentIDs)
+	if title == "" || len([]rune(title)) > 255 || len(ids) == 0 {
+		utils.Error(ctx, http.StatusBadRequest, 40071, "invalid request payload")
+		return
+	}
+	post, ok := p.loadModeratedPost(ctx, models.PermPostMove)
+	if !ok {
+		return
+	}
+	category := strings.TrimSpace(req.Category)
+	if category == "" {
+		category = post.Category
+	}
+	if !isValidCategory(category) {
+		utils.Error(ctx, http.StatusBadRequest, 40072, "invalid category")
+		return
+	}
+	if category != post.Category && !middleware.HasPermission(ctx, models.PermPostMove, category) {
+		utils.Error(ctx, http.StatusForbidden, 40331, "you do not moderate this category")
+		return
+	}
+	var comments []models.Comment
+	if err := p.db.Where("post_id = ? AND id IN ?", post.ID, ids).Order("created_at ASC, id ASC").Find(&comments).Error; err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50176, "failed to split post")
+		return
+	}
+	if len(comments) != len(ids) {
+		utils.Error(ctx, http.StatusBadRequest, 40028, "comments must belong to this post")
+		return
+	}
+	first := comments[0]
+	split := models.Post{
+		UserID:    first.UserID,
+		Title:     title,
+		Content:   first.Content,
+		Category:  category,
+		Hidden:    first.Hidden,
+		Status:    first.Status,
+		CreatedAt: first.CreatedAt,
+	}
+	reason := strings.TrimSpace(req.Reason)
+	uid, _ := getUserID(ctx)
+	err := p.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&split).Error; err != nil {
+			return err
+		}
+		// The opening comment lives on as the new post's body. It goes to the trash, pointing at the new
+		// post, rather than away: its tips, ledger entries and reports still refer to it, and restoring it
+		// undoes that part of the split
+		note := fmt.Sprintf("split into #%d", split.ID)
+		if reason != "" {
+			note += ": " + reason
+		}
+		if err := trashRecord(tx, &first, uid, note); err != nil {
+			return err
+		}
+		if len(comments) == 1 {
+			return nil
+		}
+		rest := make([]uint, 0, len(comments)-1)
+		for _, c := range comments[1:] {
+			rest = append(rest, c.ID)
+		}
+		return tx.Model(&models.Comment{}).Where("id IN ?", rest).UpdateColumn("post_id", split.ID).Error
+	})
+	if err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50176, "failed to split post")
+		return
+	}
+	invalidatePostCaches(post)
+	invalidatePostCaches(&split)
+	if reason == "" {
+		reason = fmt.Sprintf("split %d comments into #%d", len(comments), split.ID)
+	}
+	recordModeration(ctx, p.db, "post.split", "post", post.ID, reason, gin.H{"comment_ids": ids, "new_post_id": split.ID})
+	utils.Success(ctx, gin.H{"post": split, "moved_comments": len(comments) - 1})
+}
+
+// redirectStub builds the locked placeholder that keeps an old link pointing at post to.
+func redirectStub(from *models.Post, to uint) *models.Post {
+	return &models.Post{
+		UserID:    from.UserID,
+		Title:     from.Title,
+		Content:   fmt.Sprintf("本帖已移动，请前往 [新位置](/post-%d-1)。", to),
+		Category:  from.Category,
+		Locked:    true,
+		Status:    models.StatusPublished,
+		MovedTo:   to,
+		CreatedAt: from.CreatedAt,
+	}
+}
+
+func (p *PostController) setPostFlag(ctx *gin.Context, perm, column string, value bool, action string) {
+	post, ok := p.loadModeratedPost(ctx, perm)
+	if !ok {
+		return
+	}
+	reason := moderationReason(ctx)
+	before := *post
+	if err := p.db.Model(post).Update(column, value).Error; err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50072, "failed to update post")
+		return
+	}
+	invalidatePostCaches(post)
+	recordModeration(ctx, p.db, action, "post", post.ID, reason, before)
+	utils.Success(ctx, gin.H{"post": post})
+}
+
+// loadModeratedPost loads the :id post and checks perm against its category.
+func (p *PostController) loadModeratedPost(ctx *gin.Context, perm string) (*models.Post, bool) {
+	var post models.Post
+	if err := p.db.First(&post, ctx.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			utils.Error(ctx, http.StatusNotFound, 40405, "post not found")
+			return nil, false
+		}
+		utils.Error(ctx, http.StatusInternalServerError, 50073, "failed to load post")
+		return nil, false
+	}
+	if !middleware.HasPermission(ctx, perm, post.Category) {
+		utils.Error(ctx, http.StatusForbidden, 40331, "you do not moderate this category")
+		return nil, false
+	}
+	return &post, true
+}
+
+func invalidatePostCaches(post *models.Post) {
+	utils.InvalidateByPrefix("cache:posts:list:")
+	utils.CacheDelete("cache:post:detail:" + strconv.Itoa(int(post.ID)))
+	utils.InvalidateByPrefix("cache:user:" + strconv.Itoa(int(post.UserID)) + ":posts:")
+}
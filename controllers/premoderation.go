@@ -0,0 +1,231 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/cppla/aibbs/config"
+	"github.com/cppla/aibbs/middleware"
+	"github.com/cppla/aibbs/models"
+	"github.com/cppla/aibbs/utils"
+)
+
+// needsPremoderation reports whether new content from userID in category must wait for approval.
+// Trusted members and moderators of the category are never held.
+func needsPremoderation(ctx *gin.Context, db *gorm.DB, userID uint, category string) bool {
+	cfg := config.Get()
+	if cfg.PremodMinAccountDays <= 0 && cfg.PremodMinPoints <= 0 && len(cfg.PremodCategories) == 0 {
+		return false
+	}
+	if middleware.HasPermission(ctx, models.PermContentTrusted, category) || middleware.HasPermission(ctx, models.PermContentApprove, category) {
+		return false
+	}
+	if containsString(cfg.PremodCategories, category) {
+		return true
+	}
+	var user models.User
+	if err := db.Select("id", "points", "created_at").First(&user, userID).Error; err != nil {
+		return true
+	}
+	if cfg.PremodMinAccountDays > 0 && time.Since(user.CreatedAt) < time.Duration(cfg.PremodMinAccountDays)*24*time.Hour {
+		return true
+	}
+	return cfg.PremodMinPoints > 0 && user.Points < cfg.PremodMinPoints
+}
+
+// initialStatus is the status new content gets under the pre-moderation policy.
+func initialStatus(ctx *gin.Context, db *gorm.DB, userID uint, category string) string {
+	if needsPremoderation(ctx, db, userID, category) {
+		return models.StatusPending
+	}
+	return models.StatusPublished
+}
+
+// ListPending is the pre-moderation queue: ?type=post (default) or comment, optionally ?category=.
+func (p *PostController) ListPending(ctx *gin.Context) {
+	page, pageSize := parsePagination(ctx.Query("page"), ctx.Query("page_size"))
+	category := strings.TrimSpace(ctx.Query("category"))
+	var (
+		total int64
+		items interface{}
+		err   error
+	)
+	if ctx.Query("type") == models.ReportTargetComment {
+		var comments []models.Comment
+		q := p.db.Model(&models.Comment{}).Where("comments.status = ?", models.StatusPending)
+		if category != "" {
+			q = q.Joins("JOIN posts ON posts.id = comments.post_id").Where("posts.category = ?", category)
+		}
+		if err = q.Count(&total).Error; err == nil {
+			err = q.Preload("User").Order("comments.id ASC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&comments).Error
+		}
+		items = comments
+	} else {
+		var posts []models.Post
+		q := p.db.Model(&models.Post{}).Where("status = ?", models.StatusPending)
+		if category != "" {
+			q = q.Where("category = ?", category)
+		}
+		if err = q.Count(&total).Error; err == nil {
+			err = q.Preload("User").Order("id ASC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&posts).Error
+		}
+		items = posts
+	}
+	if err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50173, "failed to list pending content")
+		return
+	}
+	utils.Success(ctx, gin.H{
+		"items": items,
+		"pagination": gin.H{
+			"page":        page,
+			"page_size":   pageSize,
+			"total":       total,
+			"total_pages": int((total + int64(pageSize) - 1) / int64(pageSize)),
+		},
+	})
+}
+
+// ApprovePost publishes a pending post.
+func (p *PostController) ApprovePost(ctx *gin.Context) {
+	p.reviewPost(ctx, models.StatusPublished)
+}
+
+// RejectPost rejects a pending post; a reason is required and sent to the author.
+func (p *PostController) RejectPost(ctx *gin.Context) {
+	p.reviewPost(ctx, models.StatusRejected)
+}
+
+// ApproveComment publishes a pending comment.
+func (p *PostController) ApproveComment(ctx *gin.Context) {
+	p.reviewComment(ctx, models.StatusPublished)
+}
+
+// RejectComment rejects a pending comment; a reason is required and sent to the author.
+func (p *PostController) RejectComment(ctx *gin.Context) {
+	p.reviewComment(ctx, models.StatusRejected)
+}
+
+func (p *PostController) reviewPost(ctx *gin.Context, status string) {
+	post, ok := p.loadModeratedPost(ctx, models.PermContentApprove)
+	if !ok {
+		return
+	}
+	reason, ok := reviewReason(ctx, status)
+	if !ok {
+		return
+	}
+	if !p.setStatus(ctx, &models.Post{}, post.ID, status) {
+		return
+	}
+	before := *post
+	post.Status = status
+	if status == models.StatusPublished {
+		utils.TrainSpam(spamItem(models.ReportTargetPost, post.ID), post.Title, post.Content, false)
+		awardPostCreated(p.db, post)
+		countPost(post, 1)
+		awardBadges(p.db, post.UserID, models.BadgeRulePostCount)
+	}
+	invalidatePostCaches(post)
+	recordModeration(ctx, p.db, "post."+reviewVerb(status), models.ReportTargetPost, post.ID, reason, before)
+	notifyReview(p.db, post.UserID, "帖子", post.Title, status, reason, fmt.Sprintf("/post-%d-1", post.ID))
+	utils.Success(ctx, gin.H{"post": post})
+}
+
+func (p *PostController) reviewComment(ctx *gin.Context, status string) {
+	var cmt models.Comment
+	if err := p.db.First(&cmt, ctx.Param("commentId")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.Error(ctx, http.StatusNotFound, 40420, "comment not found")
+			return
+		}
+		utils.Error(ctx, http.StatusInternalServerError, 50070, "failed to load comment")
+		return
+	}
+	var post models.Post
+	if err := p.db.Select("id", "category").First(&post, cmt.PostID).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.Error(ctx, http.StatusInternalServerError, 50070, "failed to load comment")
+		return
+	}
+	if !middleware.HasPermission(ctx, models.PermContentApprove, post.Category) {
+		utils.Error(ctx, http.StatusForbidden, 40331, "you do not moderate this category")
+		return
+	}
+	reason, ok := reviewReason(ctx, status)
+	if !ok {
+		return
+	}
+	if !p.setStatus(ctx, &models.Comment{}, cmt.ID, status) {
+		return
+	}
+	before := cmt
+	cmt.Status = status
+	if status == models.StatusPublished {
+		utils.TrainSpam(spamItem(models.ReportTargetComment, cmt.ID), "", cmt.Content, false)
+		awardReplyReceived(p.db, &cmt)
+	}
+	utils.CacheDelete("cache:post:detail:" + strconv.Itoa(int(cmt.PostID)))
+	recordModeration(ctx, p.db, "comment."+reviewVerb(status), models.ReportTargetComment, cmt.ID, reason, before)
+	notifyReview(p.db, cmt.UserID, "评论", excerpt(cmt.Content, 30), status, reason, fmt.Sprintf("/post-%d-1", cmt.PostID))
+	utils.Success(ctx, gin.H{"comment": cmt})
+}
+
+// setStatus moves a pending row to status; it writes a 409 when the row is no longer pending.
+func (p *PostController) setStatus(ctx *gin.Context, model interface{}, id uint, status string) bool {
+	res := p.db.Model(model).Where("id = ? AND status = ?", id, models.StatusPending).Update("status", status)
+	if res.Error != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50174, "failed to update status")
+		return false
+	}
+	if res.RowsAffected == 0 {
+		utils.Error(ctx, http.StatusConflict, 40960, "content is not pending review")
+		return false
+	}
+	return true
+}
+
+// reviewReason returns the moderator's reason; rejections must carry one.
+func reviewReason(ctx *gin.Context, status string) (string, bool) {
+	reason := moderationReason(ctx)
+	if status == models.StatusRejected && reason == "" {
+		utils.Error(ctx, http.StatusBadRequest, 40073, "reason is required when rejecting content")
+		return "", false
+	}
+	return reason, true
+}
+
+func reviewVerb(status string) string {
+	if status == models.StatusPublished {
+		return "approve"
+	}
+	return "reject"
+}
+
+// notifyReview tells the author how their held post or comment was reviewed.
+func notifyReview(db *gorm.DB, userID uint, kind, subject, status, reason, link string) {
+	if status == models.StatusPublished {
+		notify(db, userID, models.NotificationContentApproved, "你的"+kind+"已通过审核", subject, link)
+		return
+	}
+	body := subject
+	if reason != "" {
+		body += "\n原因：" + reason
+	}
+	notify(db, userID, models.NotificationContentRejected, "你的"+kind+"未通过审核", body, link)
+}
+
+// excerpt shortens text to n runes for notification bodies.
+func excerpt(s string, n int) string {
+	rs := []rune(strings.TrimSpace(s))
+	if len(rs) <= n {
+		return string(rs)
+	}
+	return string(rs[:n]) + "…"
+}
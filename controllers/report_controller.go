@@ -0,0 +1,384 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/cppla/aibbs/config"
+	"github.com/cppla/aibbs/models"
+	"github.com/cppla/aibbs/utils"
+)
+
+var errReportClosed = errors.New("report already closed")
+
+// Reason codes of reports filed by the system (reporter 0) rather than by members.
+const (
+	reportReasonFilter = "filter"
+	reportReasonSpam   = "spam"
+)
+
+// ReportController handles member reports and the moderation queue.
+type ReportController struct {
+	db *gorm.DB
+}
+
+// NewReportController builds a ReportController.
+func NewReportController(db *gorm.DB) *ReportController {
+	return &ReportController{db: db}
+}
+
+// CreateReport files a report against a post, comment or user.
+func (r *ReportController) CreateReport(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		utils.Error(ctx, http.StatusUnauthorized, 40130, "unauthorized")
+		return
+	}
+	if sanctionBlocks(ctx, r.db, models.SanctionSuspend) {
+		return
+	}
+	var req struct {
+		TargetType string `json:"target_type" binding:"required"`
+		TargetID   uint   `json:"target_id" binding:"required"`
+		Reason     string `json:"reason" binding:"required"`
+		Detail     string `json:"detail"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.Error(ctx, http.StatusBadRequest, 40082, "invalid request payload")
+		return
+	}
+	targetType := strings.ToLower(strings.TrimSpace(req.TargetType))
+	reason := strings.ToLower(strings.TrimSpace(req.Reason))
+	detail := strings.TrimSpace(req.Detail)
+	if targetType == models.ReportTargetMessage {
+		// Private messages are not implemented yet; keep the type reserved
+		utils.Error(ctx, http.StatusBadRequest, 40083, "private messages are not supported")
+		return
+	}
+	if !containsString(models.ReportReasons, reason) {
+		utils.Error(ctx, http.StatusBadRequest, 40084, "invalid reason")
+		return
+	}
+	if reason == "other" && detail == "" {
+		utils.Error(ctx, http.StatusBadRequest, 40085, "detail is required for reason 'other'")
+		return
+	}
+	if len([]rune(detail)) > 1000 {
+		utils.Error(ctx, http.StatusBadRequest, 40086, "detail too long")
+		return
+	}
+
+	ownerID, _, err := r.reportTarget(targetType, req.TargetID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.Error(ctx, http.StatusNotFound, 40430, "report target not found")
+			return
+		}
+		utils.Error(ctx, http.StatusBadRequest, 40087, err.Error())
+		return
+	}
+	if ownerID == userID {
+		utils.Error(ctx, http.StatusBadRequest, 40088, "you cannot report your own content")
+		return
+	}
+
+	active := true
+	report := models.Report{
+		ReporterID: userID,
+		TargetType: targetType,
+		TargetID:   req.TargetID,
+		ReasonCode: reason,
+		Detail:     detail,
+		Status:     models.ReportStatusOpen,
+		Active:     &active,
+	}
+	res := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&report)
+	if res.Error != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50130, "failed to create report")
+		return
+	}
+	if res.RowsAffected == 0 {
+		utils.Error(ctx, http.StatusConflict, 40930, "you already have an open report on this")
+		return
+	}
+	r.autoHide(targetType, req.TargetID)
+	utils.Success(ctx, gin.H{"report": report})
+}
+
+// ListReports is the moderation queue, filterable by status, target_type, reason and claimed_by.
+func (r *ReportController) ListReports(ctx *gin.Context) {
+	page, pageSize := parsePagination(ctx.Query("page"), ctx.Query("page_size"))
+	q := r.db.Model(&models.Report{})
+	if v := strings.TrimSpace(ctx.Query("status")); v != "" {
+		q = q.Where("status = ?", v)
+	} else {
+		q = q.Where("status IN ?", []string{models.ReportStatusOpen, models.ReportStatusClaimed})
+	}
+	if v := strings.TrimSpace(ctx.Query("target_type")); v != "" {
+		q = q.Where("target_type = ?", v)
+	}
+	if v := strings.TrimSpace(ctx.Query("target_id")); v != "" {
+		q = q.Where("target_id = ?", v)
+	}
+	if v := strings.TrimSpace(ctx.Query("reason")); v != "" {
+		q = q.Where("reason_code = ?", v)
+	}
+	if v := strings.TrimSpace(ctx.Query("claimed_by")); v != "" {
+		q = q.Where("claimed_by = ?", v)
+	}
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50131, "failed to count reports")
+		return
+	}
+	var items []models.Report
+	if err := q.Order("id ASC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&items).Error; err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50132, "failed to list reports")
+		return
+	}
+	utils.Success(ctx, gin.H{
+		"items": items,
+		"pagination": gin.H{
+			"page":        page,
+			"page_size":   pageSize,
+			"total":       total,
+			"total_pages": int((total + int64(pageSize) - 1) / int64(pageSize)),
+		},
+	})
+}
+
+// ClaimReport marks an open report as being handled by the current moderator.
+func (r *ReportController) ClaimReport(ctx *gin.Context) {
+	uid, _ := getUserID(ctx)
+	res := r.db.Model(&models.Report{}).
+		Where("id = ? AND status = ?", ctx.Param("id"), models.ReportStatusOpen).
+		Updates(map[string]interface{}{"status": models.ReportStatusClaimed, "claimed_by": uid})
+	if res.Error != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50133, "failed to claim report")
+		return
+	}
+	if res.RowsAffected == 0 {
+		utils.Error(ctx, http.StatusConflict, 40931, "report is not open")
+		return
+	}
+	utils.Success(ctx, gin.H{"message": "report claimed"})
+}
+
+// ResolveReport confirms a report. The target stays hidden, or is deleted with "delete": true.
+// Every open report on the same target is closed with it.
+func (r *ReportController) ResolveReport(ctx *gin.Context) {
+	var req struct {
+		Note   string `json:"note"`
+		Delete bool   `json:"delete"`
+	}
+	_ = ctx.ShouldBindJSON(&req)
+	r.closeReport(ctx, models.ReportStatusResolved, strings.TrimSpace(req.Note), req.Delete)
+}
+
+// DismissReport rejects a report and unhides the target.
+func (r *ReportController) DismissReport(ctx *gin.Context) {
+	var req struct {
+		Note string `json:"note"`
+	}
+	_ = ctx.ShouldBindJSON(&req)
+	r.closeReport(ctx, models.ReportStatusDismissed, strings.TrimSpace(req.Note), false)
+}
+
+func (r *ReportController) closeReport(ctx *gin.Context, status, note string, deleteTarget bool) {
+	uid, _ := getUserID(ctx)
+	var report models.Report
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&report, ctx.Param("id")).Error; err != nil {
+			return err
+		}
+		if report.Status != models.ReportStatusOpen && report.Status != models.ReportStatusClaimed {
+			return errReportClosed
+		}
+		now := time.Now()
+		return tx.Model(&models.Report{}).
+			Where("target_type = ? AND target_id = ? AND status IN ?", report.TargetType, report.TargetID, []string{models.ReportStatusOpen, models.ReportStatusClaimed}).
+			Updates(map[string]interface{}{"status": status, "resolved_by": uid, "resolution": note, "resolved_at": now, "active": nil}).Error
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			utils.Error(ctx, http.StatusNotFound, 40431, "report not found")
+		case errors.Is(err, errReportClosed):
+			utils.Error(ctx, http.StatusConflict, 40932, "report already closed")
+		default:
+			utils.Error(ctx, http.StatusInternalServerError, 50134, "failed to update report")
+		}
+		return
+	}
+
+	reason := note
+	if reason == "" {
+		reason = "report #" + strconv.FormatUint(uint64(report.ID), 10) + ": " + report.ReasonCode
+	}
+	if report.ReasonCode == reportReasonSpam {
+		// Moderator decisions on spam reports are the training data of the Bayesian filter
+		_ = trainSpamTarget(r.db, report.TargetType, report.TargetID, status == models.ReportStatusResolved)
+	}
+	switch {
+	case status == models.ReportStatusDismissed:
+		// Content hidden by an earlier upheld report stays hidden
+		var upheld int64
+		r.db.Model(&models.Report{}).Where("target_type = ? AND target_id = ? AND status = ?", report.TargetType, report.TargetID, models.ReportStatusResolved).Count(&upheld)
+		if upheld == 0 {
+			setContentHidden(r.db, report.TargetType, report.TargetID, false)
+		}
+		recordModeration(ctx, r.db, "report.dismiss", report.TargetType, report.TargetID, reason, report)
+	case deleteTarget:
+		if _, before, err := r.reportTarget(report.TargetType, report.TargetID); err == nil && before != nil {
+			deleteContent(r.db, report.TargetType, report.TargetID, uid, reason)
+			recordModeration(ctx, r.db, report.TargetType+".delete", report.TargetType, report.TargetID, reason, before)
+		}
+	default:
+		setContentHidden(r.db, report.TargetType, report.TargetID, true)
+		recordModeration(ctx, r.db, "report.resolve", report.TargetType, report.TargetID, reason, report)
+	}
+	utils.Success(ctx, gin.H{"message": "report " + status})
+}
+
+// reportTarget loads a report target, returning its owner and the record itself.
+func (r *ReportController) reportTarget(targetType string, id uint) (uint, interface{}, error) {
+	switch targetType {
+	case models.ReportTargetPost:
+		var post models.Post
+		if err := r.db.First(&post, id).Error; err != nil {
+			return 0, nil, err
+		}
+		return post.UserID, post, nil
+	case models.ReportTargetComment:
+		var cmt models.Comment
+		if err := r.db.First(&cmt, id).Error; err != nil {
+			return 0, nil, err
+		}
+		return cmt.UserID, cmt, nil
+	case models.ReportTargetUser:
+		var user models.User
+		if err := r.db.First(&user, id).Error; err != nil {
+			return 0, nil, err
+		}
+		return user.ID, nil, nil
+	default:
+		return 0, nil, errors.New("invalid target_type")
+	}
+}
+
+// autoHide hides a post or comment once enough distinct trusted members have reported it.
+func (r *ReportController) autoHide(targetType string, id uint) {
+	threshold := config.Get().ReportHideThreshold
+	if threshold <= 0 || (targetType != models.ReportTargetPost && targetType != models.ReportTargetComment) {
+		return
+	}
+	var reporters []uint
+	if err := r.db.Model(&models.Report{}).
+		Where("target_type = ? AND target_id = ? AND status IN ?", targetType, id, []string{models.ReportStatusOpen, models.ReportStatusClaimed}).
+		Distinct().Pluck("reporter_id", &reporters).Error; err != nil || len(reporters) < threshold {
+		return
+	}
+	trusted := 0
+	for _, rid := range reporters {
+		if utils.HasPermission(r.db, rid, models.PermContentTrusted, "") {
+			trusted++
+		}
+	}
+	if trusted >= threshold {
+		setContentHidden(r.db, targetType, id, true)
+		if utils.Sugar != nil {
+			utils.Sugar.Infof("auto-hidden %s:%d after %d trusted reports", targetType, id, trusted)
+		}
+	}
+}
+
+// setContentHidden toggles the hidden flag of a post or comment and drops the affected caches.
+func setContentHidden(db *gorm.DB, targetType string, id uint, hidden bool) {
+	switch targetType {
+	case models.ReportTargetPost:
+		var post models.Post
+		if err := db.First(&post, id).Error; err != nil {
+			return
+		}
+		db.Model(&post).Update("hidden", hidden)
+		invalidatePostCaches(&post)
+	case models.ReportTargetComment:
+		var cmt models.Comment
+		if err := db.First(&cmt, id).Error; err != nil {
+			return
+		}
+		db.Model(&cmt).Update("hidden", hidden)
+		utils.CacheDelete("cache:post:detail:" + strconv.Itoa(int(cmt.PostID)))
+	}
+}
+
+// deleteContent moves a reported post or comment to the trash and drops the affected caches.
+func deleteContent(db *gorm.DB, targetType string, id, actorID uint, reason string) {
+	switch targetType {
+	case models.ReportTargetPost:
+		var post models.Post
+		if err := db.First(&post, id).Error; err != nil {
+			return
+		}
+		if trashRecord(db, &post, actorID, reason) == nil {
+			settleDeletedPoints(db, targetType, id, post.UserID, true)
+			countPost(&post, -1)
+		}
+		invalidatePostCaches(&post)
+	case models.ReportTargetComment:
+		var cmt models.Comment
+		if err := db.First(&cmt, id).Error; err != nil {
+			return
+		}
+		if trashRecord(db, &cmt, actorID, reason) == nil {
+			settleDeletedPoints(db, targetType, id, cmt.UserID, true)
+		}
+		utils.CacheDelete("cache:post:detail:" + strconv.Itoa(int(cmt.PostID)))
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// fileSystemReport opens a report with reporter 0 so content held automatically shows up in the
+// moderation queue. An open system report on the same target is updated rather than duplicated.
+func fileSystemReport(db *gorm.DB, targetType string, targetID uint, reason, detail string) {
+	if rs := []rune(detail); len(rs) > 1000 {
+		detail = string(rs[:1000])
+	}
+	active := true
+	report := models.Report{
+		TargetType: targetType,
+		TargetID:   targetID,
+		ReasonCode: reason,
+		Detail:     detail,
+		Status:     models.ReportStatusOpen,
+		Active:     &active,
+	}
+	err := db.Clauses(clause.OnConflict{DoUpdates: clause.Assignments(map[string]interface{}{
+		"reason_code": reason,
+		"detail":      detail,
+		"status":      models.ReportStatusOpen,
+		"claimed_by":  0,
+		"resolved_by": 0,
+		"resolution":  "",
+		"resolved_at": nil,
+		"updated_at":  time.Now(),
+	})}).Create(&report).Error
+	if err != nil && utils.Sugar != nil {
+		utils.Sugar.Warnf("file system report %s:%d failed: %v", targetType, targetID, err)
+	}
+}
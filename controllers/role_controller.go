@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/cppla/aibbs/models"
+	"github.com/cppla/aibbs/utils"
+)
+
+var errLastAdmin = errors.New("cannot revoke the last admin")
+
+// RoleController manages role definitions and role assignments.
+type RoleController struct {
+	db *gorm.DB
+}
+
+// NewRoleController builds a RoleController.
+func NewRoleController(db *gorm.DB) *RoleController {
+	return &RoleController{db: db}
+}
+
+// ListRoles returns all roles with their permissions.
+func (r *RoleController) ListRoles(ctx *gin.Context) {
+	var roles []models.Role
+	if err := r.db.Preload("Permissions").Order("id ASC").Find(&roles).Error; err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50110, "failed to load roles")
+		return
+	}
+	utils.Success(ctx, gin.H{"items": roles})
+}
+
+// ListUserRoles returns the role assignments of a user.
+func (r *RoleController) ListUserRoles(ctx *gin.Context) {
+	var assignments []models.UserRole
+	if err := r.db.Preload("Role").Where("user_id = ?", ctx.Param("id")).Order("id ASC").Find(&assignments).Error; err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50111, "failed to load user roles")
+		return
+	}
+	utils.Success(ctx, gin.H{"items": assignments})
+}
+
+// AssignRole grants a role to a user; category_moderator requires a category, admin and moderator take none.
+func (r *RoleController) AssignRole(ctx *gin.Context) {
+	var req struct {
+		Role     string `json:"role" binding:"required"`
+		Category string `json:"category"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.Error(ctx, http.StatusBadRequest, 40091, "invalid request payload")
+		return
+	}
+	category := strings.TrimSpace(req.Category)
+
+	var user models.User
+	if err := r.db.First(&user, ctx.Param("id")).Error; err != nil {
+		utils.Error(ctx, http.StatusNotFound, 40401, "user not found")
+		return
+	}
+	var role models.Role
+	if err := r.db.Where("name = ?", strings.TrimSpace(req.Role)).First(&role).Error; err != nil {
+		utils.Error(ctx, http.StatusNotFound, 40490, "role not found")
+		return
+	}
+	if role.Name == models.RoleCategoryModerator && category == "" {
+		utils.Error(ctx, http.StatusBadRequest, 40092, "category_moderator requires a category")
+		return
+	}
+	if category != "" && (role.Name == models.RoleAdmin || role.Name == models.RoleModerator) {
+		utils.Error(ctx, http.StatusBadRequest, 40098, "admin and moderator are site-wide roles and take no category")
+		return
+	}
+	if category != "" && !isValidCategory(category) {
+		utils.Error(ctx, http.StatusBadRequest, 40093, "invalid category")
+		return
+	}
+
+	grantedBy, _ := getUserID(ctx)
+	assignment := models.UserRole{UserID: user.ID, RoleID: role.ID, Category: category}
+	res := r.db.Where(&models.UserRole{UserID: user.ID, RoleID: role.ID, Category: category}).
+		Attrs(models.UserRole{GrantedBy: grantedBy}).FirstOrCreate(&assignment)
+	if res.Error != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50112, "failed to assign role")
+		return
+	}
+	utils.InvalidateUserPermissions(user.ID)
+	if utils.Sugar != nil {
+		utils.Sugar.Infof("role assigned user=%d role=%s category=%q by=%d", user.ID, role.Name, category, grantedBy)
+	}
+	assignment.Role = role
+	utils.Success(ctx, gin.H{"assignment": assignment})
+}
+
+// RevokeRole removes a role assignment; the last admin assignment cannot be removed.
+func (r *RoleController) RevokeRole(ctx *gin.Context) {
+	userID := ctx.Param("id")
+	var assignment models.UserRole
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Preload("Role").Where("id = ? AND user_id = ?", ctx.Param("assignmentId"), userID).First(&assignment).Error; err != nil {
+			return err
+		}
+		if assignment.Role.Name == models.RoleAdmin {
+			var admins int64
+			if err := tx.Model(&models.UserRole{}).Where("role_id = ?", assignment.RoleID).Count(&admins).Error; err != nil {
+				return err
+			}
+			if admins <= 1 {
+				return errLastAdmin
+			}
+		}
+		return tx.Delete(&assignment).Error
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			utils.Error(ctx, http.StatusNotFound, 40491, "role assignment not found")
+		case errors.Is(err, errLastAdmin):
+			utils.Error(ctx, http.StatusBadRequest, 40094, "至少需要保留一名管理员")
+		default:
+			utils.Error(ctx, http.StatusInternalServerError, 50113, "failed to revoke role")
+		}
+		return
+	}
+	utils.InvalidateUserPermissions(assignment.UserID)
+	if utils.Sugar != nil {
+		revokedBy, _ := getUserID(ctx)
+		utils.Sugar.Infof("role revoked user=%d role=%s category=%q by=%d", assignment.UserID, assignment.Role.Name, assignment.Category, revokedBy)
+	}
+	utils.Success(ctx, gin.H{"message": "role revoked"})
+}
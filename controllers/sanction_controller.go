@@ -0,0 +1,157 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/cppla/aibbs/models"
+	"github.com/cppla/aibbs/utils"
+)
+
+// sessionLifetime matches the expiry of tokens issued by the auth handlers.
+const sessionLifetime = 72 * time.Hour
+
+// SanctionController manages bans, suspensions and mutes.
+type SanctionController struct {
+	db *gorm.DB
+}
+
+// NewSanctionController builds a SanctionController.
+func NewSanctionController(db *gorm.DB) *SanctionController {
+	return &SanctionController{db: db}
+}
+
+// ListUserSanctions returns a user's sanction history, newest first.
+func (s *SanctionController) ListUserSanctions(ctx *gin.Context) {
+	var items []models.UserSanction
+	if err := s.db.Where("user_id = ?", ctx.Param("id")).Order("id DESC").Find(&items).Error; err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50140, "failed to load sanctions")
+		return
+	}
+	now := time.Now()
+	out := make([]gin.H, 0, len(items))
+	for _, it := range items {
+		out = append(out, gin.H{"sanction": it, "active": it.Active(now)})
+	}
+	utils.Success(ctx, gin.H{"items": out})
+}
+
+// CreateSanction bans, suspends or mutes a user. duration_hours <= 0 means permanent.
+func (s *SanctionController) CreateSanction(ctx *gin.Context) {
+	var req struct {
+		Type          string `json:"type" binding:"required"`
+		Reason        string `json:"reason" binding:"required"`
+		DurationHours int    `json:"duration_hours"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.Error(ctx, http.StatusBadRequest, 40074, "invalid request payload")
+		return
+	}
+	kind := strings.ToLower(strings.TrimSpace(req.Type))
+	if kind != models.SanctionBan && kind != models.SanctionSuspend && kind != models.SanctionMute {
+		utils.Error(ctx, http.StatusBadRequest, 40075, "type must be ban, suspend or mute")
+		return
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		utils.Error(ctx, http.StatusBadRequest, 40076, "reason is required")
+		return
+	}
+
+	var user models.User
+	if err := s.db.First(&user, ctx.Param("id")).Error; err != nil {
+		utils.Error(ctx, http.StatusNotFound, 40401, "user not found")
+		return
+	}
+	actorID, _ := getUserID(ctx)
+	if user.ID == actorID {
+		utils.Error(ctx, http.StatusBadRequest, 40077, "you cannot sanction yourself")
+		return
+	}
+	if utils.HasPermission(s.db, user.ID, models.PermRoleAssign, "") {
+		utils.Error(ctx, http.StatusForbidden, 40343, "administrators cannot be sanctioned")
+		return
+	}
+
+	sanction := models.UserSanction{UserID: user.ID, Type: kind, Reason: reason, IssuedBy: actorID}
+	if req.DurationHours > 0 {
+		expires := time.Now().Add(time.Duration(req.DurationHours) * time.Hour)
+		sanction.ExpiresAt = &expires
+	}
+	if err := s.db.Create(&sanction).Error; err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50141, "failed to create sanction")
+		return
+	}
+	utils.InvalidateSanctions(user.ID)
+	if kind == models.SanctionBan {
+		utils.RevokeUserSessions(user.ID, sessionLifetime)
+	}
+	recordModeration(ctx, s.db, "user."+kind, models.ReportTargetUser, user.ID, reason, sanction)
+	utils.Success(ctx, gin.H{"sanction": sanction})
+}
+
+// RevokeSanction lifts a sanction before it expires.
+func (s *SanctionController) RevokeSanction(ctx *gin.Context) {
+	var sanction models.UserSanction
+	if err := s.db.First(&sanction, ctx.Param("id")).Error; err != nil {
+		utils.Error(ctx, http.StatusNotFound, 40440, "sanction not found")
+		return
+	}
+	if !sanction.Active(time.Now()) {
+		utils.Error(ctx, http.StatusConflict, 40940, "sanction is not active")
+		return
+	}
+	actorID, _ := getUserID(ctx)
+	now := time.Now()
+	if err := s.db.Model(&sanction).Updates(map[string]interface{}{"revoked_at": now, "revoked_by": actorID}).Error; err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50142, "failed to revoke sanction")
+		return
+	}
+	utils.InvalidateSanctions(sanction.UserID)
+	recordModeration(ctx, s.db, "user.un"+sanction.Type, models.ReportTargetUser, sanction.UserID, moderationReason(ctx), sanction)
+	utils.Success(ctx, gin.H{"sanction": sanction})
+}
+
+// sanctionBlocks writes a 403 and returns true when one of the given sanction types is active.
+func sanctionBlocks(ctx *gin.Context, db *gorm.DB, types ...string) bool {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		return false
+	}
+	status := utils.ActiveSanctions(db, userID)
+	for _, t := range types {
+		if s, active := status[t]; active {
+			code, msg := 40341, "account suspended"
+			if t == models.SanctionMute {
+				code, msg = 40342, "account muted"
+			}
+			utils.Respond(ctx, http.StatusForbidden, code, msg, gin.H{"reason": s.Reason, "expires_at": s.ExpiresAt})
+			return true
+		}
+	}
+	return false
+}
+
+// loginBanned rejects a login for a banned account; it writes the response and returns true when banned.
+func (a *AuthController) loginBanned(ctx *gin.Context, user *models.User, method string) bool {
+	ban, banned := utils.ActiveSanctions(a.db, user.ID)[models.SanctionBan]
+	if !banned {
+		return false
+	}
+	recordLoginEvent(a.db, ctx, user, method, loginOutcomeBanned)
+	utils.Respond(ctx, http.StatusForbidden, 40340, "account banned", gin.H{"reason": ban.Reason, "expires_at": ban.ExpiresAt})
+	return true
+}
+
+// sanctionSummary renders active sanctions for /auth/me.
+func sanctionSummary(status utils.SanctionStatus) gin.H {
+	out := gin.H{}
+	for t, s := range status {
+		out[t] = gin.H{"reason": s.Reason, "expires_at": s.ExpiresAt}
+	}
+	return out
+}
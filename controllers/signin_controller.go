@@ -3,6 +3,8 @@ package controllers
 import (
 	"errors"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -34,34 +36,42 @@ func (s *SignInController) DailySignIn(ctx *gin.Context) {
 		return
 	}
 
+	// Days start at midnight in the user's profile timezone, or the site's signin.Timezone
 	now := time.Now()
-	todayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-	tomorrowStart := todayStart.Add(24 * time.Hour)
+	var tz string
+	s.db.Model(&models.User{}).Where("id = ?", userID).Select("timezone").Scan(&tz)
+	today := utils.CalendarDate(now, utils.UserLocation(tz))
 
 	var existing models.SignIn
-	if err := s.db.Where("user_id = ? AND signin_date >= ? AND signin_date < ?", userID, todayStart, tomorrowStart).First(&existing).Error; err == nil {
+	if err := s.db.Where("user_id = ? AND signin_day = ?", userID, today).First(&existing).Error; err == nil {
 		utils.Error(ctx, http.StatusBadRequest, 40030, "already signed in today")
 		return
 	}
 
 	cfg := config.Get()
 	reward := cfg.SigninRewardPoints
+	var (
+		total  int
+		streak int
+		events []signInEvent
+	)
 
-	err := s.db.Transaction(func(tx *gorm.DB) error {
+	err := utils.Transaction(s.db, func(tx *gorm.DB) error {
 		var user models.User
 		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error; err != nil {
 			return err
 		}
 
 		var lastSignIn models.SignIn
-		err := tx.Where("user_id = ?", userID).Order("signin_date DESC").First(&lastSignIn).Error
+		err := tx.Where("user_id = ?", userID).Order("signin_day DESC, id DESC").First(&lastSignIn).Error
 
-		streak := 1
+		streak = 1
 		if err == nil {
-			if isSameDay(lastSignIn.SigninDate, todayStart) {
+			// Not only the same day: after a timezone change today can fall on or before the latest sign-in
+			if !lastSignIn.SigninDay.Before(today) {
 				return errAlreadySignedIn
 			}
-			if isYesterday(lastSignIn.SigninDate, todayStart) {
+			if isYesterday(lastSignIn.SigninDay, today) {
 				streak = lastSignIn.StreakAchieved + 1
 			}
 		} else if err != gorm.ErrRecordNotFound {
@@ -71,6 +81,7 @@ func (s *SignInController) DailySignIn(ctx *gin.Context) {
 		record := models.SignIn{
 			UserID:         userID,
 			SigninDate:     now,
+			SigninDay:      today,
 			PointsAwarded:  reward,
 			StreakAchieved: streak,
 		}
@@ -79,7 +90,48 @@ func (s *SignInController) DailySignIn(ctx *gin.Context) {
 			return err
 		}
 
-		user.Points += reward
+		balance, err := utils.ApplyPoints(tx, utils.PointsChange{
+			UserID:  userID,
+			Amount:  reward,
+			Reason:  models.PointsReasonSignIn,
+			RefType: "signin",
+			RefID:   record.ID,
+		})
+		if err != nil {
+			return err
+		}
+		total = reward
+
+		if bonus := cfg.SigninStreakBonuses[streak]; bonus > 0 {
+			if balance, err = utils.ApplyPoints(tx, utils.PointsChange{
+				UserID:  userID,
+				Amount:  bonus,
+				Reason:  models.PointsReasonSigninStreak,
+				RefType: "signin",
+				RefID:   record.ID,
+				Note:    strconv.Itoa(streak) + " days",
+			}); err != nil {
+				return err
+			}
+			total += bonus
+			events = append(events, signInEvent{Type: "streak", Days: streak, Points: bonus})
+		}
+		event, monthBalance, err := awardFullMonth(tx, userID, today)
+		if err != nil {
+			return err
+		}
+		if event != nil {
+			balance = monthBalance
+			total += event.Points
+			events = append(events, *event)
+		}
+		if total != reward {
+			if err := tx.Model(&record).Update("points_awarded", total).Error; err != nil {
+				return err
+			}
+		}
+
+		user.Points = balance
 		user.ConsecutiveDays = streak
 		user.LastSigninAt = &record.SigninDate
 
@@ -95,12 +147,127 @@ func (s *SignInController) DailySignIn(ctx *gin.Context) {
 		return
 	}
 
+	utils.LeaderboardStreakChanged(userID, streak)
+	awardBadges(s.db, userID, models.BadgeRuleSigninStreak)
+
+	if events == nil {
+		events = []signInEvent{}
+	}
 	utils.Success(ctx, gin.H{
 		"message":        "sign-in successful",
-		"points_awarded": reward,
+		"points_awarded": total,
+		"streak":         streak,
+		"events":         events,
 	})
 }
 
+// signInEvent is a bonus reached by a sign-in, returned so the UI can celebrate it.
+type signInEvent struct {
+	Type   string `json:"type"` // "streak" or "full_month"
+	Days   int    `json:"days,omitempty"`
+	Month  string `json:"month,omitempty"`
+	Points int    `json:"points"`
+}
+
+// awardFullMonth pays signin.FullMonthBonus when the user has signed in on every day of day's month;
+// day is a calendar date as returned by utils.CalendarDate.
+// The bonus is recorded against the month (ref_type "month", ref_id YYYYMM) and paid at most once.
+// It returns the bonus event, or nil when none is due, and the user's balance after it.
+func awardFullMonth(tx *gorm.DB, userID uint, day time.Time) (*signInEvent, int, error) {
+	bonus := config.Get().SigninFullMonthBonus
+	if bonus <= 0 {
+		return nil, 0, nil
+	}
+	monthStart := time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.Local)
+	nextMonth := monthStart.AddDate(0, 1, 0)
+	daysInMonth := nextMonth.AddDate(0, 0, -1).Day()
+	var signed int64
+	if err := tx.Model(&models.SignIn{}).
+		Where("user_id = ? AND signin_day >= ? AND signin_day < ?", userID, monthStart, nextMonth).
+		Distinct("signin_day").Count(&signed).Error; err != nil {
+		return nil, 0, err
+	}
+	if int(signed) < daysInMonth {
+		return nil, 0, nil
+	}
+	ref := uint(day.Year()*100 + int(day.Month()))
+	var paid int64
+	if err := tx.Model(&models.PointsTransaction{}).
+		Where("user_id = ? AND reason = ? AND ref_type = ? AND ref_id = ?", userID, models.PointsReasonSigninMonth, "month", ref).
+		Count(&paid).Error; err != nil || paid > 0 {
+		return nil, 0, err
+	}
+	balance, err := utils.ApplyPoints(tx, utils.PointsChange{
+		UserID:  userID,
+		Amount:  bonus,
+		Reason:  models.PointsReasonSigninMonth,
+		RefType: "month",
+		RefID:   ref,
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return &signInEvent{Type: "full_month", Month: monthStart.Format("2006-01"), Points: bonus}, balance, nil
+}
+
+// SignInCalendar lists the days of ?month=YYYY-MM (default: this month) the user signed in on.
+func (s *SignInController) SignInCalendar(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		utils.Error(ctx, http.StatusUnauthorized, 40110, "unauthorized")
+		return
+	}
+	var user models.User
+	if err := s.db.Select("id", "consecutive_days", "timezone").First(&user, userID).Error; err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50031, "failed to load user")
+		return
+	}
+	loc := utils.UserLocation(user.Timezone)
+	today := utils.CalendarDate(time.Now(), loc)
+	monthStart := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.Local)
+	if m := strings.TrimSpace(ctx.Query("month")); m != "" {
+		t, err := time.ParseInLocation("2006-01", m, time.Local)
+		if err != nil {
+			utils.Error(ctx, http.StatusBadRequest, 40034, "month must be YYYY-MM")
+			return
+		}
+		monthStart = t
+	}
+	nextMonth := monthStart.AddDate(0, 1, 0)
+
+	var records []models.SignIn
+	if err := s.db.Where("user_id = ? AND signin_day >= ? AND signin_day < ?", userID, monthStart, nextMonth).
+		Order("signin_day ASC").Find(&records).Error; err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50034, "failed to load sign-ins")
+		return
+	}
+	days := make([]int, 0, len(records))
+	for _, r := range records {
+		days = append(days, r.SigninDay.Day())
+	}
+
+	cfg := config.Get()
+	resp := gin.H{
+		"month":            monthStart.Format("2006-01"),
+		"days":             days,
+		"days_in_month":    nextMonth.AddDate(0, 0, -1).Day(),
+		"consecutive_days": user.ConsecutiveDays,
+		"full_month_bonus": cfg.SigninFullMonthBonus,
+		"timezone":         loc.String(),
+	}
+	// Next streak milestone the user has not reached yet
+	next := 0
+	for d := range cfg.SigninStreakBonuses {
+		if d > user.ConsecutiveDays && (next == 0 || d < next) {
+			next = d
+		}
+	}
+	if next > 0 {
+		resp["next_milestone"] = gin.H{"days": next, "points": cfg.SigninStreakBonuses[next]}
+	}
+	utils.Success(ctx, resp)
+}
+
 // SignInStatus returns the user's streak and last sign-in time.
 func (s *SignInController) SignInStatus(ctx *gin.Context) {
 	userID, ok := getUserID(ctx)
@@ -123,14 +290,20 @@ func (s *SignInController) SignInStatus(ctx *gin.Context) {
 		"points":           user.Points,
 		"consecutive_days": user.ConsecutiveDays,
 		"last_signin_at":   user.LastSigninAt,
+		"makeup_cards":     user.MakeupCards,
+		"timezone":         utils.UserLocation(user.Timezone).String(),
 	})
 }
 
+// isSameDay compares the calendar days of a and b, which must be in the same zone.
 func isSameDay(a, b time.Time) bool {
-	return a.Year() == b.Year() && a.YearDay() == b.YearDay()
+	y1, m1, d1 := a.Date()
+	y2, m2, d2 := b.Date()
+	return y1 == y2 && m1 == m2 && d1 == d2
 }
 
+// isYesterday reports whether last falls on the calendar day before today. AddDate steps one
+// calendar day, where Add(-24h) can land on the wrong day across a DST change.
 func isYesterday(last, today time.Time) bool {
-	yesterday := today.Add(-24 * time.Hour)
-	return last.Year() == yesterday.Year() && last.YearDay() == yesterday.YearDay()
+	return isSameDay(last, today.AddDate(0, 0, -1))
 }
@@ -0,0 +1,252 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/cppla/aibbs/config"
+	"github.com/cppla/aibbs/models"
+	"github.com/cppla/aibbs/utils"
+)
+
+var (
+	errNoMakeupCards = errors.New("no make-up cards left")
+	errDaySigned     = errors.New("already signed in on that day")
+)
+
+// BuyMakeupCards exchanges points for make-up cards at signin.MakeupCardPrice each.
+func (s *SignInController) BuyMakeupCards(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		utils.Error(ctx, http.StatusUnauthorized, 40110, "unauthorized")
+		return
+	}
+	if sanctionBlocks(ctx, s.db, models.SanctionSuspend) {
+		return
+	}
+	var req struct {
+		Count int `json:"count"`
+	}
+	_ = ctx.ShouldBindJSON(&req)
+	if req.Count == 0 {
+		req.Count = 1
+	}
+	if req.Count < 0 || req.Count > 10 {
+		utils.Error(ctx, http.StatusBadRequest, 40036, "count must be between 1 and 10")
+		return
+	}
+	price := config.Get().SigninMakeupCardPrice
+	if price <= 0 {
+		utils.Error(ctx, http.StatusForbidden, 40304, "make-up cards are not for sale")
+		return
+	}
+	var cards, balance int
+	err := utils.Transaction(s.db, func(tx *gorm.DB) error {
+		var err error
+		balance, err = utils.ApplyPoints(tx, utils.PointsChange{
+			UserID: userID,
+			Amount: -price * req.Count,
+			Reason: models.PointsReasonMakeupCard,
+			Note:   strconv.Itoa(req.Count) + " cards",
+		})
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).UpdateColumn("makeup_cards", gorm.Expr("makeup_cards + ?", req.Count)).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.User{}).Where("id = ?", userID).Select("makeup_cards").Scan(&cards).Error
+	})
+	if err != nil {
+		if errors.Is(err, utils.ErrInsufficientPoints) {
+			utils.Error(ctx, http.StatusConflict, 40963, "not enough points")
+			return
+		}
+		utils.Error(ctx, http.StatusInternalServerError, 50035, "failed to buy make-up cards")
+		return
+	}
+	utils.Success(ctx, gin.H{"makeup_cards": cards, "points": balance})
+}
+
+// GrantMakeupCards lets admins give (or with a negative count, take back) make-up cards.
+func (s *SignInController) GrantMakeupCards(ctx *gin.Context) {
+	var req struct {
+		Count int    `json:"count" binding:"required"`
+		Note  string `json:"note"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.Error(ctx, http.StatusBadRequest, 40036, "count is required")
+		return
+	}
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		utils.Error(ctx, http.StatusBadRequest, 40031, "invalid user id")
+		return
+	}
+	var user models.User
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "makeup_cards").First(&user, id).Error; err != nil {
+			return err
+		}
+		user.MakeupCards += req.Count
+		if user.MakeupCards < 0 {
+			user.MakeupCards = 0
+		}
+		return tx.Model(&models.User{}).Where("id = ?", user.ID).UpdateColumn("makeup_cards", user.MakeupCards).Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.Error(ctx, http.StatusNotFound, 40462, "user not found")
+			return
+		}
+		utils.Error(ctx, http.StatusInternalServerError, 50035, "failed to update make-up cards")
+		return
+	}
+	recordModeration(ctx, s.db, "user.makeup_cards", "user", user.ID, strings.TrimSpace(req.Note), gin.H{"count": req.Count})
+	utils.Success(ctx, gin.H{"makeup_cards": user.MakeupCards})
+}
+
+// MakeupSignIn spends a make-up card to fill a missed day ({"date":"YYYY-MM-DD"}) within
+// signin.MakeupLookbackDays. Streaks are recomputed from the sign-in history afterwards.
+func (s *SignInController) MakeupSignIn(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		utils.Error(ctx, http.StatusUnauthorized, 40110, "unauthorized")
+		return
+	}
+	var req struct {
+		Date string `json:"date" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.Error(ctx, http.StatusBadRequest, 40035, "date is required")
+		return
+	}
+	var profile models.User
+	if err := s.db.Select("id", "timezone", "created_at").First(&profile, userID).Error; err != nil {
+		utils.Error(ctx, http.StatusNotFound, 40401, "user not found")
+		return
+	}
+	loc := utils.UserLocation(profile.Timezone)
+	today := utils.CalendarDate(time.Now(), loc)
+	day, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(req.Date), time.Local)
+	if err != nil {
+		utils.Error(ctx, http.StatusBadRequest, 40035, "date must be YYYY-MM-DD")
+		return
+	}
+	lookback := config.Get().SigninMakeupLookbackDays
+	if !day.Before(today) || day.Before(today.AddDate(0, 0, -lookback)) {
+		utils.Error(ctx, http.StatusBadRequest, 40035, "date must be one of the last "+strconv.Itoa(lookback)+" days")
+		return
+	}
+	// Streaks cannot be backfilled from before the account existed
+	if day.Before(utils.CalendarDate(profile.CreatedAt, loc)) {
+		utils.Error(ctx, http.StatusBadRequest, 40035, "date is before your registration")
+		return
+	}
+
+	var (
+		user   models.User
+		events []signInEvent
+	)
+	err = utils.Transaction(s.db, func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error; err != nil {
+			return err
+		}
+		if user.MakeupCards <= 0 {
+			return errNoMakeupCards
+		}
+		var count int64
+		if err := tx.Model(&models.SignIn{}).Where("user_id = ? AND signin_day = ?", userID, day).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return errDaySigned
+		}
+		if err := tx.Create(&models.SignIn{UserID: userID, SigninDate: time.Now(), SigninDay: day, Makeup: true}).Error; err != nil {
+			return err
+		}
+		streak, err := recomputeStreaks(tx, userID, day)
+		if err != nil {
+			return err
+		}
+		event, balance, err := awardFullMonth(tx, userID, day)
+		if err != nil {
+			return err
+		}
+		if event != nil {
+			user.Points = balance
+			events = append(events, *event)
+		}
+		user.MakeupCards--
+		user.ConsecutiveDays = streak
+		return tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"makeup_cards":     user.MakeupCards,
+			"consecutive_days": streak,
+		}).Error
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, errNoMakeupCards):
+			utils.Error(ctx, http.StatusConflict, 40964, err.Error())
+		case errors.Is(err, errDaySigned):
+			utils.Error(ctx, http.StatusConflict, 40965, err.Error())
+		default:
+			utils.Error(ctx, http.StatusInternalServerError, 50036, "failed to record make-up sign-in")
+		}
+		return
+	}
+	utils.LeaderboardStreakChanged(userID, user.ConsecutiveDays)
+	awardBadges(s.db, userID, models.BadgeRuleSigninStreak)
+	if events == nil {
+		events = []signInEvent{}
+	}
+	utils.Success(ctx, gin.H{
+		"message":          "make-up sign-in successful",
+		"consecutive_days": user.ConsecutiveDays,
+		"makeup_cards":     user.MakeupCards,
+		"events":           events,
+	})
+}
+
+// recomputeStreaks rewrites streak_achieved for the user's sign-ins from `from` onwards, continuing
+// the streak of the day before, and returns the streak of the latest sign-in.
+func recomputeStreaks(tx *gorm.DB, userID uint, from time.Time) (int, error) {
+	streak := 0
+	var prev models.SignIn
+	err := tx.Where("user_id = ? AND signin_day < ?", userID, from).Order("signin_day DESC").First(&prev).Error
+	switch {
+	case err == nil:
+		if isYesterday(prev.SigninDay, from) {
+			streak = prev.StreakAchieved
+		}
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return 0, err
+	}
+	var records []models.SignIn
+	if err := tx.Where("user_id = ? AND signin_day >= ?", userID, from).Order("signin_day ASC, id ASC").Find(&records).Error; err != nil {
+		return 0, err
+	}
+	last := from.AddDate(0, 0, -1)
+	for _, r := range records {
+		day := r.SigninDay
+		if isYesterday(last, day) {
+			streak++
+		} else {
+			streak = 1
+		}
+		last = day
+		if r.StreakAchieved != streak {
+			if err := tx.Model(&models.SignIn{}).Where("id = ?", r.ID).UpdateColumn("streak_achieved", streak).Error; err != nil {
+				return 0, err
+			}
+		}
+	}
+	return streak, nil
+}
@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/cppla/aibbs/config"
+	"github.com/cppla/aibbs/middleware"
+	"github.com/cppla/aibbs/models"
+	"github.com/cppla/aibbs/utils"
+)
+
+// spamVerdict is the routed outcome of the spam pipeline for one submission.
+type spamVerdict struct {
+	score  float64
+	held   bool
+	detail string
+}
+
+// SpamController exposes manual training of the Bayesian spam filter.
+type SpamController struct {
+	db *gorm.DB
+}
+
+// NewSpamController builds a SpamController.
+func NewSpamController(db *gorm.DB) *SpamController {
+	return &SpamController{db: db}
+}
+
+// TrainSpam labels a post or comment as spam or ham for the Bayesian filter.
+func (s *SpamController) TrainSpam(ctx *gin.Context) {
+	var req struct {
+		TargetType string `json:"target_type" binding:"required"`
+		TargetID   uint   `json:"target_id" binding:"required"`
+		Label      string `json:"label" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.Error(ctx, http.StatusBadRequest, 40016, "invalid request payload")
+		return
+	}
+	label := strings.ToLower(strings.TrimSpace(req.Label))
+	if label != "spam" && label != "ham" {
+		utils.Error(ctx, http.StatusBadRequest, 40017, "label must be spam or ham")
+		return
+	}
+	if !config.Get().SpamBayesEnabled {
+		utils.Error(ctx, http.StatusConflict, 40961, "bayesian filter is disabled")
+		return
+	}
+	if err := trainSpamTarget(s.db, strings.ToLower(strings.TrimSpace(req.TargetType)), req.TargetID, label == "spam"); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.Error(ctx, http.StatusNotFound, 40470, "target not found")
+			return
+		}
+		utils.Error(ctx, http.StatusBadRequest, 40018, err.Error())
+		return
+	}
+	utils.Success(ctx, gin.H{"message": "trained as " + label})
+}
+
+// spamCheck scores a new or edited post or a new comment. Above spam.BlockScore it writes a 400 and returns false;
+// above spam.ReviewScore the verdict is held for review. Trusted members and moderators are not scored.
+func spamCheck(ctx *gin.Context, db *gorm.DB, kind string, userID uint, category, title, content string) (spamVerdict, bool) {
+	if middleware.HasPermission(ctx, models.PermContentTrusted, category) || middleware.HasPermission(ctx, models.PermContentApprove, category) {
+		return spamVerdict{}, true
+	}
+	in := &utils.SpamInput{Kind: kind, UserID: userID, Title: title, Content: content, IP: ctx.ClientIP()}
+	var user models.User
+	if err := db.Select("id", "created_at").First(&user, userID).Error; err == nil {
+		in.CreatedAt = user.CreatedAt
+	}
+	score, signals := utils.ScoreSpam(db, in)
+	v := spamVerdict{score: score, detail: utils.FormatSpamSignals(score, signals)}
+	cfg := config.Get()
+	pct := int(score * 100)
+	if pct >= cfg.SpamBlockScore {
+		if utils.Sugar != nil {
+			utils.Sugar.Infof("spam blocked user=%d %s: %s", userID, kind, v.detail)
+		}
+		utils.Error(ctx, http.StatusBadRequest, 40015, "content flagged as spam")
+		return v, false
+	}
+	v.held = pct >= cfg.SpamReviewScore
+	return v, true
+}
+
+// fileHeldReport queues an item held by the content filter and/or the spam scorer.
+func fileHeldReport(db *gorm.DB, targetType string, id uint, matches []utils.FilterMatch, spam spamVerdict) {
+	switch {
+	case len(matches) > 0 && spam.held:
+		fileSystemReport(db, targetType, id, reportReasonFilter, filterDetail(matches)+"; "+spam.detail)
+	case len(matches) > 0:
+		fileSystemReport(db, targetType, id, reportReasonFilter, filterDetail(matches))
+	case spam.held:
+		fileSystemReport(db, targetType, id, reportReasonSpam, spam.detail)
+	}
+}
+
+// trainSpamTarget feeds a post or comment to the Bayesian filter. Unscoped: spam is usually deleted
+// before or right after it is labelled.
+func trainSpamTarget(db *gorm.DB, targetType string, id uint, isSpam bool) error {
+	switch targetType {
+	case models.ReportTargetPost:
+		var post models.Post
+		if err := db.Unscoped().Select("id", "title", "content").First(&post, id).Error; err != nil {
+			return err
+		}
+		utils.TrainSpam(spamItem(targetType, id), post.Title, post.Content, isSpam)
+	case models.ReportTargetComment:
+		var cmt models.Comment
+		if err := db.Unscoped().Select("id", "content").First(&cmt, id).Error; err != nil {
+			return err
+		}
+		utils.TrainSpam(spamItem(targetType, id), "", cmt.Content, isSpam)
+	default:
+		return errors.New("target_type must be post or comment")
+	}
+	return nil
+}
+
+// spamItem names a post or comment in the Bayesian filter's record of trained items.
+func spamItem(targetType string, id uint) string {
+	return targetType + ":" + strconv.FormatUint(uint64(id), 10)
+}
@@ -74,7 +74,7 @@ func (s *StatsController) GetPostStats(ctx *gin.Context) {
 	}
 
 	var commentsCount int64
-	if err := s.db.Model(&models.Comment{}).Where("post_id = ?", id).Count(&commentsCount).Error; err != nil {
+	if err := s.db.Model(&models.Comment{}).Where("post_id = ? AND hidden = ? AND status = ?", id, false, models.StatusPublished).Count(&commentsCount).Error; err != nil {
 		commentsCount = 0
 	}
 
@@ -0,0 +1,187 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/cppla/aibbs/config"
+	"github.com/cppla/aibbs/models"
+	"github.com/cppla/aibbs/utils"
+)
+
+var errTipDailyCap = errors.New("daily tip limit reached")
+
+// tipRequest is the payload of the tip endpoints.
+type tipRequest struct {
+	Amount  int    `json:"amount" binding:"required"`
+	Message string `json:"message"`
+}
+
+// TipPost tips points to a post's author.
+func (p *PostController) TipPost(ctx *gin.Context) {
+	var post models.Post
+	if err := p.db.Select("id", "user_id", "status", "hidden").
+		Where("status = ? AND hidden = ?", models.StatusPublished, false).First(&post, ctx.Param("id")).Error; err != nil {
+		utils.Error(ctx, http.StatusNotFound, 40401, "post not found")
+		return
+	}
+	p.tip(ctx, models.Tip{ToUserID: post.UserID, TargetType: models.ReportTargetPost, TargetID: post.ID, PostID: post.ID})
+}
+
+// TipComment tips points to a comment's author.
+func (p *PostController) TipComment(ctx *gin.Context) {
+	var cmt models.Comment
+	if err := p.db.Select("id", "post_id", "user_id", "status", "hidden").
+		Where("status = ? AND hidden = ?", models.StatusPublished, false).First(&cmt, ctx.Param("commentId")).Error; err != nil {
+		utils.Error(ctx, http.StatusNotFound, 40420, "comment not found")
+		return
+	}
+	// Comments of hidden or unpublished posts are not visible, so they cannot be tipped either
+	var visible int64
+	if err := p.db.Model(&models.Post{}).Where("id = ? AND status = ? AND hidden = ?", cmt.PostID, models.StatusPublished, false).
+		Count(&visible).Error; err != nil || visible == 0 {
+		utils.Error(ctx, http.StatusNotFound, 40401, "post not found")
+		return
+	}
+	p.tip(ctx, models.Tip{ToUserID: cmt.UserID, TargetType: models.ReportTargetComment, TargetID: cmt.ID, PostID: cmt.PostID})
+}
+
+// tip transfers the requested amount from the current user to tip.ToUserID and records the tip.
+func (p *PostController) tip(ctx *gin.Context, tip models.Tip) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		utils.Error(ctx, http.StatusUnauthorized, 40110, "unauthorized")
+		return
+	}
+	if sanctionBlocks(ctx, p.db, models.SanctionSuspend) {
+		return
+	}
+	var req tipRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.Error(ctx, http.StatusBadRequest, 40054, "invalid request payload")
+		return
+	}
+	cfg := config.Get()
+	if req.Amount < cfg.TipMin || req.Amount > cfg.TipMax {
+		utils.Error(ctx, http.StatusBadRequest, 40052, fmt.Sprintf("tip amount must be between %d and %d points", cfg.TipMin, cfg.TipMax))
+		return
+	}
+	if tip.ToUserID == userID {
+		utils.Error(ctx, http.StatusBadRequest, 40053, "cannot tip yourself")
+		return
+	}
+	// Deleted accounts are soft-deleted, and the ghost account only holds content of deleted users
+	var author models.User
+	if err := p.db.Select("id", "ghost").First(&author, tip.ToUserID).Error; err != nil {
+		utils.Error(ctx, http.StatusNotFound, 40407, "author not found")
+		return
+	}
+	if author.Ghost {
+		utils.Error(ctx, http.StatusConflict, 40969, "the author's account has been deleted")
+		return
+	}
+	tip.FromUserID, tip.Amount = userID, req.Amount
+	tip.Message = utils.Sanitize(req.Message)
+	if len([]rune(tip.Message)) > 255 {
+		tip.Message = string([]rune(tip.Message)[:255])
+	}
+
+	var balance int
+	err := utils.Transaction(p.db, func(tx *gorm.DB) error {
+		// Lock both users up front: the tipper's row serializes the daily cap check below
+		if _, err := utils.LockUsers(tx, userID, tip.ToUserID); err != nil {
+			return err
+		}
+		if cfg.TipDailyCap > 0 {
+			now := time.Now().In(utils.SiteLocation())
+			midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+			var today int
+			if err := tx.Model(&models.Tip{}).Where("from_user_id = ? AND created_at >= ?", userID, midnight).
+				Select("COALESCE(SUM(amount), 0)").Scan(&today).Error; err != nil {
+				return err
+			}
+			if today+tip.Amount > cfg.TipDailyCap {
+				return errTipDailyCap
+			}
+		}
+		var err error
+		balance, tip.TxnID, err = utils.TransferPoints(tx, utils.PointsTransfer{
+			FromID:  userID,
+			ToID:    tip.ToUserID,
+			Amount:  tip.Amount,
+			Reason:  models.PointsReasonTip,
+			RefType: tip.TargetType,
+			RefID:   tip.TargetID,
+			Note:    tip.Message,
+		})
+		if err != nil {
+			return err
+		}
+		return tx.Create(&tip).Error
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, errTipDailyCap):
+			utils.Error(ctx, http.StatusConflict, 40968, fmt.Sprintf("daily tip limit of %d points reached", cfg.TipDailyCap))
+		case errors.Is(err, utils.ErrInsufficientPoints):
+			utils.Error(ctx, http.StatusConflict, 40963, "not enough points")
+		default:
+			utils.Error(ctx, http.StatusInternalServerError, 50197, "failed to send tip")
+		}
+		return
+	}
+	utils.CacheDelete("cache:post:detail:" + strconv.Itoa(int(tip.PostID)))
+	target := "帖子"
+	if tip.TargetType == models.ReportTargetComment {
+		target = "评论"
+	}
+	body := "获得 " + strconv.Itoa(tip.Amount) + " 积分"
+	if tip.Message != "" {
+		body += "：" + tip.Message
+	}
+	notify(p.db, tip.ToUserID, models.NotificationTipReceived, "有人打赏了你的"+target, body,
+		"/post-"+strconv.Itoa(int(tip.PostID))+"-1")
+	utils.Success(ctx, gin.H{"tip": tip, "balance": balance})
+}
+
+// tipSummary aggregates the tips of a post for GetPost: the post's own tips per tipper (largest first,
+// at most 20) and the tip total of each comment.
+func tipSummary(db *gorm.DB, postID uint) gin.H {
+	type tipper struct {
+		UserID    uint   `json:"user_id"`
+		Username  string `json:"username"`
+		AvatarURL string `json:"avatar_url"`
+		Amount    int    `json:"amount"`
+	}
+	var total struct {
+		Total int
+		Count int
+	}
+	db.Model(&models.Tip{}).Where("post_id = ? AND target_type = ?", postID, models.ReportTargetPost).
+		Select("COALESCE(SUM(amount), 0) AS total, COUNT(*) AS count").Scan(&total)
+	tippers := []tipper{}
+	if total.Count > 0 {
+		db.Table("tips").Joins("JOIN users ON users.id = tips.from_user_id").
+			Where("tips.post_id = ? AND tips.target_type = ?", postID, models.ReportTargetPost).
+			Select("tips.from_user_id AS user_id, users.username, users.avatar_url, SUM(tips.amount) AS amount").
+			Group("tips.from_user_id, users.username, users.avatar_url").
+			Order("amount DESC, user_id ASC").Limit(20).Scan(&tippers)
+	}
+	var rows []struct {
+		TargetID uint
+		Total    int
+	}
+	db.Model(&models.Tip{}).Where("post_id = ? AND target_type = ?", postID, models.ReportTargetComment).
+		Select("target_id, SUM(amount) AS total").Group("target_id").Scan(&rows)
+	comments := make(map[uint]int, len(rows))
+	for _, r := range rows {
+		comments[r.TargetID] = r.Total
+	}
+	return gin.H{"total": total.Total, "count": total.Count, "tippers": tippers, "comments": comments}
+}
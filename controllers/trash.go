@@ -0,0 +1,195 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/cppla/aibbs/config"
+	"github.com/cppla/aibbs/middleware"
+	"github.com/cppla/aibbs/models"
+	"github.com/cppla/aibbs/utils"
+)
+
+// trashRecord soft-deletes a post or comment, recording who removed it and why.
+func trashRecord(db *gorm.DB, record interface{}, actorID uint, reason string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(record).Updates(map[string]interface{}{"deleted_by": actorID, "deleted_reason": reason}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(record).Error
+	})
+}
+
+// ListTrash lists deleted posts (?type=post, default) or comments. Moderators with the matching
+// delete.any permission see everything; other users see only their own deleted content.
+func (p *PostController) ListTrash(ctx *gin.Context) {
+	userID, ok := getUserID(ctx)
+	if !ok {
+		utils.Error(ctx, http.StatusUnauthorized, 40114, "unauthorized")
+		return
+	}
+	page, pageSize := parsePagination(ctx.Query("page"), ctx.Query("page_size"))
+	var (
+		total int64
+		items interface{}
+		err   error
+	)
+	if ctx.Query("type") == models.ReportTargetComment {
+		var comments []models.Comment
+		q := p.db.Unscoped().Model(&models.Comment{}).Where("deleted_at IS NOT NULL")
+		if !middleware.HasPermission(ctx, models.PermCommentDeleteAny, "") {
+			q = q.Where("user_id = ?", userID)
+		}
+		if err = q.Count(&total).Error; err == nil {
+			err = q.Preload("User").Order("deleted_at DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&comments).Error
+		}
+		items = comments
+	} else {
+		var posts []models.Post
+		q := p.db.Unscoped().Model(&models.Post{}).Where("deleted_at IS NOT NULL")
+		if !middleware.HasPermission(ctx, models.PermPostDeleteAny, "") {
+			q = q.Where("user_id = ?", userID)
+		}
+		if err = q.Count(&total).Error; err == nil {
+			err = q.Preload("User").Order("deleted_at DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&posts).Error
+		}
+		items = posts
+	}
+	if err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50180, "failed to list trash")
+		return
+	}
+	utils.Success(ctx, gin.H{
+		"items":          items,
+		"retention_days": config.Get().TrashRetentionDays,
+		"pagination": gin.H{
+			"page":        page,
+			"page_size":   pageSize,
+			"total":       total,
+			"total_pages": int((total + int64(pageSize) - 1) / int64(pageSize)),
+		},
+	})
+}
+
+// RestorePost brings a post back from the trash. Authors may only undo their own deletions.
+func (p *PostController) RestorePost(ctx *gin.Context) {
+	var post models.Post
+	if err := p.db.Unscoped().Where("deleted_at IS NOT NULL").First(&post, ctx.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.Error(ctx, http.StatusNotFound, 40406, "post not found in trash")
+			return
+		}
+		utils.Error(ctx, http.StatusInternalServerError, 50181, "failed to load post")
+		return
+	}
+	moderated, ok := canRestore(ctx, post.UserID, post.DeletedBy, models.PermPostDeleteAny, post.Category)
+	if !ok {
+		return
+	}
+	if err := restoreRecord(p.db, &post); err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50182, "failed to restore post")
+		return
+	}
+	if moderated {
+		recordModeration(ctx, p.db, "post.restore", models.ReportTargetPost, post.ID, moderationReason(ctx), post)
+	}
+	countPost(&post, 1)
+	invalidatePostCaches(&post)
+	post.DeletedAt, post.DeletedBy, post.DeletedReason = gorm.DeletedAt{}, 0, ""
+	utils.Success(ctx, gin.H{"post": post})
+}
+
+// RestoreComment brings a comment back from the trash; its post must not be deleted.
+func (p *PostController) RestoreComment(ctx *gin.Context) {
+	var cmt models.Comment
+	if err := p.db.Unscoped().Where("deleted_at IS NOT NULL").First(&cmt, ctx.Param("commentId")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.Error(ctx, http.StatusNotFound, 40421, "comment not found in trash")
+			return
+		}
+		utils.Error(ctx, http.StatusInternalServerError, 50181, "failed to load comment")
+		return
+	}
+	var post models.Post
+	if err := p.db.Select("id", "category").First(&post, cmt.PostID).Error; err != nil {
+		utils.Error(ctx, http.StatusConflict, 40962, "restore the post first")
+		return
+	}
+	moderated, ok := canRestore(ctx, cmt.UserID, cmt.DeletedBy, models.PermCommentDeleteAny, post.Category)
+	if !ok {
+		return
+	}
+	if err := restoreRecord(p.db, &cmt); err != nil {
+		utils.Error(ctx, http.StatusInternalServerError, 50182, "failed to restore comment")
+		return
+	}
+	if moderated {
+		recordModeration(ctx, p.db, "comment.restore", models.ReportTargetComment, cmt.ID, moderationReason(ctx), cmt)
+	}
+	utils.CacheDelete("cache:post:detail:" + strconv.Itoa(int(cmt.PostID)))
+	cmt.DeletedAt, cmt.DeletedBy, cmt.DeletedReason = gorm.DeletedAt{}, 0, ""
+	utils.Success(ctx, gin.H{"comment": cmt})
+}
+
+// canRestore allows moderators holding perm for the category, and owners who deleted the item themselves.
+func canRestore(ctx *gin.Context, ownerID, deletedBy uint, perm, category string) (moderated, ok bool) {
+	uid, _ := getUserID(ctx)
+	if uid == ownerID && deletedBy == ownerID {
+		return false, true
+	}
+	if middleware.HasPermission(ctx, perm, category) {
+		return uid != ownerID, true
+	}
+	utils.Error(ctx, http.StatusForbidden, 40303, "only a moderator can restore this")
+	return false, false
+}
+
+func restoreRecord(db *gorm.DB, record interface{}) error {
+	return db.Unscoped().Model(record).Updates(map[string]interface{}{"deleted_at": nil, "deleted_by": 0, "deleted_reason": ""}).Error
+}
+
+// StartTrashPurgeJob periodically removes trashed posts and comments past the retention period.
+func StartTrashPurgeJob(db *gorm.DB, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			PurgeTrash(db)
+			<-ticker.C
+		}
+	}()
+}
+
+// PurgeTrash permanently deletes content trashed more than moderation.TrashRetentionDays ago.
+// A negative retention keeps the trash forever.
+func PurgeTrash(db *gorm.DB) {
+	days := config.Get().TrashRetentionDays
+	if days < 0 {
+		return
+	}
+	cutoff := time.Now().AddDate(0, 0, -days)
+	// Comments first; purged posts take their remaining comments with them via the foreign key
+	res := db.Unscoped().Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).Delete(&models.Comment{})
+	if res.Error != nil {
+		if utils.Sugar != nil {
+			utils.Sugar.Warnf("trash purge of comments failed: %v", res.Error)
+		}
+		return
+	}
+	comments := res.RowsAffected
+	res = db.Unscoped().Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).Delete(&models.Post{})
+	if res.Error != nil {
+		if utils.Sugar != nil {
+			utils.Sugar.Warnf("trash purge of posts failed: %v", res.Error)
+		}
+		return
+	}
+	if (comments > 0 || res.RowsAffected > 0) && utils.Sugar != nil {
+		utils.Sugar.Infof("trash purge removed %d posts and %d comments", res.RowsAffected, comments)
+	}
+}
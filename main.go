@@ -1,7 +1,11 @@
 package main
 
 import (
+	"os"
+	"time"
+
 	"github.com/cppla/aibbs/config"
+	"github.com/cppla/aibbs/controllers"
 	"github.com/cppla/aibbs/models"
 	"github.com/cppla/aibbs/routes"
 	"github.com/cppla/aibbs/utils"
@@ -16,10 +20,28 @@ func main() {
 	}
 
 	// Auto-migrate models (no local upload tracking since using external storage)
-	db := config.InitDatabase(&models.User{}, &models.Post{}, &models.Comment{}, &models.SignIn{}, &models.PageView{})
+	db := config.InitDatabase(&models.User{}, &models.Post{}, &models.Comment{}, &models.SignIn{}, &models.PageView{}, &models.UserIdentity{}, &models.LoginEvent{}, &models.Permission{}, &models.Role{}, &models.UserRole{}, &models.ModerationAction{}, &models.Report{}, &models.UserSanction{}, &models.IPBan{}, &models.FilterRule{}, &models.Notification{}, &models.PointsTransaction{}, &models.Badge{}, &models.UserBadge{}, &models.HiddenContentPurchase{}, &models.Tip{})
+
+	// `aibbs rebuild-leaderboards` recomputes the Redis leaderboards from MySQL and exits
+	if len(os.Args) > 1 && os.Args[1] == "rebuild-leaderboards" {
+		if err := utils.RebuildLeaderboards(db); err != nil {
+			utils.Sugar.Fatalf("leaderboard rebuild failed: %v", err)
+		}
+		utils.Sugar.Info("leaderboards rebuilt")
+		return
+	}
 
 	r := routes.SetupRouter(db)
 
+	// Purge accounts whose self-service deletion grace period has elapsed
+	controllers.StartAccountPurgeJob(db, time.Hour)
+	// Permanently remove trashed posts and comments past their retention period
+	controllers.StartTrashPurgeJob(db, time.Hour)
+	// Recompute leaderboards so broken streaks drop out and missed updates are corrected
+	controllers.StartLeaderboardRebuildJob(db, time.Hour)
+	// Award rule-based badges, including membership anniversaries that no event triggers
+	controllers.StartBadgeAwardJob(db, time.Hour)
+
 	utils.Sugar.Infof("Starting server on port %s (graceful)", cfg.AppPort)
 	if err := utils.GraceServer(":"+cfg.AppPort, r); err != nil {
 		utils.Sugar.Fatalf("server stopped with error: %v", err)
@@ -6,6 +6,8 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/cppla/aibbs/config"
+	"github.com/cppla/aibbs/models"
 	"github.com/cppla/aibbs/utils"
 )
 
@@ -53,6 +55,51 @@ func AuthRequired() gin.HandlerFunc {
 			return
 		}
 
+		// Tokens issued before a ban or forced logout are rejected
+		if cutoff := utils.SessionsRevokedSince(claims.UserID); cutoff > 0 && claims.IssuedAt != nil && claims.IssuedAt.Unix() <= cutoff {
+			utils.Error(ctx, http.StatusUnauthorized, 40104, "token revoked")
+			ctx.Abort()
+			return
+		}
+		if ban, banned := utils.ActiveSanctions(config.DB(), claims.UserID)[models.SanctionBan]; banned {
+			utils.Respond(ctx, http.StatusForbidden, 40340, "account banned", gin.H{"reason": ban.Reason, "expires_at": ban.ExpiresAt})
+			ctx.Abort()
+			return
+		}
+
+		ctx.Set(ContextUserIDKey, claims.UserID)
+		ctx.Set(ContextUsernameKey, claims.Username)
+		ctx.Next()
+	}
+}
+
+// OptionalAuth identifies the caller when a valid bearer token is sent but never rejects the request,
+// so public endpoints can tailor their response to the viewer.
+func OptionalAuth() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		parts := strings.SplitN(ctx.GetHeader("Authorization"), " ", 2)
+		if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+			ctx.Next()
+			return
+		}
+		tokenString := strings.TrimSpace(parts[1])
+		if tokenString == "" || utils.IsTokenBlacklisted(tokenString) {
+			ctx.Next()
+			return
+		}
+		claims, err := utils.ParseToken(tokenString)
+		if err != nil {
+			ctx.Next()
+			return
+		}
+		if cutoff := utils.SessionsRevokedSince(claims.UserID); cutoff > 0 && claims.IssuedAt != nil && claims.IssuedAt.Unix() <= cutoff {
+			ctx.Next()
+			return
+		}
+		if _, banned := utils.ActiveSanctions(config.DB(), claims.UserID)[models.SanctionBan]; banned {
+			ctx.Next()
+			return
+		}
 		ctx.Set(ContextUserIDKey, claims.UserID)
 		ctx.Set(ContextUsernameKey, claims.Username)
 		ctx.Next()
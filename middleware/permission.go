@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/cppla/aibbs/config"
+	"github.com/cppla/aibbs/utils"
+)
+
+// RequirePermission aborts with 403 unless the authenticated user holds perm site-wide.
+// It must run after AuthRequired.
+func RequirePermission(perm string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !HasPermission(ctx, perm, "") {
+			utils.Error(ctx, http.StatusForbidden, 40330, "permission denied")
+			ctx.Abort()
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// HasPermission checks perm for the current user; a non-empty category also accepts category-scoped grants.
+func HasPermission(ctx *gin.Context, perm, category string) bool {
+	v, exists := ctx.Get(ContextUserIDKey)
+	if !exists {
+		return false
+	}
+	userID, ok := v.(uint)
+	if !ok {
+		return false
+	}
+	return utils.HasPermission(config.DB(), userID, perm, category)
+}
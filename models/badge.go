@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// Badge award rules. A badge with a rule is awarded automatically once the user's metric reaches
+// Threshold; a badge without one is only granted by admins.
+const (
+	BadgeRuleManual          = ""
+	BadgeRulePostCount       = "post_count"       // published posts
+	BadgeRuleSigninStreak    = "signin_streak"    // longest sign-in streak reached
+	BadgeRuleMembershipYears = "membership_years" // whole years since registration
+)
+
+// BadgeRules lists the valid award rules.
+var BadgeRules = []string{BadgeRuleManual, BadgeRulePostCount, BadgeRuleSigninStreak, BadgeRuleMembershipYears}
+
+// Badge is an achievement shown on user profiles.
+type Badge struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Slug        string    `gorm:"size:64;not null;uniqueIndex" json:"slug"`
+	Name        string    `gorm:"size:64;not null" json:"name"`
+	Description string    `gorm:"size:255" json:"description"`
+	Icon        string    `gorm:"size:512" json:"icon"` // image URL or emoji
+	Rule        string    `gorm:"size:32;not null;default:''" json:"rule"`
+	Threshold   int       `gorm:"default:0" json:"threshold"`
+	Enabled     bool      `gorm:"default:true" json:"enabled"` // disabled badges are neither awarded nor shown
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// UserBadge records a badge held by a user. Revoked rows are kept so automatic rules do not award the
+// badge again; granting it manually clears RevokedAt.
+type UserBadge struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"not null;uniqueIndex:idx_user_badge" json:"user_id"`
+	BadgeID   uint       `gorm:"not null;uniqueIndex:idx_user_badge" json:"badge_id"`
+	GrantedBy uint       `gorm:"default:0" json:"granted_by"` // 0 for automatic awards
+	Note      string     `gorm:"size:255" json:"note,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	Badge     Badge      `gorm:"constraint:OnDelete:CASCADE;" json:"badge"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -1,6 +1,10 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 // Comment represents a reply to a post.
 type Comment struct {
@@ -8,7 +12,14 @@ type Comment struct {
 	PostID    uint      `gorm:"index;not null" json:"post_id"`
 	UserID    uint      `gorm:"index;not null" json:"user_id"`
 	Content   string    `gorm:"type:text;not null" json:"content"`
+	Hidden    bool      `gorm:"default:false" json:"hidden"` // hidden pending review after reports
+	Status    string    `gorm:"size:16;not null;default:'published'" json:"status"`
+	SpamScore float64   `gorm:"default:0" json:"-"` // combined spam classifier score at submission, 0-1
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
-	User      User      `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"author"`
+	// Soft delete: deleted comments stay in threads as placeholders until purged
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"deleted_at"`
+	DeletedBy     uint           `gorm:"default:0" json:"deleted_by,omitempty"`
+	DeletedReason string         `gorm:"size:512" json:"deleted_reason,omitempty"`
+	User          User           `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"author"`
 }
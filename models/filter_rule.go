@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// Filter rule match kinds.
+const (
+	FilterKindLiteral = "literal" // case-insensitive substring
+	FilterKindRegex   = "regex"   // Go RE2 syntax
+	FilterKindPinyin  = "pinyin"  // pinyin or Chinese word, matched by sound on syllable boundaries, ignoring case and separators
+)
+
+// Filter rule actions, from strongest to weakest.
+const (
+	FilterActionBlock   = "block"   // reject the submission
+	FilterActionReview  = "review"  // accept but hide until a moderator reviews it
+	FilterActionReplace = "replace" // mask the match with ***
+)
+
+// Fields a filter rule can apply to.
+const (
+	FilterFieldTitle     = "title"
+	FilterFieldContent   = "content"
+	FilterFieldComment   = "comment"
+	FilterFieldUsername  = "username"
+	FilterFieldSignature = "signature"
+)
+
+// FilterFields lists every field checked by the content filter.
+var FilterFields = []string{FilterFieldTitle, FilterFieldContent, FilterFieldComment, FilterFieldUsername, FilterFieldSignature}
+
+// FilterRule is an admin-managed prohibited word or pattern.
+type FilterRule struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Pattern   string    `gorm:"size:255;not null" json:"pattern"`
+	Kind      string    `gorm:"size:16;not null;default:'literal'" json:"kind"`
+	Action    string    `gorm:"size:16;not null;default:'block'" json:"action"`
+	Fields    string    `gorm:"size:128;not null;default:''" json:"fields"` // comma separated; empty means all fields
+	Enabled   bool      `gorm:"not null;default:true" json:"enabled"`
+	Note      string    `gorm:"size:255" json:"note"`
+	CreatedBy uint      `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
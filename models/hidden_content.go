@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// HiddenContentPurchase records that a user paid to unlock a post's points-gated hidden content.
+// One purchase per user and post; it stays valid if the author later changes the price.
+type HiddenContentPurchase struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	PostID    uint      `gorm:"not null;uniqueIndex:idx_hidden_purchase,priority:1" json:"post_id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_hidden_purchase,priority:2;index" json:"user_id"`
+	Price     int       `gorm:"not null" json:"price"`
+	TxnID     string    `gorm:"size:40" json:"txn_id"` // points transfer that paid the author
+	CreatedAt time.Time `json:"created_at"`
+}
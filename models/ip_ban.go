@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// IP ban scopes.
+const (
+	IPBanScopeAll   = "all"   // block every request
+	IPBanScopeWrite = "write" // block only state-changing requests
+)
+
+// IPBan blocks a single address or CIDR range (IPv4 or IPv6) until ExpiresAt (nil means permanent).
+type IPBan struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	CIDR      string     `gorm:"size:64;not null;uniqueIndex" json:"cidr"` // normalized, e.g. 203.0.113.0/24
+	Scope     string     `gorm:"size:16;not null;default:'all'" json:"scope"`
+	Note      string     `gorm:"size:512" json:"note"`
+	ExpiresAt *time.Time `gorm:"index" json:"expires_at"`
+	CreatedBy uint       `json:"created_by"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
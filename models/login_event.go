@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// LoginEvent records a login attempt for the account's login history and new-device alerts.
+type LoginEvent struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	UserID     uint      `gorm:"index:idx_login_events_user_created;not null;default:0" json:"user_id"`
+	Method     string    `gorm:"size:32;not null" json:"method"`
+	IP         string    `gorm:"size:45" json:"ip"`
+	Country    string    `gorm:"size:64" json:"country"`
+	UserAgent  string    `gorm:"size:512" json:"user_agent"`
+	DeviceHash string    `gorm:"size:64;index" json:"-"`
+	Outcome    string    `gorm:"size:16;not null" json:"outcome"`
+	CreatedAt  time.Time `gorm:"index:idx_login_events_user_created" json:"created_at"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrModerationLogImmutable is returned when code tries to modify an audit entry.
+var ErrModerationLogImmutable = errors.New("moderation actions are append-only")
+
+// ModerationAction is an append-only audit entry for a moderator action.
+type ModerationAction struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	ActorID    uint      `gorm:"index;not null" json:"actor_id"`
+	ActorName  string    `gorm:"size:64" json:"actor_name"`
+	Action     string    `gorm:"size:32;index;not null" json:"action"`
+	TargetType string    `gorm:"size:16;not null;index:idx_moderation_target" json:"target_type"`
+	TargetID   uint      `gorm:"not null;index:idx_moderation_target" json:"target_id"`
+	Reason     string    `gorm:"size:512" json:"reason"`
+	Snapshot   string    `gorm:"type:mediumtext" json:"snapshot"` // JSON of the target before the action
+	IP         string    `gorm:"size:45" json:"ip"`
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`
+}
+
+// BeforeUpdate rejects updates so the log stays append-only.
+func (m *ModerationAction) BeforeUpdate(tx *gorm.DB) error {
+	return ErrModerationLogImmutable
+}
+
+// BeforeDelete rejects deletes so the log stays append-only.
+func (m *ModerationAction) BeforeDelete(tx *gorm.DB) error {
+	return ErrModerationLogImmutable
+}
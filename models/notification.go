@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Notification types.
+const (
+	NotificationContentApproved = "content.approved"
+	NotificationContentRejected = "content.rejected"
+	NotificationBadgeAwarded    = "badge.awarded"
+	NotificationHiddenPurchased = "hidden.purchased"
+	NotificationTipReceived     = "tip.received"
+)
+
+// Notification is an in-site message to a user, such as the outcome of a moderation review.
+type Notification struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"not null;index:idx_notification_user_read" json:"user_id"`
+	Type      string     `gorm:"size:32;not null" json:"type"`
+	Title     string     `gorm:"size:255;not null" json:"title"`
+	Body      string     `gorm:"size:1000" json:"body"`
+	Link      string     `gorm:"size:255" json:"link"`
+	ReadAt    *time.Time `gorm:"index:idx_notification_user_read" json:"read_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// SystemAccountID is the ledger's system account: the source of awards and the sink of charges.
+// It has no users row and its balance is not tracked.
+const SystemAccountID uint = 0
+
+// Points transaction reason codes.
+const (
+	PointsReasonOpening = "opening_balance" // balance carried over when the ledger was introduced
+	PointsReasonSignIn  = "signin"
+	PointsReasonAdjust  = "admin_adjust"
+	// Sign-in bonuses for reaching a streak milestone and for signing in every day of a month
+	PointsReasonSigninStreak = "signin_streak"
+	PointsReasonSigninMonth  = "signin_full_month"
+	PointsReasonMakeupCard   = "makeup_card" // buying make-up sign-in cards
+	// Paying a post author to unlock the post's points-gated hidden content (both entries of the transfer)
+	PointsReasonHiddenContent = "hidden_content"
+	PointsReasonTip           = "tip" // 打赏: a reader tipping a post or comment author
+)
+
+// PointsTransaction is one ledger entry. Every change is written as a balanced pair sharing TxnID:
+// one entry per account, amounts summing to zero, so the ledger always reconciles with users.points.
+type PointsTransaction struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	TxnID          string    `gorm:"size:40;not null;index" json:"txn_id"`
+	UserID         uint      `gorm:"not null;index:idx_points_user_time,priority:1" json:"user_id"`
+	CounterpartyID uint      `gorm:"not null;default:0" json:"counterparty_id"`
+	Amount         int       `gorm:"not null" json:"amount"`        // signed: positive credits the account
+	BalanceAfter   int       `gorm:"not null" json:"balance_after"` // always 0 for the system account
+	Reason         string    `gorm:"size:32;not null;index" json:"reason"`
+	RefType        string    `gorm:"size:16" json:"ref_type,omitempty"` // what the change relates to, e.g. signin, post
+	RefID          uint      `json:"ref_id,omitempty"`
+	ActorID        uint      `json:"actor_id,omitempty"` // admin who made a manual adjustment
+	Note           string    `gorm:"size:255" json:"note,omitempty"`
+	CreatedAt      time.Time `gorm:"index:idx_points_user_time,priority:2" json:"created_at"`
+}
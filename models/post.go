@@ -1,6 +1,17 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Publication states shared by posts and comments.
+const (
+	StatusPublished = "published"
+	StatusPending   = "pending" // held by pre-moderation until a moderator approves it
+	StatusRejected  = "rejected"
+)
 
 // Post represents a forum post created by a user.
 type Post struct {
@@ -9,9 +20,19 @@ type Post struct {
 	Title       string    `gorm:"size:255;not null" json:"title"`
 	Content     string    `gorm:"type:text;not null" json:"content"`
 	Category    string    `gorm:"size:32;default:'综合'" json:"category"`
-	Attachments string    `gorm:"type:text" json:"attachments"` // JSON array of attachment URLs
+	Attachments string    `gorm:"type:text" json:"attachments"`      // JSON array of attachment URLs
+	Locked      bool      `gorm:"default:false" json:"locked"`       // locked posts accept no edits or new comments
+	Pinned      bool      `gorm:"default:false;index" json:"pinned"` // pinned posts are listed first
+	Hidden      bool      `gorm:"default:false" json:"hidden"`       // hidden pending review after reports
+	Status      string    `gorm:"size:16;not null;default:'published';index" json:"status"`
+	SpamScore   float64   `gorm:"default:0" json:"-"`                  // combined spam classifier score at submission, 0-1
+	MovedTo     uint      `gorm:"default:0" json:"moved_to,omitempty"` // set on redirect stubs left behind by move and merge
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
-	User        User      `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"author"`
-	Comments    []Comment `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"comments"`
+	// Soft delete: trashed posts can be restored until the retention purge removes them
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"deleted_at"`
+	DeletedBy     uint           `gorm:"default:0" json:"deleted_by,omitempty"`
+	DeletedReason string         `gorm:"size:512" json:"deleted_reason,omitempty"`
+	User          User           `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"author"`
+	Comments      []Comment      `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"comments"`
 }
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// Report target types.
+const (
+	ReportTargetPost    = "post"
+	ReportTargetComment = "comment"
+	ReportTargetUser    = "user"
+	ReportTargetMessage = "message"
+)
+
+// Report workflow states.
+const (
+	ReportStatusOpen      = "open"
+	ReportStatusClaimed   = "claimed"
+	ReportStatusResolved  = "resolved"
+	ReportStatusDismissed = "dismissed"
+)
+
+// ReportReasons lists the accepted reason codes.
+var ReportReasons = []string{"spam", "abuse", "harassment", "illegal", "nsfw", "other"}
+
+// Report is a member's flag on a post, comment or user, reviewed through the moderation queue.
+type Report struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	ReporterID uint       `gorm:"not null;uniqueIndex:idx_report_active" json:"reporter_id"`
+	TargetType string     `gorm:"size:16;not null;uniqueIndex:idx_report_active;index:idx_report_target" json:"target_type"`
+	TargetID   uint       `gorm:"not null;uniqueIndex:idx_report_active;index:idx_report_target" json:"target_id"`
+	ReasonCode string     `gorm:"size:32;not null" json:"reason_code"`
+	Detail     string     `gorm:"size:1000" json:"detail"`
+	Status     string     `gorm:"size:16;not null;default:'open';index" json:"status"`
+	ClaimedBy  uint       `json:"claimed_by"`
+	ResolvedBy uint       `json:"resolved_by"`
+	Resolution string     `gorm:"size:1000" json:"resolution"`
+	ResolvedAt *time.Time `json:"resolved_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	// Active is true while the report is open or claimed and NULL once closed: idx_report_active only
+	// stops duplicate open reports, so a member can report the same target again after a decision
+	Active *bool `gorm:"uniqueIndex:idx_report_active" json:"-"`
+}
@@ -0,0 +1,66 @@
+package models
+
+import "time"
+
+// Built-in role names.
+const (
+	RoleAdmin             = "admin"
+	RoleModerator         = "moderator"
+	RoleCategoryModerator = "category_moderator"
+	RoleTrusted           = "trusted"
+)
+
+// Permission names checked by handlers and middleware.
+const (
+	PermPostDeleteAny    = "post.delete.any"
+	PermPostLock         = "post.lock"
+	PermPostPin          = "post.pin"
+	PermPostMove         = "post.move"
+	PermCommentDeleteAny = "comment.delete.any"
+	PermUserList         = "user.list"
+	PermRoleAssign       = "role.assign"
+	PermContentTrusted   = "content.trusted"
+	PermModerationView   = "moderation.view"
+	PermReportReview     = "report.review"
+	PermUserSanction     = "user.sanction"
+	PermIPBan            = "ip.ban"
+	PermContentFilter    = "content.filter"
+	PermContentApprove   = "content.approve"
+	PermPointsAdjust     = "points.adjust"
+	PermBadgeManage      = "badge.manage"
+)
+
+// DefaultRolePermissions seeds the built-in roles. Admin implicitly receives every permission.
+var DefaultRolePermissions = map[string][]string{
+	RoleAdmin:             {PermPostDeleteAny, PermPostLock, PermPostPin, PermPostMove, PermCommentDeleteAny, PermUserList, PermRoleAssign, PermContentTrusted, PermModerationView, PermReportReview, PermUserSanction, PermIPBan, PermContentFilter, PermContentApprove, PermPointsAdjust, PermBadgeManage},
+	RoleModerator:         {PermPostDeleteAny, PermPostLock, PermPostPin, PermPostMove, PermCommentDeleteAny, PermContentTrusted, PermModerationView, PermReportReview, PermUserSanction, PermContentApprove},
+	RoleCategoryModerator: {PermPostDeleteAny, PermPostLock, PermPostPin, PermPostMove, PermCommentDeleteAny, PermContentApprove},
+	RoleTrusted:           {PermContentTrusted},
+}
+
+// Role groups permissions that can be granted to users.
+type Role struct {
+	ID          uint         `gorm:"primaryKey" json:"id"`
+	Name        string       `gorm:"size:32;not null;uniqueIndex" json:"name"`
+	Description string       `gorm:"size:255" json:"description"`
+	Permissions []Permission `gorm:"many2many:role_permissions;" json:"permissions,omitempty"`
+	CreatedAt   time.Time    `json:"created_at"`
+}
+
+// Permission is a named capability such as "post.delete.any".
+type Permission struct {
+	ID          uint   `gorm:"primaryKey" json:"id"`
+	Name        string `gorm:"size:64;not null;uniqueIndex" json:"name"`
+	Description string `gorm:"size:255" json:"description"`
+}
+
+// UserRole assigns a role to a user. A non-empty Category limits the grant to that post category.
+type UserRole struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_user_role_scope" json:"user_id"`
+	RoleID    uint      `gorm:"not null;uniqueIndex:idx_user_role_scope" json:"role_id"`
+	Category  string    `gorm:"size:32;not null;default:'';uniqueIndex:idx_user_role_scope" json:"category"`
+	GrantedBy uint      `json:"granted_by"`
+	Role      Role      `gorm:"constraint:OnDelete:CASCADE;" json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
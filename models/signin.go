@@ -5,9 +5,11 @@ import "time"
 // SignIn stores daily sign-in records for users.
 type SignIn struct {
 	ID             uint      `gorm:"primaryKey" json:"id"`
-	UserID         uint      `gorm:"index;not null" json:"user_id"`
+	UserID         uint      `gorm:"index;not null;index:idx_signin_user_day,priority:1" json:"user_id"`
 	SigninDate     time.Time `gorm:"index;not null" json:"signin_date"`
+	SigninDay      time.Time `gorm:"type:date;index:idx_signin_user_day,priority:2" json:"signin_day"` // calendar day in the user's timezone
 	PointsAwarded  int       `json:"points_awarded"`
 	StreakAchieved int       `json:"streak_achieved"`
+	Makeup         bool      `gorm:"default:false" json:"makeup"` // filled in later with a make-up card
 	CreatedAt      time.Time `json:"created_at"`
 }
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// Tip records points a user gave to the author of a post or comment (打赏).
+type Tip struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	FromUserID uint      `gorm:"not null;index:idx_tip_from_created,priority:1" json:"from_user_id"`
+	ToUserID   uint      `gorm:"not null;index" json:"to_user_id"`
+	TargetType string    `gorm:"size:16;not null" json:"target_type"` // post or comment
+	TargetID   uint      `gorm:"not null" json:"target_id"`
+	PostID     uint      `gorm:"not null;index" json:"post_id"` // the post itself or the commented post
+	Amount     int       `gorm:"not null" json:"amount"`
+	Message    string    `gorm:"size:255" json:"message,omitempty"`
+	TxnID      string    `gorm:"size:40" json:"txn_id"` // points transfer that paid the author
+	CreatedAt  time.Time `gorm:"index:idx_tip_from_created,priority:2" json:"created_at"`
+}
@@ -8,23 +8,31 @@ import (
 
 // User represents a forum user. Passwords are stored as bcrypt hashes only.
 type User struct {
-	ID              uint           `gorm:"primaryKey" json:"id"`
-	Username        string         `gorm:"size:64;not null" json:"username"`
-	Email           string         `gorm:"size:255" json:"email"`
-	PasswordHash    string         `gorm:"size:255" json:"-"`
-	Provider        string         `gorm:"size:32" json:"provider"`
-	ProviderID      string         `gorm:"size:255" json:"provider_id"`
-	RegisterIP      string         `gorm:"size:45" json:"register_ip"`
-	AvatarURL       string         `gorm:"size:512" json:"avatar_url"`
-	Signature       string         `gorm:"size:255" json:"signature"`
-	Points          int            `gorm:"default:0" json:"points"`
-	LastSigninAt    *time.Time     `json:"last_signin_at"`
-	ConsecutiveDays int            `gorm:"default:0" json:"consecutive_days"`
-	CreatedAt       time.Time      `json:"created_at"`
-	UpdatedAt       time.Time      `json:"updated_at"`
-	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
-	Comments        []Comment      `json:"-"`
-	Posts           []Post         `json:"-"`
+	ID              uint       `gorm:"primaryKey" json:"id"`
+	Username        string     `gorm:"size:64;not null" json:"username"`
+	Email           string     `gorm:"size:255" json:"email"`
+	PasswordHash    string     `gorm:"size:255" json:"-"`
+	Provider        string     `gorm:"size:32" json:"provider"`
+	ProviderID      string     `gorm:"size:255" json:"provider_id"`
+	RegisterIP      string     `gorm:"size:45" json:"register_ip"`
+	AvatarURL       string     `gorm:"size:512" json:"avatar_url"`
+	Signature       string     `gorm:"size:255" json:"signature"`
+	Points          int        `gorm:"default:0" json:"points"`
+	LastSigninAt    *time.Time `json:"last_signin_at"`
+	ConsecutiveDays int        `gorm:"default:0" json:"consecutive_days"`
+	MakeupCards     int        `gorm:"default:0" json:"makeup_cards"` // 补签卡: each fills one missed sign-in day
+	Timezone        string     `gorm:"size:64" json:"timezone"`       // IANA zone for sign-in days; empty uses signin.Timezone
+	// TimezoneChangedAt rate-limits timezone changes, which would otherwise shift sign-in days
+	TimezoneChangedAt *time.Time `json:"-"`
+	// Self-service deletion: set when the user requests it, purged after the grace period
+	DeletionRequestedAt *time.Time     `json:"-"`
+	DeletionMode        string         `gorm:"size:16" json:"-"`
+	Ghost               bool           `gorm:"default:false" json:"-"` // the shared account keeping content of deleted users
+	CreatedAt           time.Time      `json:"created_at"`
+	UpdatedAt           time.Time      `json:"updated_at"`
+	DeletedAt           gorm.DeletedAt `gorm:"index" json:"-"`
+	Comments            []Comment      `json:"-"`
+	Posts               []Post         `json:"-"`
 }
 
 // BeforeCreate hook ensures timestamps are set even when not provided.
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// UserIdentity links an external login (OAuth provider or Telegram) to a local account.
+// A user may own several identities; each provider account maps to at most one user.
+type UserIdentity struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	UserID     uint      `gorm:"index;not null" json:"user_id"`
+	Provider   string    `gorm:"size:32;not null;uniqueIndex:idx_identity_provider_uid" json:"provider"`
+	ProviderID string    `gorm:"size:255;not null;uniqueIndex:idx_identity_provider_uid" json:"provider_id"`
+	Email      string    `gorm:"size:255" json:"email"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Sanction types, from most to least restrictive.
+const (
+	SanctionBan     = "ban"     // cannot log in; existing sessions are revoked
+	SanctionSuspend = "suspend" // read-only: no posts, comments or uploads
+	SanctionMute    = "mute"    // cannot comment
+)
+
+// UserSanction restricts an account until ExpiresAt (nil means permanent) or until revoked.
+type UserSanction struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"index;not null" json:"user_id"`
+	Type      string     `gorm:"size:16;not null" json:"type"`
+	Reason    string     `gorm:"size:512;not null" json:"reason"`
+	ExpiresAt *time.Time `gorm:"index" json:"expires_at"`
+	IssuedBy  uint       `json:"issued_by"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	RevokedBy uint       `json:"revoked_by"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Active reports whether the sanction is in force at t.
+func (s UserSanction) Active(t time.Time) bool {
+	return s.RevokedAt == nil && (s.ExpiresAt == nil || s.ExpiresAt.After(t))
+}
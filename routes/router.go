@@ -12,6 +12,7 @@ import (
 	"github.com/cppla/aibbs/config"
 	"github.com/cppla/aibbs/controllers"
 	"github.com/cppla/aibbs/middleware"
+	"github.com/cppla/aibbs/models"
 	"github.com/cppla/aibbs/utils"
 )
 
@@ -29,6 +30,12 @@ func SetupRouter(db *gorm.DB) *gin.Engine {
 	}
 
 	r := gin.New()
+	// ClientIP only reads forwarding headers set by a trusted proxy, otherwise anyone could pick their IP
+	r.RemoteIPHeaders = []string{"CF-Connecting-IP", "X-Forwarded-For", "X-Real-IP"}
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		utils.Sugar.Warnf("invalid app.TrustedProxies, trusting no proxy: %v", err)
+		_ = r.SetTrustedProxies(nil)
+	}
 	// Replace default console logger with file-based zap logger
 	ginLogPath := cfg.GinPath
 	// Use application log level as reference
@@ -58,6 +65,8 @@ func SetupRouter(db *gorm.DB) *gin.Engine {
 	r.Use(cors.New(corsCfg))
 	// Country allow/deny filter (deny has priority)
 	r.Use(middleware.CountryFilter())
+	// Admin-managed IP / CIDR bans (scope all or write-only)
+	r.Use(middleware.IPBanFilter())
 	// Record PV after each request
 	r.Use(middleware.PageViewRecorder(db))
 
@@ -88,6 +97,17 @@ func SetupRouter(db *gorm.DB) *gin.Engine {
 	signController := controllers.NewSignInController(db)
 	statsController := controllers.NewStatsController(db)
 	configController := controllers.NewConfigController()
+	roleController := controllers.NewRoleController(db)
+	moderationController := controllers.NewModerationController(db)
+	reportController := controllers.NewReportController(db)
+	sanctionController := controllers.NewSanctionController(db)
+	ipBanController := controllers.NewIPBanController(db)
+	filterRuleController := controllers.NewFilterRuleController(db)
+	notificationController := controllers.NewNotificationController(db)
+	pointsController := controllers.NewPointsController(db)
+	spamController := controllers.NewSpamController(db)
+	leaderboardController := controllers.NewLeaderboardController(db)
+	badgeController := controllers.NewBadgeController(db)
 
 	api := r.Group("/api/v1")
 
@@ -99,15 +119,24 @@ func SetupRouter(db *gorm.DB) *gin.Engine {
 	authGroup.GET("/captcha", authController.Captcha)
 	authGroup.POST("/captcha/verify", authController.CaptchaVerify)
 	authGroup.POST("/telegram", authController.TelegramLogin)
+	authGroup.GET("/oauth/providers", authController.OAuthProviders)
 	authGroup.GET("/oauth/:provider/login", authController.OAuthRedirect)
 	authGroup.GET("/oauth/:provider/callback", authController.OAuthCallback)
 	authGroup.POST("/logout", middleware.AuthRequired(), authController.Logout)
 	authGroup.GET("/me", middleware.AuthRequired(), authController.Me)
 	authGroup.PATCH("/profile", middleware.AuthRequired(), authController.UpdateProfile)
+	authGroup.GET("/login-history", middleware.AuthRequired(), authController.LoginHistory)
+	authGroup.GET("/identities", middleware.AuthRequired(), authController.ListIdentities)
+	authGroup.GET("/oauth/:provider/link", middleware.AuthRequired(), authController.OAuthLink)
+	authGroup.POST("/identities/telegram", middleware.AuthRequired(), authController.LinkTelegram)
+	authGroup.DELETE("/identities/:id", middleware.AuthRequired(), authController.UnlinkIdentity)
+	authGroup.POST("/account/export", middleware.AuthRequired(), authController.ExportAccount)
+	authGroup.DELETE("/account", middleware.AuthRequired(), authController.RequestAccountDeletion)
+	authGroup.POST("/account/cancel-deletion", middleware.AuthRequired(), authController.CancelAccountDeletion)
 
 	postsGroup := api.Group("/posts")
 	postsGroup.GET("", postController.ListPosts)
-	postsGroup.GET("/:id", postController.GetPost)
+	postsGroup.GET("/:id", middleware.OptionalAuth(), postController.GetPost)
 
 	// Public stats endpoint
 	api.GET("/stats", statsController.GetStats)
@@ -115,8 +144,12 @@ func SetupRouter(db *gorm.DB) *gin.Engine {
 	// Public config endpoint
 	api.GET("/config/footer", configController.GetFooter)
 	api.GET("/config/notice", configController.GetNotice)
+	// Public moderation log (when moderation.PublicLog is enabled)
+	api.GET("/moderation/log", moderationController.PublicLog)
 	// Public user posts
 	api.GET("/users/:id/posts", postController.ListUserPosts)
+	api.GET("/leaderboards/:kind", middleware.OptionalAuth(), leaderboardController.GetLeaderboard)
+	api.GET("/badges", badgeController.ListBadges)
 
 	// Public user by username
 	api.GET("/user/by-username/:username", authController.GetUserPublicByUsername)
@@ -126,16 +159,76 @@ func SetupRouter(db *gorm.DB) *gin.Engine {
 	// Public user profile
 	api.GET("/users/:id", authController.GetUserPublic)
 
-	protected.GET("/users", authController.ListUsers)
+	protected.GET("/users", middleware.RequirePermission(models.PermUserList), authController.ListUsers)
 	protected.POST("/upload", postController.UploadAttachment)
 	protected.POST("/posts", postController.CreatePost)
 	protected.PUT("/posts/:id", postController.UpdatePost)
 	protected.DELETE("/posts/:id", postController.DeletePost)
+	protected.POST("/posts/:id/lock", postController.LockPost)
+	protected.POST("/posts/:id/unlock", postController.UnlockPost)
+	protected.POST("/posts/:id/pin", postController.PinPost)
+	protected.POST("/posts/:id/unpin", postController.UnpinPost)
+	protected.POST("/posts/:id/move", postController.MovePost)
+	protected.POST("/posts/:id/merge", postController.MergePost)
+	protected.POST("/posts/:id/split", postController.SplitPost)
+	protected.POST("/posts/:id/restore", postController.RestorePost)
+	protected.POST("/posts/:id/purchase", postController.PurchaseHiddenContent)
+	protected.POST("/posts/:id/tips", postController.TipPost)
+	protected.POST("/posts/:id/approve", postController.ApprovePost)
+	protected.POST("/posts/:id/reject", postController.RejectPost)
 	protected.POST("/posts/:id/comments", postController.CreateComment)
 	protected.DELETE("/comments/:commentId", postController.DeleteComment)
+	protected.POST("/comments/:commentId/tips", postController.TipComment)
+	protected.POST("/comments/:commentId/approve", postController.ApproveComment)
+	protected.POST("/comments/:commentId/reject", postController.RejectComment)
+	protected.POST("/comments/:commentId/restore", postController.RestoreComment)
+	protected.GET("/trash", postController.ListTrash)
+	protected.GET("/notifications", notificationController.ListNotifications)
+	protected.POST("/notifications/:id/read", notificationController.MarkNotificationRead)
+	protected.POST("/notifications/read-all", notificationController.MarkAllNotificationsRead)
+	protected.GET("/points/history", pointsController.PointsHistory)
 	protected.GET("/users/me/posts", postController.ListMyPosts)
+	protected.POST("/reports", reportController.CreateReport)
 	protected.POST("/signin/daily", signController.DailySignIn)
 	protected.GET("/signin/status", signController.SignInStatus)
+	protected.GET("/signin/calendar", signController.SignInCalendar)
+	protected.POST("/signin/makeup", signController.MakeupSignIn)
+	protected.POST("/signin/makeup-cards", signController.BuyMakeupCards)
+
+	// Administration
+	adminGroup := protected.Group("/admin")
+	adminGroup.GET("/roles", middleware.RequirePermission(models.PermRoleAssign), roleController.ListRoles)
+	adminGroup.GET("/users/:id/roles", middleware.RequirePermission(models.PermRoleAssign), roleController.ListUserRoles)
+	adminGroup.POST("/users/:id/roles", middleware.RequirePermission(models.PermRoleAssign), roleController.AssignRole)
+	adminGroup.DELETE("/users/:id/roles/:assignmentId", middleware.RequirePermission(models.PermRoleAssign), roleController.RevokeRole)
+	adminGroup.GET("/moderation-actions", middleware.RequirePermission(models.PermModerationView), moderationController.SearchActions)
+	adminGroup.GET("/users/:id/sanctions", middleware.RequirePermission(models.PermUserSanction), sanctionController.ListUserSanctions)
+	adminGroup.POST("/users/:id/sanctions", middleware.RequirePermission(models.PermUserSanction), sanctionController.CreateSanction)
+	adminGroup.DELETE("/sanctions/:id", middleware.RequirePermission(models.PermUserSanction), sanctionController.RevokeSanction)
+	adminGroup.GET("/users/:id/points", middleware.RequirePermission(models.PermPointsAdjust), pointsController.UserPointsHistory)
+	adminGroup.POST("/users/:id/points", middleware.RequirePermission(models.PermPointsAdjust), pointsController.AdjustPoints)
+	adminGroup.POST("/users/:id/makeup-cards", middleware.RequirePermission(models.PermPointsAdjust), signController.GrantMakeupCards)
+	adminGroup.GET("/badges", middleware.RequirePermission(models.PermBadgeManage), badgeController.AdminListBadges)
+	adminGroup.POST("/badges", middleware.RequirePermission(models.PermBadgeManage), badgeController.CreateBadge)
+	adminGroup.PATCH("/badges/:id", middleware.RequirePermission(models.PermBadgeManage), badgeController.UpdateBadge)
+	adminGroup.DELETE("/badges/:id", middleware.RequirePermission(models.PermBadgeManage), badgeController.DeleteBadge)
+	adminGroup.POST("/users/:id/badges", middleware.RequirePermission(models.PermBadgeManage), badgeController.GrantBadge)
+	adminGroup.DELETE("/users/:id/badges/:badgeId", middleware.RequirePermission(models.PermBadgeManage), badgeController.RevokeBadge)
+	adminGroup.GET("/ip-bans", middleware.RequirePermission(models.PermIPBan), ipBanController.ListIPBans)
+	adminGroup.POST("/ip-bans", middleware.RequirePermission(models.PermIPBan), ipBanController.CreateIPBan)
+	adminGroup.PATCH("/ip-bans/:id", middleware.RequirePermission(models.PermIPBan), ipBanController.UpdateIPBan)
+	adminGroup.DELETE("/ip-bans/:id", middleware.RequirePermission(models.PermIPBan), ipBanController.DeleteIPBan)
+	adminGroup.GET("/filter-rules", middleware.RequirePermission(models.PermContentFilter), filterRuleController.ListFilterRules)
+	adminGroup.POST("/filter-rules", middleware.RequirePermission(models.PermContentFilter), filterRuleController.CreateFilterRule)
+	adminGroup.PATCH("/filter-rules/:id", middleware.RequirePermission(models.PermContentFilter), filterRuleController.UpdateFilterRule)
+	adminGroup.DELETE("/filter-rules/:id", middleware.RequirePermission(models.PermContentFilter), filterRuleController.DeleteFilterRule)
+	adminGroup.POST("/filter-rules/test", middleware.RequirePermission(models.PermContentFilter), filterRuleController.TestFilter)
+	adminGroup.GET("/pending", middleware.RequirePermission(models.PermContentApprove), postController.ListPending)
+	adminGroup.POST("/spam/train", middleware.RequirePermission(models.PermReportReview), spamController.TrainSpam)
+	adminGroup.GET("/reports", middleware.RequirePermission(models.PermReportReview), reportController.ListReports)
+	adminGroup.POST("/reports/:id/claim", middleware.RequirePermission(models.PermReportReview), reportController.ClaimReport)
+	adminGroup.POST("/reports/:id/resolve", middleware.RequirePermission(models.PermReportReview), reportController.ResolveReport)
+	adminGroup.POST("/reports/:id/dismiss", middleware.RequirePermission(models.PermReportReview), reportController.DismissReport)
 
 	r.NoRoute(func(ctx *gin.Context) {
 		path := ctx.Request.URL.Path
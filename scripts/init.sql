@@ -14,6 +14,12 @@ CREATE TABLE IF NOT EXISTS users (
     points INT DEFAULT 0,
     last_signin_at DATETIME NULL,
     consecutive_days INT DEFAULT 0,
+    makeup_cards INT NOT NULL DEFAULT 0,
+    timezone VARCHAR(64) NULL,
+    timezone_changed_at DATETIME NULL,
+    deletion_requested_at DATETIME NULL,
+    deletion_mode VARCHAR(16),
+    ghost TINYINT(1) NOT NULL DEFAULT 0,
     created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
     updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     deleted_at DATETIME NULL,
@@ -29,12 +35,24 @@ CREATE TABLE IF NOT EXISTS posts (
     content TEXT NOT NULL,
     category VARCHAR(32) DEFAULT '综合',
     attachments TEXT,
+    locked TINYINT(1) NOT NULL DEFAULT 0,
+    pinned TINYINT(1) NOT NULL DEFAULT 0,
+    hidden TINYINT(1) NOT NULL DEFAULT 0,
+    status VARCHAR(16) NOT NULL DEFAULT 'published',
+    spam_score DOUBLE NOT NULL DEFAULT 0,
+    moved_to BIGINT UNSIGNED NOT NULL DEFAULT 0,
     created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
     updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    deleted_at DATETIME NULL,
+    deleted_by BIGINT UNSIGNED NOT NULL DEFAULT 0,
+    deleted_reason VARCHAR(512) NOT NULL DEFAULT '',
     CONSTRAINT fk_posts_user FOREIGN KEY (user_id) REFERENCES users(id)
         ON DELETE CASCADE ON UPDATE CASCADE,
     INDEX idx_posts_user (user_id),
-    INDEX idx_posts_created_at (created_at)
+    INDEX idx_posts_created_at (created_at),
+    INDEX idx_posts_pinned (pinned),
+    INDEX idx_posts_status (status),
+    INDEX idx_posts_deleted_at (deleted_at)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
 
 CREATE TABLE IF NOT EXISTS comments (
@@ -42,26 +60,264 @@ CREATE TABLE IF NOT EXISTS comments (
     post_id BIGINT UNSIGNED NOT NULL,
     user_id BIGINT UNSIGNED NOT NULL,
     content TEXT NOT NULL,
+    hidden TINYINT(1) NOT NULL DEFAULT 0,
+    status VARCHAR(16) NOT NULL DEFAULT 'published',
+    spam_score DOUBLE NOT NULL DEFAULT 0,
     created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
     updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    deleted_at DATETIME NULL,
+    deleted_by BIGINT UNSIGNED NOT NULL DEFAULT 0,
+    deleted_reason VARCHAR(512) NOT NULL DEFAULT '',
     CONSTRAINT fk_comments_post FOREIGN KEY (post_id) REFERENCES posts(id)
         ON DELETE CASCADE ON UPDATE CASCADE,
     CONSTRAINT fk_comments_user FOREIGN KEY (user_id) REFERENCES users(id)
         ON DELETE CASCADE ON UPDATE CASCADE,
     INDEX idx_comments_post (post_id),
-    INDEX idx_comments_user (user_id)
+    INDEX idx_comments_user (user_id),
+    INDEX idx_comments_deleted_at (deleted_at)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
 
 CREATE TABLE IF NOT EXISTS sign_ins (
     id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
     user_id BIGINT UNSIGNED NOT NULL,
     signin_date DATETIME NOT NULL,
+    signin_day DATE NULL,
     points_awarded INT NOT NULL,
     streak_achieved INT NOT NULL,
+    makeup TINYINT(1) NOT NULL DEFAULT 0,
     created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
     CONSTRAINT fk_signins_user FOREIGN KEY (user_id) REFERENCES users(id)
         ON DELETE CASCADE ON UPDATE CASCADE,
-    INDEX idx_signins_user_date (user_id, signin_date)
+    INDEX idx_signins_user_date (user_id, signin_date),
+    INDEX idx_signin_user_day (user_id, signin_day)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- External login identities (OAuth providers / Telegram) linked to local accounts
+CREATE TABLE IF NOT EXISTS user_identities (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT UNSIGNED NOT NULL,
+    provider VARCHAR(32) NOT NULL,
+    provider_id VARCHAR(255) NOT NULL,
+    email VARCHAR(255),
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    CONSTRAINT fk_identities_user FOREIGN KEY (user_id) REFERENCES users(id)
+        ON DELETE CASCADE ON UPDATE CASCADE,
+    UNIQUE KEY idx_identity_provider_uid (provider, provider_id),
+    INDEX idx_user_identities_user_id (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- Login attempts per account (login history / new device alerts)
+CREATE TABLE IF NOT EXISTS login_events (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT UNSIGNED NOT NULL DEFAULT 0,
+    method VARCHAR(32) NOT NULL,
+    ip VARCHAR(45),
+    country VARCHAR(64),
+    user_agent VARCHAR(512),
+    device_hash VARCHAR(64),
+    outcome VARCHAR(16) NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_login_events_user_created (user_id, created_at),
+    INDEX idx_login_events_device_hash (device_hash)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS roles (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(32) NOT NULL,
+    description VARCHAR(255),
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY idx_roles_name (name)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS permissions (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(64) NOT NULL,
+    description VARCHAR(255),
+    UNIQUE KEY idx_permissions_name (name)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role_id BIGINT UNSIGNED NOT NULL,
+    permission_id BIGINT UNSIGNED NOT NULL,
+    PRIMARY KEY (role_id, permission_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS user_roles (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT UNSIGNED NOT NULL,
+    role_id BIGINT UNSIGNED NOT NULL,
+    category VARCHAR(32) NOT NULL DEFAULT '',
+    granted_by BIGINT UNSIGNED NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY idx_user_role_scope (user_id, role_id, category)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS moderation_actions (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    actor_id BIGINT UNSIGNED NOT NULL,
+    actor_name VARCHAR(64),
+    action VARCHAR(32) NOT NULL,
+    target_type VARCHAR(16) NOT NULL,
+    target_id BIGINT UNSIGNED NOT NULL,
+    reason VARCHAR(512),
+    snapshot MEDIUMTEXT,
+    ip VARCHAR(45),
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_moderation_actions_actor_id (actor_id),
+    INDEX idx_moderation_actions_action (action),
+    INDEX idx_moderation_target (target_type, target_id),
+    INDEX idx_moderation_actions_created_at (created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS reports (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    reporter_id BIGINT UNSIGNED NOT NULL,
+    target_type VARCHAR(16) NOT NULL,
+    target_id BIGINT UNSIGNED NOT NULL,
+    reason_code VARCHAR(32) NOT NULL,
+    detail VARCHAR(1000),
+    status VARCHAR(16) NOT NULL DEFAULT 'open',
+    claimed_by BIGINT UNSIGNED NOT NULL DEFAULT 0,
+    resolved_by BIGINT UNSIGNED NOT NULL DEFAULT 0,
+    resolution VARCHAR(1000),
+    resolved_at DATETIME NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    active TINYINT(1) NULL,
+    UNIQUE KEY idx_report_active (reporter_id, target_type, target_id, active),
+    INDEX idx_report_target (target_type, target_id),
+    INDEX idx_reports_status (status)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS user_sanctions (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT UNSIGNED NOT NULL,
+    type VARCHAR(16) NOT NULL,
+    reason VARCHAR(512) NOT NULL,
+    expires_at DATETIME NULL,
+    issued_by BIGINT UNSIGNED NOT NULL DEFAULT 0,
+    revoked_at DATETIME NULL,
+    revoked_by BIGINT UNSIGNED NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_user_sanctions_user_id (user_id),
+    INDEX idx_user_sanctions_expires_at (expires_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS ip_bans (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    cidr VARCHAR(64) NOT NULL,
+    scope VARCHAR(16) NOT NULL DEFAULT 'all',
+    note VARCHAR(512),
+    expires_at DATETIME NULL,
+    created_by BIGINT UNSIGNED NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY idx_ip_bans_cidr (cidr),
+    INDEX idx_ip_bans_expires_at (expires_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- Content filter rules (prohibited words / regex / pinyin)
+CREATE TABLE IF NOT EXISTS filter_rules (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    pattern VARCHAR(255) NOT NULL,
+    kind VARCHAR(16) NOT NULL DEFAULT 'literal',
+    action VARCHAR(16) NOT NULL DEFAULT 'block',
+    fields VARCHAR(128) NOT NULL DEFAULT '',
+    enabled TINYINT(1) NOT NULL DEFAULT 1,
+    note VARCHAR(255),
+    created_by BIGINT UNSIGNED NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- In-site notifications
+CREATE TABLE IF NOT EXISTS notifications (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT UNSIGNED NOT NULL,
+    type VARCHAR(32) NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    body VARCHAR(1000),
+    link VARCHAR(255),
+    read_at DATETIME NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_notification_user_read (user_id, read_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- Points ledger: balanced entry pairs per change; user_id 0 is the system account
+CREATE TABLE IF NOT EXISTS points_transactions (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    txn_id VARCHAR(40) NOT NULL,
+    user_id BIGINT UNSIGNED NOT NULL,
+    counterparty_id BIGINT UNSIGNED NOT NULL DEFAULT 0,
+    amount INT NOT NULL,
+    balance_after INT NOT NULL,
+    reason VARCHAR(32) NOT NULL,
+    ref_type VARCHAR(16),
+    ref_id BIGINT UNSIGNED,
+    actor_id BIGINT UNSIGNED,
+    note VARCHAR(255),
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_points_transactions_txn_id (txn_id),
+    INDEX idx_points_user_time (user_id, created_at),
+    INDEX idx_points_transactions_reason (reason)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- Badges and the badges held by users (revoked awards are kept so rules do not re-award them)
+CREATE TABLE IF NOT EXISTS badges (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    slug VARCHAR(64) NOT NULL,
+    name VARCHAR(64) NOT NULL,
+    description VARCHAR(255),
+    icon VARCHAR(512),
+    rule VARCHAR(32) NOT NULL DEFAULT '',
+    threshold INT NOT NULL DEFAULT 0,
+    enabled TINYINT(1) NOT NULL DEFAULT 1,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY idx_badges_slug (slug)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS user_badges (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT UNSIGNED NOT NULL,
+    badge_id BIGINT UNSIGNED NOT NULL,
+    granted_by BIGINT UNSIGNED NOT NULL DEFAULT 0,
+    note VARCHAR(255),
+    revoked_at DATETIME NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY idx_user_badge (user_id, badge_id),
+    CONSTRAINT fk_user_badges_badge FOREIGN KEY (badge_id) REFERENCES badges(id)
+        ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- Purchases of points-gated hidden post content, one per user and post
+CREATE TABLE IF NOT EXISTS hidden_content_purchases (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    post_id BIGINT UNSIGNED NOT NULL,
+    user_id BIGINT UNSIGNED NOT NULL,
+    price INT NOT NULL,
+    txn_id VARCHAR(40),
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY idx_hidden_purchase (post_id, user_id),
+    INDEX idx_hidden_content_purchases_user_id (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- Tips (打赏) from readers to post/comment authors
+CREATE TABLE IF NOT EXISTS tips (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    from_user_id BIGINT UNSIGNED NOT NULL,
+    to_user_id BIGINT UNSIGNED NOT NULL,
+    target_type VARCHAR(16) NOT NULL,
+    target_id BIGINT UNSIGNED NOT NULL,
+    post_id BIGINT UNSIGNED NOT NULL,
+    amount INT NOT NULL,
+    message VARCHAR(255),
+    txn_id VARCHAR(40),
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_tip_from_created (from_user_id, created_at),
+    INDEX idx_tips_to_user_id (to_user_id),
+    INDEX idx_tips_post_id (post_id)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
 
 -- Aggregated Page Views (for stats and PV middleware)
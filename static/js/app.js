@@ -383,7 +383,10 @@ async function fetchPost(id) {
     try {
         const data = await apiRequest(`${API_BASE}/posts/${id}`);
         // 兼容不同结构 {data:{post}}, {post}, 或直接对象
-        return data?.data?.post || data?.post || data?.data || data;
+        const post = data?.data?.post || data?.post || data?.data || data;
+        if (post && data?.data?.hidden_content) post.hidden_content = data.data.hidden_content;
+        if (post && data?.data?.tips) post.tips = data.data.tips;
+        return post;
     } catch (error) {
         console.error('Error fetching post:', error);
         return null;
@@ -500,10 +503,12 @@ function renderPostDetail(post) {
         <div class="card">
             <div class="card-body">
                 <h2 class="card-title">${post.title}</h2>
-                <div class="card-text">${DOMPurify.sanitize(renderMarkdown(post.content || ''))}</div>
+                <div class="card-text">${DOMPurify.sanitize(renderMarkdown(renderHiddenBlocks(post.content || '')))}</div>
+                ${hiddenContentActions(post, isAuthor)}
+                ${tipSection(post, isAuthor)}
                 
                 <p class="card-text"><small class="text-muted">👤 <a href="${authorHref}" style="text-decoration: none; color: inherit;">${authorName}</a>${createdLabel} · 📂 <a href="${catSlug ? '/categories/' + catSlug : '/'}" onclick="return handleCategoryLinkClick(event, '${cat}')" style="text-decoration: none; color: inherit;">${cat}</a></small></p>
-                ${(isAuthor || isAdmin) ? `<div class="mt-3">${isAuthor ? `<button class=\"btn btn-warning me-2\" onclick=\"editPost(${post.id})\">编辑</button>` : ''}<button class=\"btn btn-danger\" onclick=\"deletePost(${post.id})\">删除</button></div>` : ''}
+                ${(isAuthor || isAdmin) ? `<div class="mt-3">${isAuthor ? `<button class=\"btn btn-warning me-2\" onclick=\"editPost(${post.id})\">编辑</button>` : ''}<button class=\"btn btn-danger\" onclick=\"deletePost(${post.id}, ${isAuthor})\">删除</button></div>` : ''}
             </div>
         </div>
         <h4 class="mt-4">评论</h4>
@@ -522,13 +527,24 @@ function renderPostDetail(post) {
             const commentDiv = document.createElement('div');
             commentDiv.className = 'card mt-2';
             commentDiv.id = `comment-card-${comment.id}`;
+            if (comment.deleted_at) {
+                commentDiv.innerHTML = deletedCommentHTML();
+                commentsDiv.appendChild(commentDiv);
+                return;
+            }
             const canDelete = !!(currentUser && (currentUser.is_admin || currentUser.id === comment.user_id));
+            const commentTips = post.tips?.comments?.[comment.id] || 0;
+            const canTip = !!(currentUser && currentUser.id !== comment.user_id);
             commentDiv.innerHTML = `
                 <div class="card-body">
                     <p class="card-text">${DOMPurify.sanitize(comment.content || '')}</p>
                     <p class="card-text d-flex justify-content-between align-items-center">
                         <small class="text-muted">👤 <a href="${authorHref}" style="text-decoration: none; color: inherit;">${commentAuthor}</a>${commentLabel}</small>
-                        ${canDelete ? `<button class="btn btn-sm btn-outline-danger" onclick="deleteComment(${comment.id})">删除</button>` : ''}
+                        <span>
+                            ${commentTips ? `<small class="text-muted me-2">💰 ${commentTips}</small>` : ''}
+                            ${canTip ? `<button class="btn btn-sm btn-outline-warning me-1" onclick="tipTarget('comments', ${comment.id}, ${post.id})">打赏</button>` : ''}
+                            ${canDelete ? `<button class="btn btn-sm btn-outline-danger" onclick="deleteComment(${comment.id}, ${currentUser.id === comment.user_id})">删除</button>` : ''}
+                        </span>
                     </p>
                 </div>
             `;
@@ -574,6 +590,11 @@ async function submitComment(postId) {
         }
         // 局部插入新评论，不整帖刷新
         const comment = data?.data?.comment || data?.comment || data;
+        if (comment && (comment.status === 'pending' || comment.hidden)) {
+            notify('评论已提交，审核通过后显示', 'info', 4000);
+            textarea.value = '';
+            return;
+        }
         const commentsDiv = document.getElementById('comments');
         if (commentsDiv && comment) {
             const authorObj = comment.author || comment.user || currentUser || {};
@@ -589,7 +610,7 @@ async function submitComment(postId) {
                     <p class="card-text">${DOMPurify.sanitize(comment.content || '')}</p>
                     <p class="card-text d-flex justify-content-between align-items-center">
                         <small class="text-muted">👤 <a href="${authorHref}" style="text-decoration: none; color: inherit;">${commentAuthor}</a>${commentLabel}</small>
-                        ${currentUser ? `<button class="btn btn-sm btn-outline-danger" onclick="deleteComment(${comment.id})">删除</button>` : ''}
+                        ${currentUser ? `<button class="btn btn-sm btn-outline-danger" onclick="deleteComment(${comment.id}, true)">删除</button>` : ''}
                     </p>
                 </div>
             `;
@@ -619,18 +640,30 @@ async function submitComment(postId) {
 
 async function submitPost(title, content, category, attachments) {
     try {
-        await apiRequest(`${API_BASE}/posts`, {
+        const data = await apiRequest(`${API_BASE}/posts`, {
             method: 'POST',
             headers: { 'Content-Type': 'application/json' },
             body: JSON.stringify({ title, content, category, attachments })
         });
+        const post = data?.data?.post;
+        if (post && (post.status === 'pending' || post.hidden)) {
+            notify('帖子已提交，审核通过后公开显示', 'info', 4000);
+        }
         showHome(); // Refresh
     } catch (error) {
     notify('发帖失败: ' + error.message, 'error', 4000);
     }
 }
 
-async function deleteComment(commentId) {
+// 删除他人内容时需填写原因，写入管理日志
+function askModerationReason(isOwn) {
+    if (isOwn) return '';
+    const reason = (window.prompt('请输入删除原因（将记录到管理日志）') || '').trim();
+    if (!reason) return null;
+    return `?reason=${encodeURIComponent(reason)}`;
+}
+
+async function deleteComment(commentId, isOwn = false) {
     if (!currentUser) {
         notify('请先登录', 'warning');
         showLogin();
@@ -638,8 +671,10 @@ async function deleteComment(commentId) {
     }
     const ok = await confirmModal('确定要删除此评论吗？', { title: '删除确认', confirmText: '删除', confirmVariant: 'danger' });
     if (!ok) return;
+    const query = askModerationReason(isOwn);
+    if (query === null) return;
     try {
-        const resp = await fetch(`${API_BASE}/comments/${commentId}`, { method: 'DELETE', headers: { 'Authorization': `Bearer ${getToken()}` } });
+        const resp = await fetch(`${API_BASE}/comments/${commentId}${query}`, { method: 'DELETE', headers: { 'Authorization': `Bearer ${getToken()}` } });
         const data = await resp.json();
         if (!resp.ok || (data && data.code && data.code !== 0)) {
             const msg = data?.message || `HTTP ${resp.status}`;
@@ -647,12 +682,74 @@ async function deleteComment(commentId) {
             return;
         }
         const el = document.getElementById(`comment-card-${commentId}`);
-        if (el && el.parentNode) el.parentNode.removeChild(el);
+        if (el) el.innerHTML = deletedCommentHTML();
     } catch (e) {
         notify('删除失败：' + e.message, 'error', 4000);
     }
 }
 
+// Deleted comments keep their place in the thread as a placeholder
+// 已解锁的隐藏内容（[hide]...[/hide]）以引用块标出；未解锁部分由后端替换为提示
+function renderHiddenBlocks(md) {
+    return md.replace(/\[hide(?:=[^\]]*)?\]([\s\S]*?)\[\/hide\]/g, (_, inner) => `\n\n> 🔓 隐藏内容\n\n${inner}\n\n`);
+}
+
+function hiddenContentActions(post, isAuthor) {
+    const h = post.hidden_content;
+    if (!h || !h.locked || isAuthor) return '';
+    const parts = [];
+    if (h.price > 0 && !h.purchased) {
+        parts.push(currentUser
+            ? `<button class="btn btn-outline-primary btn-sm me-2" onclick="purchaseHiddenContent(${post.id}, ${h.price})">支付 ${h.price} 积分查看</button>`
+            : '<span class="text-muted me-2">登录后可支付积分查看</span>');
+    }
+    if (!h.replied) parts.push('<span class="text-muted">部分内容回复后可见</span>');
+    return parts.length ? `<div class="mb-3">${parts.join('')}</div>` : '';
+}
+
+async function purchaseHiddenContent(postId, price) {
+    const ok = await confirmModal(`支付 ${price} 积分查看隐藏内容？积分将转给作者。`, { title: '积分查看', confirmText: '支付' });
+    if (!ok) return;
+    try {
+        await apiRequest(`${API_BASE}/posts/${postId}/purchase`, { method: 'POST' });
+        notify('已解锁隐藏内容', 'success');
+        showPostDetail(postId);
+    } catch (error) {
+        notify('解锁失败: ' + error.message, 'error', 4000);
+    }
+}
+
+function tipSection(post, isAuthor) {
+    const t = post.tips || {};
+    const tippers = (t.tippers || []).map(u => `<span class="badge bg-light text-dark me-1">${DOMPurify.sanitize(u.username || '')} ${u.amount}</span>`).join('');
+    const summary = t.count ? `<span class="text-muted me-2">💰 ${t.count} 次打赏，共 ${t.total} 积分</span>${tippers}` : '';
+    const button = currentUser && !isAuthor
+        ? `<button class="btn btn-outline-warning btn-sm me-2" onclick="tipTarget('posts', ${post.id}, ${post.id})">打赏</button>`
+        : '';
+    return summary || button ? `<div class="mb-3">${button}${summary}</div>` : '';
+}
+
+async function tipTarget(kind, id, postId) {
+    const amount = parseInt(window.prompt('打赏积分数量') || '', 10);
+    if (!amount || amount <= 0) return;
+    const message = (window.prompt('留言（可选）') || '').trim();
+    try {
+        await apiRequest(`${API_BASE}/${kind}/${id}/tips`, {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ amount, message })
+        });
+        notify('打赏成功', 'success');
+        showPostDetail(postId);
+    } catch (error) {
+        notify('打赏失败: ' + error.message, 'error', 4000);
+    }
+}
+
+function deletedCommentHTML() {
+    return '<div class="card-body"><p class="card-text text-muted fst-italic mb-0">此评论已删除</p></div>';
+}
+
 async function login(username, password) {
     try {
         const data = await fetch(`${API_BASE}/auth/login`, {
@@ -769,6 +866,10 @@ async function dailySignIn() {
         const data = await res.json();
         if (res.ok && (data.code === 0 || data.message === 'sign-in successful')) {
             notify('签到成功' + (data.data?.points_awarded ? `，积分+${data.data.points_awarded}` : ''), 'success');
+            (data.data?.events || []).forEach(ev => {
+                if (ev.type === 'streak') notify(`🎉 连续签到 ${ev.days} 天，额外奖励 ${ev.points} 积分`, 'success', 5000);
+                else if (ev.type === 'full_month') notify(`🏆 ${ev.month} 全勤，额外奖励 ${ev.points} 积分`, 'success', 5000);
+            });
             try { await refreshSigninStatus(); } catch(_) {}
         } else {
             const msg = data?.message || '签到失败';
@@ -1261,11 +1362,13 @@ async function editPost(postId) {
     }
 }
 
-async function deletePost(postId) {
+async function deletePost(postId, isOwn = false) {
     const ok = await confirmModal('确定要删除此帖子吗？', { title: '删除确认', confirmText: '删除', confirmVariant: 'danger' });
     if (!ok) return;
+    const query = askModerationReason(isOwn);
+    if (query === null) return;
     try {
-        await apiRequest(`${API_BASE}/posts/${postId}`, { method: 'DELETE' });
+        await apiRequest(`${API_BASE}/posts/${postId}${query}`, { method: 'DELETE' });
         notify('帖子已删除', 'success');
         showHome();
     } catch (error) {
@@ -57,6 +57,18 @@ func CacheSetJSON(key string, v interface{}, ttl time.Duration) {
 	CacheSetBytes(key, b, ttl)
 }
 
+// CacheDelete deletes the given keys. Use it rather than InvalidateByPrefix for ids, whose prefix also
+// matches longer ids (post 1 would clear posts 10-19, 100-199, ...).
+func CacheDelete(keys ...string) {
+	rc := GetRedis()
+	if rc == nil || len(keys) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	_ = rc.Del(ctx, keys...).Err()
+}
+
 // InvalidateByPrefix deletes keys that match the given prefix using SCAN.
 func InvalidateByPrefix(prefix string) {
 	rc := GetRedis()
@@ -0,0 +1,364 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+
+	"github.com/cppla/aibbs/models"
+)
+
+const (
+	filterVersionKey    = "filter:version"
+	filterCheckInterval = 5 * time.Second
+	filterMask          = "***"
+)
+
+// FilterMatch is one rule that matched a piece of text.
+type FilterMatch struct {
+	RuleID  uint   `json:"rule_id"`
+	Pattern string `json:"pattern"`
+	Action  string `json:"action"`
+}
+
+// FilterResult is the outcome of running the content filter over one field.
+// Action is the strongest action among the matches (block > review > replace), or "" when clean.
+// Text has replace-rule matches masked.
+type FilterResult struct {
+	Text    string
+	Action  string
+	Matches []FilterMatch
+}
+
+type filterEntry struct {
+	rule   FilterMatch
+	fields []string // nil means every field
+}
+
+func (e filterEntry) appliesTo(field string) bool {
+	if e.fields == nil {
+		return true
+	}
+	for _, f := range e.fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+type compiledFilter struct {
+	literal      *ahoCorasick
+	literalRules []filterEntry
+	pinyin       *ahoCorasick
+	pinyinRules  []filterEntry
+	regexes      []*regexp.Regexp
+	regexRules   []filterEntry
+}
+
+// contentFilter is the in-memory copy of enabled filter_rules, hot-reloaded the same way as the IP ban list.
+var contentFilter struct {
+	sync.RWMutex
+	compiled  *compiledFilter
+	version   int64
+	loaded    bool
+	checkedAt time.Time
+}
+
+// FilterText checks text destined for field against the enabled filter rules.
+func FilterText(db *gorm.DB, field, text string) FilterResult {
+	res := FilterResult{Text: text}
+	if text == "" {
+		return res
+	}
+	filterRefresh(db)
+	contentFilter.RLock()
+	cf := contentFilter.compiled
+	contentFilter.RUnlock()
+	if cf == nil {
+		return res
+	}
+
+	runes := []rune(text)
+	var masks [][2]int
+	seen := map[uint]bool{}
+	hit := func(e filterEntry, start, end int) {
+		if !e.appliesTo(field) {
+			return
+		}
+		if e.rule.Action == models.FilterActionReplace {
+			masks = append(masks, [2]int{start, end})
+		}
+		if !seen[e.rule.RuleID] {
+			seen[e.rule.RuleID] = true
+			res.Matches = append(res.Matches, e.rule)
+			if filterActionRank(e.rule.Action) > filterActionRank(res.Action) {
+				res.Action = e.rule.Action
+			}
+		}
+	}
+
+	if cf.literal != nil {
+		lower := make([]rune, len(runes))
+		for i, r := range runes {
+			lower[i] = unicode.ToLower(r)
+		}
+		cf.literal.find(lower, func(p, start, end int) { hit(cf.literalRules[p], start, end) })
+	}
+	if cf.pinyin != nil {
+		// Match on pinyin letters so "fan qiang" and 翻墙 both hit the rule "fanqiang"; pos maps each
+		// letter back to its rune index in the original text.
+		letters, pos, boundary := pinyinStream(runes)
+		cf.pinyin.find(letters, func(p, start, end int) {
+			if boundary[start] && boundary[end] {
+				hit(cf.pinyinRules[p], pos[start], pos[end-1]+1)
+			}
+		})
+	}
+	for i, re := range cf.regexes {
+		if !cf.regexRules[i].appliesTo(field) {
+			continue
+		}
+		for _, loc := range re.FindAllStringIndex(text, -1) {
+			if loc[1] > loc[0] {
+				hit(cf.regexRules[i], utf8.RuneCountInString(text[:loc[0]]), utf8.RuneCountInString(text[:loc[1]]))
+			}
+		}
+	}
+
+	if len(masks) > 0 {
+		res.Text = maskSpans(runes, masks)
+	}
+	return res
+}
+
+// ValidateFilterPattern checks that a rule pattern is usable for its kind.
+func ValidateFilterPattern(kind, pattern string) error {
+	switch kind {
+	case models.FilterKindRegex:
+		_, err := regexp.Compile(pattern)
+		return err
+	case models.FilterKindPinyin:
+		if len(PinyinVariants(pattern)) == 0 {
+			return errors.New("pinyin pattern must contain latin letters or Chinese characters")
+		}
+	}
+	return nil
+}
+
+// ContentFiltersChanged bumps the shared version so every instance recompiles its matcher.
+func ContentFiltersChanged(db *gorm.DB) {
+	if rc := GetRedis(); rc != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		_ = rc.Incr(ctx, filterVersionKey).Err()
+		cancel()
+	}
+	filterReload(db, -1)
+}
+
+func filterActionRank(action string) int {
+	switch action {
+	case models.FilterActionBlock:
+		return 3
+	case models.FilterActionReview:
+		return 2
+	case models.FilterActionReplace:
+		return 1
+	}
+	return 0
+}
+
+// maskSpans replaces each merged [start,end) rune span with the mask.
+func maskSpans(runes []rune, spans [][2]int) string {
+	sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+	var b strings.Builder
+	cur := 0
+	for i := 0; i < len(spans); {
+		start, end := spans[i][0], spans[i][1]
+		for i++; i < len(spans) && spans[i][0] <= end; i++ {
+			if spans[i][1] > end {
+				end = spans[i][1]
+			}
+		}
+		if start < cur {
+			start = cur
+		}
+		b.WriteString(string(runes[cur:start]))
+		b.WriteString(filterMask)
+		cur = end
+	}
+	b.WriteString(string(runes[cur:]))
+	return b.String()
+}
+
+func filterRefresh(db *gorm.DB) {
+	contentFilter.RLock()
+	fresh := contentFilter.loaded && time.Since(contentFilter.checkedAt) < filterCheckInterval
+	local := contentFilter.version
+	loaded := contentFilter.loaded
+	contentFilter.RUnlock()
+	if fresh {
+		return
+	}
+
+	var remote int64 = -1
+	if rc := GetRedis(); rc != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		if v, err := rc.Get(ctx, filterVersionKey).Int64(); err == nil {
+			remote = v
+		} else {
+			remote = 0
+		}
+		cancel()
+	}
+	if loaded && remote >= 0 && remote == local {
+		contentFilter.Lock()
+		contentFilter.checkedAt = time.Now()
+		contentFilter.Unlock()
+		return
+	}
+	filterReload(db, remote)
+}
+
+func filterReload(db *gorm.DB, version int64) {
+	var rows []models.FilterRule
+	if err := db.Where("enabled = ?", true).Order("id").Find(&rows).Error; err != nil {
+		if Sugar != nil {
+			Sugar.Warnf("reload filter rules failed: %v", err)
+		}
+		// keep the previous rules; retry after the next interval
+		contentFilter.Lock()
+		contentFilter.checkedAt = time.Now()
+		contentFilter.Unlock()
+		return
+	}
+
+	cf := &compiledFilter{}
+	var literals, pinyins [][]rune
+	for _, r := range rows {
+		e := filterEntry{rule: FilterMatch{RuleID: r.ID, Pattern: r.Pattern, Action: r.Action}}
+		if f := strings.TrimSpace(r.Fields); f != "" {
+			e.fields = strings.Split(f, ",")
+		}
+		switch r.Kind {
+		case models.FilterKindRegex:
+			re, err := regexp.Compile(r.Pattern)
+			if err != nil {
+				if Sugar != nil {
+					Sugar.Warnf("skip filter rule %d: %v", r.ID, err)
+				}
+				continue
+			}
+			cf.regexes = append(cf.regexes, re)
+			cf.regexRules = append(cf.regexRules, e)
+		case models.FilterKindPinyin:
+			for _, p := range PinyinVariants(r.Pattern) {
+				pinyins = append(pinyins, []rune(p))
+				cf.pinyinRules = append(cf.pinyinRules, e)
+			}
+		default:
+			if p := strings.ToLower(r.Pattern); p != "" {
+				literals = append(literals, []rune(p))
+				cf.literalRules = append(cf.literalRules, e)
+			}
+		}
+	}
+	if len(literals) > 0 {
+		cf.literal = newAhoCorasick(literals)
+	}
+	if len(pinyins) > 0 {
+		cf.pinyin = newAhoCorasick(pinyins)
+	}
+
+	contentFilter.Lock()
+	contentFilter.compiled = cf
+	if version >= 0 {
+		contentFilter.version = version
+	} else {
+		// local change: force a version check on the next call
+		contentFilter.version = -1
+	}
+	contentFilter.loaded = true
+	contentFilter.checkedAt = time.Now()
+	contentFilter.Unlock()
+}
+
+// ahoCorasick matches many patterns in one pass over the text.
+type ahoCorasick struct {
+	next []map[rune]int
+	fail []int
+	out  [][]int // pattern indices ending at each node, including those reached via fail links
+	lens []int
+}
+
+func newAhoCorasick(patterns [][]rune) *ahoCorasick {
+	ac := &ahoCorasick{next: []map[rune]int{{}}, fail: []int{0}, out: [][]int{nil}, lens: make([]int, len(patterns))}
+	for i, p := range patterns {
+		ac.lens[i] = len(p)
+		node := 0
+		for _, r := range p {
+			child, ok := ac.next[node][r]
+			if !ok {
+				child = len(ac.next)
+				ac.next = append(ac.next, map[rune]int{})
+				ac.fail = append(ac.fail, 0)
+				ac.out = append(ac.out, nil)
+				ac.next[node][r] = child
+			}
+			node = child
+		}
+		ac.out[node] = append(ac.out[node], i)
+	}
+
+	// Breadth-first so every fail target is complete before its dependants
+	queue := make([]int, 0, len(ac.next))
+	for _, child := range ac.next[0] {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for r, child := range ac.next[node] {
+			f := ac.fail[node]
+			for f > 0 {
+				if _, ok := ac.next[f][r]; ok {
+					break
+				}
+				f = ac.fail[f]
+			}
+			if target, ok := ac.next[f][r]; ok && target != child {
+				ac.fail[child] = target
+			}
+			ac.out[child] = append(ac.out[child], ac.out[ac.fail[child]]...)
+			queue = append(queue, child)
+		}
+	}
+	return ac
+}
+
+// find calls fn with the pattern index and the [start,end) rune span of every match.
+func (ac *ahoCorasick) find(text []rune, fn func(pattern, start, end int)) {
+	node := 0
+	for i, r := range text {
+		for node > 0 {
+			if _, ok := ac.next[node][r]; ok {
+				break
+			}
+			node = ac.fail[node]
+		}
+		if child, ok := ac.next[node][r]; ok {
+			node = child
+		}
+		for _, p := range ac.out[node] {
+			fn(p, i+1-ac.lens[p], i+1)
+		}
+	}
+}
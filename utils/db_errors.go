@@ -0,0 +1,14 @@
+package utils
+
+import (
+	"errors"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// IsDuplicateKey reports whether err is a MySQL unique index violation (ER_DUP_ENTRY), e.g. a
+// concurrent request inserting the same row first.
+func IsDuplicateKey(err error) bool {
+	var me *mysql.MySQLError
+	return errors.As(err, &me) && me.Number == 1062
+}
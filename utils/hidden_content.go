@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cppla/aibbs/config"
+)
+
+// Hidden blocks in post content (回复可见 / 积分可见):
+//
+//	[hide]...[/hide]            shown once the viewer has commented on the post
+//	[hide=points]...[/hide]     shown once the viewer has paid points.HiddenContentPrice
+//	[hide=points:N]...[/hide]   shown once the viewer has paid N points
+//
+// Blocks do not nest. One purchase unlocks every points block of a post at the highest block price.
+const (
+	HiddenReply  = "reply"
+	HiddenPoints = "points"
+)
+
+var hiddenBlockRe = regexp.MustCompile(`(?s)\[hide(?:=([^\]]*))?\](.*?)\[/hide\]`)
+
+// HiddenBlockSQLPattern matches the same blocks with MySQL's REGEXP_REPLACE, so searches skip their text.
+const HiddenBlockSQLPattern = `(?s)\[hide(=[^\]]*)?\].*?\[/hide\]`
+
+// hiddenBlock parses a block's parameter into its kind and price (0 for reply blocks).
+func hiddenBlock(param string) (kind string, price int, err error) {
+	param = strings.ToLower(strings.TrimSpace(param))
+	switch {
+	case param == "" || param == HiddenReply:
+		return HiddenReply, 0, nil
+	case param == HiddenPoints:
+		return HiddenPoints, config.Get().HiddenContentPrice, nil
+	case strings.HasPrefix(param, HiddenPoints+":"):
+		n, err := strconv.Atoi(strings.TrimPrefix(param, HiddenPoints+":"))
+		if err != nil || n <= 0 {
+			return HiddenPoints, 0, fmt.Errorf("invalid price in [hide=%s]", param)
+		}
+		return HiddenPoints, n, nil
+	}
+	return "", 0, fmt.Errorf("unknown hidden block [hide=%s]", param)
+}
+
+// ValidateHiddenContent checks the hidden blocks of submitted content: known kinds and prices within
+// points.HiddenContentMaxPrice.
+func ValidateHiddenContent(content string) error {
+	maxPrice := config.Get().HiddenContentMaxPrice
+	for _, m := range hiddenBlockRe.FindAllStringSubmatch(content, -1) {
+		kind, price, err := hiddenBlock(m[1])
+		if err != nil {
+			return err
+		}
+		if kind == HiddenPoints && price > maxPrice {
+			return fmt.Errorf("hidden content price must not exceed %d points", maxPrice)
+		}
+	}
+	return nil
+}
+
+// HiddenSummary counts the hidden blocks in content and returns the price that unlocks its points blocks.
+func HiddenSummary(content string) (blocks, price int) {
+	for _, m := range hiddenBlockRe.FindAllStringSubmatch(content, -1) {
+		blocks++
+		if kind, p, err := hiddenBlock(m[1]); err == nil && kind == HiddenPoints && p > price {
+			price = p
+		}
+	}
+	return blocks, price
+}
+
+// RenderHiddenContent replaces the blocks the viewer may not see with a notice; unlocked blocks keep
+// their markup so clients can highlight them. Blocks that fail to parse stay locked.
+func RenderHiddenContent(content string, replyUnlocked, pointsUnlocked bool) string {
+	return hiddenBlockRe.ReplaceAllStringFunc(content, func(block string) string {
+		m := hiddenBlockRe.FindStringSubmatch(block)
+		kind, price, err := hiddenBlock(m[1])
+		switch {
+		case err == nil && kind == HiddenReply && replyUnlocked:
+			return block
+		case err == nil && kind == HiddenPoints && pointsUnlocked:
+			return block
+		case err == nil && kind == HiddenPoints:
+			return "\n\n> 🔒 此处内容需支付 " + strconv.Itoa(price) + " 积分查看\n\n"
+		}
+		return "\n\n> 🔒 此处内容回复后可见\n\n"
+	})
+}
@@ -0,0 +1,169 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/cppla/aibbs/models"
+)
+
+const (
+	ipBanVersionKey    = "ipban:version"
+	ipBanCheckInterval = 5 * time.Second
+)
+
+type ipBanEntry struct {
+	network   *net.IPNet
+	scope     string
+	expiresAt *time.Time
+}
+
+// ipBanMatcher is the in-memory copy of ip_bans. Every instance polls a Redis version
+// counter and reloads from the database when another instance (or itself) bumped it.
+var ipBanMatcher struct {
+	sync.RWMutex
+	exact     map[string]ipBanEntry // single addresses (/32, /128)
+	ranges    []ipBanEntry
+	version   int64
+	loaded    bool
+	checkedAt time.Time
+}
+
+// NormalizeCIDR accepts an address or CIDR and returns its canonical network form.
+func NormalizeCIDR(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if !strings.Contains(s, "/") {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return "", fmt.Errorf("invalid ip: %s", s)
+		}
+		if ip.To4() != nil {
+			s += "/32"
+		} else {
+			s += "/128"
+		}
+	}
+	_, network, err := net.ParseCIDR(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid cidr: %s", s)
+	}
+	return network.String(), nil
+}
+
+// IPBanned reports whether ip is banned and the scope of the matching ban ("all" wins over "write").
+func IPBanned(db *gorm.DB, ip string) (string, bool) {
+	parsed := net.ParseIP(strings.TrimSpace(ip))
+	if parsed == nil {
+		return "", false
+	}
+	ipBanRefresh(db)
+
+	now := time.Now()
+	scope := ""
+	consider := func(e ipBanEntry) {
+		if e.expiresAt != nil && !e.expiresAt.After(now) {
+			return
+		}
+		if scope != models.IPBanScopeAll {
+			scope = e.scope
+		}
+	}
+	ipBanMatcher.RLock()
+	defer ipBanMatcher.RUnlock()
+	if e, ok := ipBanMatcher.exact[parsed.String()]; ok {
+		consider(e)
+	}
+	for _, e := range ipBanMatcher.ranges {
+		if e.network.Contains(parsed) {
+			consider(e)
+		}
+	}
+	return scope, scope != ""
+}
+
+// IPBansChanged bumps the shared version so every instance reloads its matcher.
+func IPBansChanged(db *gorm.DB) {
+	if rc := GetRedis(); rc != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		_ = rc.Incr(ctx, ipBanVersionKey).Err()
+		cancel()
+	}
+	ipBanReload(db, -1)
+}
+
+func ipBanRefresh(db *gorm.DB) {
+	ipBanMatcher.RLock()
+	fresh := ipBanMatcher.loaded && time.Since(ipBanMatcher.checkedAt) < ipBanCheckInterval
+	local := ipBanMatcher.version
+	ipBanMatcher.RUnlock()
+	if fresh {
+		return
+	}
+
+	var remote int64 = -1
+	if rc := GetRedis(); rc != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		if v, err := rc.Get(ctx, ipBanVersionKey).Int64(); err == nil {
+			remote = v
+		} else {
+			remote = 0
+		}
+		cancel()
+	}
+	ipBanMatcher.RLock()
+	loaded := ipBanMatcher.loaded
+	ipBanMatcher.RUnlock()
+	if loaded && remote >= 0 && remote == local {
+		ipBanMatcher.Lock()
+		ipBanMatcher.checkedAt = time.Now()
+		ipBanMatcher.Unlock()
+		return
+	}
+	ipBanReload(db, remote)
+}
+
+func ipBanReload(db *gorm.DB, version int64) {
+	var rows []models.IPBan
+	if err := db.Where("expires_at IS NULL OR expires_at > ?", time.Now()).Find(&rows).Error; err != nil {
+		if Sugar != nil {
+			Sugar.Warnf("reload ip bans failed: %v", err)
+		}
+		// keep serving the previous list; retry after the next interval
+		ipBanMatcher.Lock()
+		ipBanMatcher.checkedAt = time.Now()
+		ipBanMatcher.Unlock()
+		return
+	}
+	exact := make(map[string]ipBanEntry)
+	var ranges []ipBanEntry
+	for _, r := range rows {
+		_, network, err := net.ParseCIDR(r.CIDR)
+		if err != nil {
+			continue
+		}
+		e := ipBanEntry{network: network, scope: r.Scope, expiresAt: r.ExpiresAt}
+		if ones, bits := network.Mask.Size(); ones == bits {
+			exact[network.IP.String()] = e
+		} else {
+			ranges = append(ranges, e)
+		}
+	}
+	ipBanMatcher.Lock()
+	ipBanMatcher.exact = exact
+	ipBanMatcher.ranges = ranges
+	if version >= 0 {
+		ipBanMatcher.version = version
+	} else {
+		// local change: force a version check on the next request
+		ipBanMatcher.version = -1
+	}
+	ipBanMatcher.loaded = true
+	ipBanMatcher.checkedAt = time.Now()
+	ipBanMatcher.Unlock()
+}
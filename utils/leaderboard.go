@@ -0,0 +1,314 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+
+	"github.com/cppla/aibbs/models"
+)
+
+// Leaderboard kinds.
+const (
+	LeaderboardPoints = "points"
+	LeaderboardStreak = "streak"
+	LeaderboardPosts  = "posts"
+)
+
+// Leaderboard windows. Monthly and weekly periods follow the site's signin.Timezone; weeks start on Monday.
+const (
+	LeaderboardAllTime = "all"
+	LeaderboardMonthly = "month"
+	LeaderboardWeekly  = "week"
+)
+
+// LeaderboardKinds and LeaderboardWindows list the valid values of each.
+var (
+	LeaderboardKinds   = []string{LeaderboardPoints, LeaderboardStreak, LeaderboardPosts}
+	LeaderboardWindows = []string{LeaderboardAllTime, LeaderboardMonthly, LeaderboardWeekly}
+)
+
+// Scores per kind and window:
+//   - points: all-time is the balance; monthly/weekly is the net ledger change in the period
+//   - streak: all-time is the current streak; monthly/weekly is the best streak reached in the period
+//   - posts: published posts (redirect stubs excluded); monthly/weekly counts posts created in the period
+//
+// Updates are best effort and sent after the database transaction commits; if Redis misses one,
+// RebuildLeaderboards recomputes everything from MySQL.
+
+// LeaderboardPeriod returns the key suffix of the window's period containing t, and when that period ends.
+// The all-time window has an empty period and a zero end.
+func LeaderboardPeriod(window string, t time.Time) (string, time.Time) {
+	t = t.In(SiteLocation())
+	switch window {
+	case LeaderboardMonthly:
+		start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+		return start.Format("2006-01"), start.AddDate(0, 1, 0)
+	case LeaderboardWeekly:
+		year, week := t.ISOWeek()
+		offset := (int(t.Weekday()) + 6) % 7 // days since Monday
+		start := time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, t.Location())
+		return fmt.Sprintf("%d-W%02d", year, week), start.AddDate(0, 0, 7)
+	}
+	return "", time.Time{}
+}
+
+// leaderboardStart returns when the current period of window began (zero for all-time).
+func leaderboardStart(window string, now time.Time) time.Time {
+	_, end := LeaderboardPeriod(window, now)
+	switch window {
+	case LeaderboardMonthly:
+		return end.AddDate(0, -1, 0)
+	case LeaderboardWeekly:
+		return end.AddDate(0, 0, -7)
+	}
+	return time.Time{}
+}
+
+// LeaderboardKey is the Redis sorted set holding kind/window for the period containing t.
+func LeaderboardKey(kind, window string, t time.Time) string {
+	period, _ := LeaderboardPeriod(window, t)
+	if period == "" {
+		return "leaderboard:" + kind + ":" + window
+	}
+	return "leaderboard:" + kind + ":" + window + ":" + period
+}
+
+// LeaderboardEntry is one ranked user.
+type LeaderboardEntry struct {
+	Rank   int     `json:"rank"`
+	UserID uint    `json:"user_id"`
+	Score  float64 `json:"score"`
+}
+
+// TopLeaderboard returns the top limit entries of kind/window for the current period.
+func TopLeaderboard(kind, window string, limit int) ([]LeaderboardEntry, error) {
+	rc := GetRedis()
+	if rc == nil {
+		return nil, fmt.Errorf("redis unavailable")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	zs, err := rc.ZRevRangeWithScores(ctx, LeaderboardKey(kind, window, time.Now()), 0, int64(limit-1)).Result()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]LeaderboardEntry, 0, len(zs))
+	for i, z := range zs {
+		id, err := strconv.ParseUint(fmt.Sprint(z.Member), 10, 64)
+		if err != nil {
+			continue
+		}
+		out = append(out, LeaderboardEntry{Rank: i + 1, UserID: uint(id), Score: z.Score})
+	}
+	return out, nil
+}
+
+// LeaderboardRank returns the user's 1-based rank and score on kind/window, or 0 when unranked.
+func LeaderboardRank(kind, window string, userID uint) (int, float64) {
+	rc := GetRedis()
+	if rc == nil {
+		return 0, 0
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	key, member := LeaderboardKey(kind, window, time.Now()), strconv.FormatUint(uint64(userID), 10)
+	rank, err := rc.ZRevRank(ctx, key, member).Result()
+	if err != nil {
+		return 0, 0
+	}
+	score, _ := rc.ZScore(ctx, key, member).Result()
+	return int(rank) + 1, score
+}
+
+// leaderboardUpdate runs fn for each window of kind in a pipeline, then sets the expiry of the period keys
+// so past periods drop out a day after they end.
+func leaderboardUpdate(kind string, at time.Time, fn func(ctx context.Context, pipe redis.Pipeliner, window, key string)) {
+	rc := GetRedis()
+	if rc == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	now := time.Now()
+	pipe := rc.Pipeline()
+	for _, window := range LeaderboardWindows {
+		// Events from an earlier period only count towards it while it is still the current one
+		period, end := LeaderboardPeriod(window, at)
+		if current, _ := LeaderboardPeriod(window, now); period != current {
+			continue
+		}
+		key := LeaderboardKey(kind, window, at)
+		fn(ctx, pipe, window, key)
+		if !end.IsZero() {
+			pipe.ExpireAt(ctx, key, end.Add(24*time.Hour))
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil && Sugar != nil {
+		Sugar.Warnf("leaderboard %s update failed: %v", kind, err)
+	}
+}
+
+// LeaderboardPointsChanged records a points change of amount leaving the user with balance.
+func LeaderboardPointsChanged(userID uint, amount, balance int) {
+	if userID == models.SystemAccountID || amount == 0 {
+		return
+	}
+	member := strconv.FormatUint(uint64(userID), 10)
+	leaderboardUpdate(LeaderboardPoints, time.Now(), func(ctx context.Context, pipe redis.Pipeliner, window, key string) {
+		if window == LeaderboardAllTime {
+			pipe.ZAdd(ctx, key, redis.Z{Score: float64(balance), Member: member})
+			return
+		}
+		pipe.ZIncrBy(ctx, key, float64(amount), member)
+	})
+}
+
+// LeaderboardStreakChanged records the user's current streak after a sign-in or make-up sign-in.
+func LeaderboardStreakChanged(userID uint, streak int) {
+	member := strconv.FormatUint(uint64(userID), 10)
+	leaderboardUpdate(LeaderboardStreak, time.Now(), func(ctx context.Context, pipe redis.Pipeliner, window, key string) {
+		if window == LeaderboardAllTime {
+			pipe.ZAdd(ctx, key, redis.Z{Score: float64(streak), Member: member})
+			return
+		}
+		pipe.ZAddGT(ctx, key, redis.Z{Score: float64(streak), Member: member})
+	})
+}
+
+// LeaderboardPostCounted adds delta (+1 when a post becomes visible, -1 when it is deleted) to the
+// author's post counts; createdAt decides which monthly and weekly periods the post belongs to.
+func LeaderboardPostCounted(userID uint, createdAt time.Time, delta int) {
+	member := strconv.FormatUint(uint64(userID), 10)
+	leaderboardUpdate(LeaderboardPosts, createdAt, func(ctx context.Context, pipe redis.Pipeliner, _, key string) {
+		pipe.ZIncrBy(ctx, key, float64(delta), member)
+	})
+}
+
+// leaderboardRow is a user's recomputed score.
+type leaderboardRow struct {
+	UserID uint
+	Score  float64
+}
+
+// RebuildLeaderboards recomputes every kind and window for the current period from MySQL and swaps the
+// results in atomically.
+func RebuildLeaderboards(db *gorm.DB) error {
+	now := time.Now()
+	for _, kind := range LeaderboardKinds {
+		for _, window := range LeaderboardWindows {
+			rows, err := leaderboardScores(db, kind, window, now)
+			if err != nil {
+				return fmt.Errorf("%s/%s: %w", kind, window, err)
+			}
+			if err := replaceLeaderboard(LeaderboardKey(kind, window, now), window, now, rows); err != nil {
+				return fmt.Errorf("%s/%s: %w", kind, window, err)
+			}
+		}
+	}
+	return nil
+}
+
+func leaderboardScores(db *gorm.DB, kind, window string, now time.Time) ([]leaderboardRow, error) {
+	var rows []leaderboardRow
+	start := leaderboardStart(window, now)
+	switch kind {
+	case LeaderboardPoints:
+		if window == LeaderboardAllTime {
+			err := db.Model(&models.User{}).Select("id AS user_id, points AS score").Where("points <> 0").Scan(&rows).Error
+			return rows, err
+		}
+		err := db.Model(&models.PointsTransaction{}).
+			Select("user_id, SUM(amount) AS score").
+			Where("user_id <> ? AND reason <> ? AND created_at >= ?", models.SystemAccountID, models.PointsReasonOpening, start).
+			Group("user_id").Having("SUM(amount) <> 0").Scan(&rows).Error
+		return rows, err
+	case LeaderboardStreak:
+		if window == LeaderboardAllTime {
+			return currentStreaks(db, now)
+		}
+		err := db.Model(&models.SignIn{}).
+			Select("user_id, MAX(streak_achieved) AS score").
+			Where("signin_day >= ?", CalendarDate(start, SiteLocation())).
+			Group("user_id").Scan(&rows).Error
+		return rows, err
+	case LeaderboardPosts:
+		q := db.Model(&models.Post{}).
+			Select("user_id, COUNT(*) AS score").
+			Where("status = ? AND moved_to = 0", models.StatusPublished)
+		if !start.IsZero() {
+			q = q.Where("created_at >= ?", start)
+		}
+		err := q.Group("user_id").Scan(&rows).Error
+		return rows, err
+	}
+	return nil, nil
+}
+
+// currentStreaks returns each user's streak as of their latest sign-in, skipping streaks already broken
+// (latest sign-in before yesterday in the user's timezone).
+func currentStreaks(db *gorm.DB, now time.Time) ([]leaderboardRow, error) {
+	var latest []struct {
+		UserID         uint
+		SigninDay      time.Time
+		StreakAchieved int
+		Timezone       string
+	}
+	err := db.Table("sign_ins s").
+		Select("s.user_id, s.signin_day, s.streak_achieved, u.timezone").
+		Joins("JOIN users u ON u.id = s.user_id AND u.deleted_at IS NULL").
+		Joins("JOIN (SELECT user_id, MAX(signin_day) AS d FROM sign_ins GROUP BY user_id) m ON m.user_id = s.user_id AND m.d = s.signin_day").
+		Scan(&latest).Error
+	if err != nil {
+		return nil, err
+	}
+	best := map[uint]int{}
+	for _, l := range latest {
+		yesterday := CalendarDate(now, UserLocation(l.Timezone)).AddDate(0, 0, -1)
+		if l.SigninDay.Before(yesterday) {
+			continue
+		}
+		if l.StreakAchieved > best[l.UserID] {
+			best[l.UserID] = l.StreakAchieved
+		}
+	}
+	rows := make([]leaderboardRow, 0, len(best))
+	for id, streak := range best {
+		rows = append(rows, leaderboardRow{UserID: id, Score: float64(streak)})
+	}
+	return rows, nil
+}
+
+// replaceLeaderboard writes rows to a temporary key and renames it over key.
+func replaceLeaderboard(key, window string, now time.Time, rows []leaderboardRow) error {
+	rc := GetRedis()
+	if rc == nil {
+		return fmt.Errorf("redis unavailable")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if len(rows) == 0 {
+		return rc.Del(ctx, key).Err()
+	}
+	tmp := key + ":rebuild"
+	pipe := rc.Pipeline()
+	pipe.Del(ctx, tmp)
+	for i := 0; i < len(rows); i += 1000 {
+		batch := rows[i:min(i+1000, len(rows))]
+		zs := make([]redis.Z, 0, len(batch))
+		for _, r := range batch {
+			zs = append(zs, redis.Z{Score: r.Score, Member: strconv.FormatUint(uint64(r.UserID), 10)})
+		}
+		pipe.ZAdd(ctx, tmp, zs...)
+	}
+	pipe.Rename(ctx, tmp, key)
+	if _, end := LeaderboardPeriod(window, now); !end.IsZero() {
+		pipe.ExpireAt(ctx, key, end.Add(24*time.Hour))
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
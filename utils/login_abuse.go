@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/cppla/aibbs/config"
+)
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+func loginKey(parts ...string) string {
+	return "login:" + join(parts, ":")
+}
+
+func normalizeLoginName(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+func loginWindow() time.Duration {
+	minutes := config.Get().LoginFailureWindowMinutes
+	if minutes <= 0 {
+		minutes = 15
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// LoginFailures returns the current failure counters for username and IP.
+func LoginFailures(username, ip string) (int, int) {
+	cli := GetRedis()
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	userFails, err := cli.Get(ctx, loginKey("failuser", normalizeLoginName(username))).Int()
+	if err != nil && err != redis.Nil {
+		userFails = 0
+	}
+	ipFails, err := cli.Get(ctx, loginKey("failip", ip)).Int()
+	if err != nil && err != redis.Nil {
+		ipFails = 0
+	}
+	return userFails, ipFails
+}
+
+// LoginIsLocked reports whether the username or the IP is temporarily locked out.
+func LoginIsLocked(username, ip string) bool {
+	cli := GetRedis()
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	n, err := cli.Exists(ctx, loginKey("lockuser", normalizeLoginName(username)), loginKey("lockip", ip)).Result()
+	if err != nil {
+		return false
+	} // fail-open
+	return n > 0
+}
+
+// LoginFailRecord increments both counters and applies lockouts once thresholds are hit.
+// It returns the new counters and whether the username became locked by this attempt.
+func LoginFailRecord(username, ip string) (int, int, bool) {
+	cfg := config.Get()
+	cli := GetRedis()
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	window := loginWindow()
+	lockFor := time.Duration(max(cfg.LoginLockoutMinutes, 1)) * time.Minute
+
+	userKey := loginKey("failuser", normalizeLoginName(username))
+	ipKey := loginKey("failip", ip)
+	pipe := cli.TxPipeline()
+	userIncr := pipe.Incr(ctx, userKey)
+	pipe.Expire(ctx, userKey, window)
+	ipIncr := pipe.Incr(ctx, ipKey)
+	pipe.Expire(ctx, ipKey, window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, 0, false
+	}
+	userFails, ipFails := int(userIncr.Val()), int(ipIncr.Val())
+
+	lockedNow := false
+	if cfg.LoginLockoutThreshold > 0 && userFails >= cfg.LoginLockoutThreshold {
+		// SetNX so the alert fires once per lockout rather than on every further attempt
+		lockedNow, _ = cli.SetNX(ctx, loginKey("lockuser", normalizeLoginName(username)), "1", lockFor).Result()
+	}
+	if cfg.LoginIPFailureThreshold > 0 && ipFails >= cfg.LoginIPFailureThreshold {
+		_ = cli.Set(ctx, loginKey("lockip", ip), "1", lockFor).Err()
+	}
+	return userFails, ipFails, lockedNow
+}
+
+// LoginSuccessReset clears the per-username counter after a successful login.
+// The per-IP counter is kept so one valid account cannot launder a stuffing run.
+func LoginSuccessReset(username string) {
+	cli := GetRedis()
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	_ = cli.Del(ctx, loginKey("failuser", normalizeLoginName(username))).Err()
+}
+
+// LoginCaptchaRequired tells whether the next attempt must carry a valid captcha.
+func LoginCaptchaRequired(userFails, ipFails int) bool {
+	after := config.Get().LoginCaptchaAfterFailures
+	return after > 0 && (userFails >= after || ipFails >= after)
+}
+
+// LoginDelay returns the progressive delay applied before verifying a password.
+func LoginDelay(fails int) time.Duration {
+	cfg := config.Get()
+	if fails <= 0 || cfg.LoginDelayStepMs <= 0 {
+		return 0
+	}
+	d := time.Duration(fails*cfg.LoginDelayStepMs) * time.Millisecond
+	if limit := time.Duration(cfg.LoginMaxDelayMs) * time.Millisecond; limit > 0 && d > limit {
+		d = limit
+	}
+	return d
+}
+
+// LoginDummyCheck burns a bcrypt comparison so unknown usernames take as long as wrong passwords.
+func LoginDummyCheck(password string) {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = HashPassword("aibbs-dummy-password")
+	})
+	_ = CheckPassword(dummyHash, password)
+}
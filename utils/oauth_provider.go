@@ -0,0 +1,228 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/cppla/aibbs/config"
+)
+
+// OAuthIdentity is the provider-neutral user profile extracted via claim mapping.
+type OAuthIdentity struct {
+	ID          string
+	Username    string
+	DisplayName string
+	Email       string
+	AvatarURL   string
+}
+
+type oidcDiscovery struct {
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	fetchedAt             time.Time
+}
+
+var (
+	oidcDiscoveryMu    sync.Mutex
+	oidcDiscoveryCache = map[string]oidcDiscovery{}
+	oidcDiscoveryTTL   = time.Hour
+	oauthHTTPClient    = &http.Client{Timeout: 10 * time.Second}
+)
+
+// ResolveOAuthProvider looks up a configured provider and fills any missing endpoints and claims
+// from OIDC discovery (<Issuer>/.well-known/openid-configuration) and OIDC defaults.
+func ResolveOAuthProvider(ctx context.Context, name string) (*config.OAuthProvider, error) {
+	p, ok := config.FindOAuthProvider(name)
+	if !ok {
+		return nil, fmt.Errorf("unsupported provider: %s", name)
+	}
+	if p.ClientID == "" || p.ClientSecret == "" {
+		return nil, fmt.Errorf("%s oauth not configured", p.Name)
+	}
+	return resolveOAuthEndpoints(ctx, p)
+}
+
+// resolveOAuthEndpoints completes a provider's endpoints, claims and scopes.
+func resolveOAuthEndpoints(ctx context.Context, p config.OAuthProvider) (*config.OAuthProvider, error) {
+	if p.Issuer != "" && (p.AuthURL == "" || p.TokenURL == "" || p.UserInfoURL == "") {
+		d, err := discoverOIDC(ctx, p.Issuer)
+		if err != nil {
+			return nil, fmt.Errorf("%s discovery failed: %w", p.Name, err)
+		}
+		if p.AuthURL == "" {
+			p.AuthURL = d.AuthorizationEndpoint
+		}
+		if p.TokenURL == "" {
+			p.TokenURL = d.TokenEndpoint
+		}
+		if p.UserInfoURL == "" {
+			p.UserInfoURL = d.UserinfoEndpoint
+		}
+	}
+	if p.AuthURL == "" || p.TokenURL == "" || p.UserInfoURL == "" {
+		return nil, fmt.Errorf("%s oauth endpoints not configured", p.Name)
+	}
+	// Standard OIDC claim names as defaults
+	if p.IDClaim == "" {
+		p.IDClaim = "sub"
+	}
+	if p.UsernameClaim == "" {
+		p.UsernameClaim = "preferred_username"
+	}
+	if p.NameClaim == "" {
+		p.NameClaim = "name"
+	}
+	if p.EmailClaim == "" {
+		p.EmailClaim = "email"
+	}
+	if p.AvatarClaim == "" {
+		p.AvatarClaim = "picture"
+	}
+	if len(p.Scopes) == 0 && p.Issuer != "" {
+		p.Scopes = []string{"openid", "profile", "email"}
+	}
+	return &p, nil
+}
+
+// OAuth2Config builds the oauth2 client configuration for a resolved provider.
+func OAuth2Config(p *config.OAuthProvider, redirectURL string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     p.ClientID,
+		ClientSecret: p.ClientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       p.Scopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  p.AuthURL,
+			TokenURL: p.TokenURL,
+		},
+	}
+}
+
+// FetchOAuthIdentity calls the provider userinfo endpoint and maps claims onto an OAuthIdentity.
+func FetchOAuthIdentity(ctx context.Context, p *config.OAuthProvider, token *oauth2.Token) (*OAuthIdentity, error) {
+	var claims map[string]any
+	if err := getJSONWithToken(ctx, p.UserInfoURL, token.AccessToken, &claims); err != nil {
+		return nil, fmt.Errorf("%s user info request failed: %w", p.Name, err)
+	}
+
+	identity := &OAuthIdentity{
+		ID:          claimString(claims, p.IDClaim),
+		Username:    claimString(claims, p.UsernameClaim),
+		DisplayName: claimString(claims, p.NameClaim),
+		Email:       claimString(claims, p.EmailClaim),
+		AvatarURL:   claimString(claims, p.AvatarClaim),
+	}
+	if identity.ID == "" {
+		return nil, fmt.Errorf("%s user info missing %q claim", p.Name, p.IDClaim)
+	}
+	if identity.Email == "" && p.EmailsURL != "" {
+		identity.Email, _ = fetchPrimaryEmail(ctx, p.EmailsURL, token.AccessToken)
+	}
+	if identity.Username == "" {
+		// fall back to the local part of the email before giving up on a readable name
+		if at := strings.Index(identity.Email, "@"); at > 0 {
+			identity.Username = identity.Email[:at]
+		}
+	}
+	if identity.DisplayName == "" {
+		identity.DisplayName = identity.Username
+	}
+	return identity, nil
+}
+
+func discoverOIDC(ctx context.Context, issuer string) (oidcDiscovery, error) {
+	oidcDiscoveryMu.Lock()
+	if d, ok := oidcDiscoveryCache[issuer]; ok && time.Since(d.fetchedAt) < oidcDiscoveryTTL {
+		oidcDiscoveryMu.Unlock()
+		return d, nil
+	}
+	oidcDiscoveryMu.Unlock()
+
+	var d oidcDiscovery
+	if err := getJSONWithToken(ctx, strings.TrimRight(issuer, "/")+"/.well-known/openid-configuration", "", &d); err != nil {
+		return oidcDiscovery{}, err
+	}
+	d.fetchedAt = time.Now()
+	oidcDiscoveryMu.Lock()
+	oidcDiscoveryCache[issuer] = d
+	oidcDiscoveryMu.Unlock()
+	return d, nil
+}
+
+func fetchPrimaryEmail(ctx context.Context, url, accessToken string) (string, error) {
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := getJSONWithToken(ctx, url, accessToken, &emails); err != nil {
+		return "", err
+	}
+	for _, e := range emails {
+		if e.Primary && e.Verified {
+			return e.Email, nil
+		}
+	}
+	if len(emails) > 0 {
+		return emails[0].Email, nil
+	}
+	return "", nil
+}
+
+func getJSONWithToken(ctx context.Context, url, accessToken string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	resp, err := oauthHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	dec := json.NewDecoder(resp.Body)
+	dec.UseNumber()
+	return dec.Decode(out)
+}
+
+// claimString resolves a dotted claim path and renders scalars as strings.
+func claimString(claims map[string]any, path string) string {
+	if path == "" {
+		return ""
+	}
+	var cur any = claims
+	for _, part := range strings.Split(path, ".") {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return ""
+		}
+		cur = m[part]
+	}
+	switch v := cur.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case json.Number:
+		return v.String()
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return ""
+	}
+}
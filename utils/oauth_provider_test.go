@@ -0,0 +1,157 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cppla/aibbs/config"
+)
+
+// newMockIdP starts an OIDC provider serving discovery, a token endpoint that accepts code "good-code"
+// and a userinfo endpoint returning claims for access token "mock-token".
+func newMockIdP(t *testing.T, claims map[string]any) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	writeJSON := func(w http.ResponseWriter, v any) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(v)
+	}
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]string{
+			"issuer":                 srv.URL,
+			"authorization_endpoint": srv.URL + "/authorize",
+			"token_endpoint":         srv.URL + "/token",
+			"userinfo_endpoint":      srv.URL + "/userinfo",
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" || r.PostForm.Get("code") != "good-code" {
+			w.WriteHeader(http.StatusBadRequest)
+			writeJSON(w, map[string]string{"error": "invalid_grant"})
+			return
+		}
+		if id, secret, ok := r.BasicAuth(); (!ok || id != "client" || secret != "secret") && r.PostForm.Get("client_secret") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			writeJSON(w, map[string]string{"error": "invalid_client"})
+			return
+		}
+		writeJSON(w, map[string]any{"access_token": "mock-token", "token_type": "Bearer", "expires_in": 3600})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer mock-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		writeJSON(w, claims)
+	})
+	return srv
+}
+
+func TestOAuthDiscoveryExchangeAndOIDCClaims(t *testing.T) {
+	srv := newMockIdP(t, map[string]any{
+		"sub":                "u-42",
+		"preferred_username": "alice",
+		"name":               "Alice",
+		"email":              "alice@example.com",
+		"picture":            "https://example.com/a.png",
+	})
+	ctx := context.Background()
+	p, err := resolveOAuthEndpoints(ctx, config.OAuthProvider{Name: "mock", ClientID: "client", ClientSecret: "secret", Issuer: srv.URL})
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if p.AuthURL != srv.URL+"/authorize" || p.TokenURL != srv.URL+"/token" || p.UserInfoURL != srv.URL+"/userinfo" {
+		t.Fatalf("discovery endpoints not applied: %+v", p)
+	}
+	if len(p.Scopes) != 3 || p.IDClaim != "sub" {
+		t.Fatalf("OIDC defaults not applied: scopes=%v id claim=%q", p.Scopes, p.IDClaim)
+	}
+
+	oc := OAuth2Config(p, "http://localhost/callback")
+	if _, err := oc.Exchange(ctx, "bad-code"); err == nil {
+		t.Fatal("exchange with a bad code succeeded")
+	}
+	token, err := oc.Exchange(ctx, "good-code")
+	if err != nil {
+		t.Fatalf("exchange: %v", err)
+	}
+	id, err := FetchOAuthIdentity(ctx, p, token)
+	if err != nil {
+		t.Fatalf("fetch identity: %v", err)
+	}
+	want := OAuthIdentity{ID: "u-42", Username: "alice", DisplayName: "Alice", Email: "alice@example.com", AvatarURL: "https://example.com/a.png"}
+	if *id != want {
+		t.Fatalf("identity = %+v, want %+v", *id, want)
+	}
+}
+
+func TestOAuthDottedClaimPaths(t *testing.T) {
+	srv := newMockIdP(t, map[string]any{
+		"data": map[string]any{
+			"id": 1234567890123,
+			"attributes": map[string]any{
+				"login":   "bob",
+				"contact": map[string]any{"email": "bob@example.com"},
+			},
+		},
+	})
+	ctx := context.Background()
+	p, err := resolveOAuthEndpoints(ctx, config.OAuthProvider{
+		Name:          "dotted",
+		ClientID:      "client",
+		ClientSecret:  "secret",
+		Issuer:        srv.URL,
+		IDClaim:       "data.id",
+		UsernameClaim: "data.attributes.login",
+		NameClaim:     "data.attributes.missing",
+		EmailClaim:    "data.attributes.contact.email",
+	})
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	token, err := OAuth2Config(p, "http://localhost/callback").Exchange(ctx, "good-code")
+	if err != nil {
+		t.Fatalf("exchange: %v", err)
+	}
+	id, err := FetchOAuthIdentity(ctx, p, token)
+	if err != nil {
+		t.Fatalf("fetch identity: %v", err)
+	}
+	// Large numeric ids keep every digit, and a missing name falls back to the username
+	want := OAuthIdentity{ID: "1234567890123", Username: "bob", DisplayName: "bob", Email: "bob@example.com"}
+	if *id != want {
+		t.Fatalf("identity = %+v, want %+v", *id, want)
+	}
+
+	p.IDClaim = "data.attributes.login.nope"
+	if _, err := FetchOAuthIdentity(ctx, p, token); err == nil {
+		t.Fatal("expected an error when the id claim path does not resolve")
+	}
+}
+
+func TestClaimString(t *testing.T) {
+	claims := map[string]any{
+		"a":    map[string]any{"b": map[string]any{"c": " deep "}},
+		"flag": true,
+		"list": []any{"x"},
+	}
+	cases := map[string]string{
+		"a.b.c":   "deep",
+		"flag":    "true",
+		"list":    "",
+		"a.b":     "",
+		"a.x.c":   "",
+		"flag.no": "",
+		"":        "",
+	}
+	for path, want := range cases {
+		if got := claimString(claims, path); got != want {
+			t.Errorf("claimString(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
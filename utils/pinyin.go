@@ -0,0 +1,189 @@
+package utils
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/mozillazg/go-pinyin"
+)
+
+// Pinyin filter rules compare text as toneless pinyin letters: Chinese characters read as pinyin and
+// separators are dropped, so the rule 翻墙 (or fanqiang) hits 翻墙, 帆强, "fan qiang" and "F-A-N.Qiang".
+// Matches must start and end on a syllable or word boundary, so "sb" does not hit "is bad".
+
+// maxPinyinVariants caps the readings combined for a rule full of polyphonic characters.
+const maxPinyinVariants = 16
+
+var (
+	pinyinArgs          = pinyin.Args{Style: pinyin.Normal}
+	pinyinHeteronymArgs = pinyin.Args{Style: pinyin.Normal, Heteronym: true}
+
+	pinyinSyllablesOnce sync.Once
+	pinyinSyllables     map[string]bool
+	pinyinSyllableMax   int
+)
+
+// hanziReadings returns the toneless readings of a Chinese character (only the most common one unless
+// all is set), or nil for any other rune.
+func hanziReadings(r rune, all bool) []string {
+	if !unicode.Is(unicode.Han, r) {
+		return nil
+	}
+	args := pinyinArgs
+	if all {
+		args = pinyinHeteronymArgs
+	}
+	var out []string
+	seen := map[string]bool{}
+	for _, p := range pinyin.SinglePinyin(r, args) {
+		if isPinyinLetters(p) && !seen[p] {
+			seen[p] = true
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func isPinyinLetters(s string) bool {
+	for _, r := range s {
+		if r < 'a' || r > 'z' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// PinyinVariants returns the letter sequences a pinyin rule matches: latin letters are kept lowercased
+// and each Chinese character expands to its readings. Everything else is ignored.
+func PinyinVariants(pattern string) []string {
+	variants := []string{""}
+	for _, r := range strings.ToLower(pattern) {
+		if r >= 'a' && r <= 'z' {
+			for i := range variants {
+				variants[i] += string(r)
+			}
+			continue
+		}
+		readings := hanziReadings(r, true)
+		if len(readings) == 0 {
+			continue
+		}
+		next := make([]string, 0, len(variants)*len(readings))
+		for _, v := range variants {
+			for _, p := range readings {
+				if len(next) < maxPinyinVariants {
+					next = append(next, v+p)
+				}
+			}
+		}
+		variants = next
+	}
+	if variants[0] == "" {
+		return nil
+	}
+	return variants
+}
+
+// pinyinStream converts text into the letters pinyin rules are matched against. pos maps each letter
+// to its rune index in text, and boundary[i] reports whether a match may start or end before letter i:
+// at the edges of a Chinese character or latin word, and inside a latin word where a split into pinyin
+// syllables allows it.
+func pinyinStream(text []rune) (letters []rune, pos []int, boundary []bool) {
+	type span struct {
+		start, end int
+		latin      bool
+	}
+	var spans []span
+	word := -1
+	closeWord := func() {
+		if word >= 0 {
+			spans = append(spans, span{word, len(letters), true})
+			word = -1
+		}
+	}
+	for i, r := range text {
+		r = unicode.ToLower(r)
+		if r >= 'a' && r <= 'z' {
+			if word < 0 {
+				word = len(letters)
+			}
+			letters = append(letters, r)
+			pos = append(pos, i)
+			continue
+		}
+		closeWord()
+		if readings := hanziReadings(r, false); len(readings) > 0 {
+			start := len(letters)
+			for _, l := range readings[0] {
+				letters = append(letters, l)
+				pos = append(pos, i)
+			}
+			spans = append(spans, span{start, len(letters), false})
+		}
+	}
+	closeWord()
+
+	boundary = make([]bool, len(letters)+1)
+	for _, s := range spans {
+		boundary[s.start], boundary[s.end] = true, true
+		if s.latin {
+			for _, k := range syllableSplits(letters[s.start:s.end]) {
+				boundary[s.start+k] = true
+			}
+		}
+	}
+	return letters, pos, boundary
+}
+
+// syllableSplits returns the offsets inside word that lie between syllables in some split of the whole
+// word into pinyin syllables, or nil when the word is not pinyin.
+func syllableSplits(word []rune) []int {
+	pinyinSyllablesOnce.Do(loadPinyinSyllables)
+	n := len(word)
+	fwd, bwd := make([]bool, n+1), make([]bool, n+1)
+	fwd[0], bwd[n] = true, true
+	for i := 0; i < n; i++ {
+		if !fwd[i] {
+			continue
+		}
+		for l := 1; l <= pinyinSyllableMax && i+l <= n; l++ {
+			if pinyinSyllables[string(word[i:i+l])] {
+				fwd[i+l] = true
+			}
+		}
+	}
+	if !fwd[n] {
+		return nil
+	}
+	for i := n - 1; i >= 0; i-- {
+		for l := 1; l <= pinyinSyllableMax && i+l <= n; l++ {
+			if bwd[i+l] && pinyinSyllables[string(word[i:i+l])] {
+				bwd[i] = true
+				break
+			}
+		}
+	}
+	var splits []int
+	for k := 1; k < n; k++ {
+		if fwd[k] && bwd[k] {
+			splits = append(splits, k)
+		}
+	}
+	return splits
+}
+
+// loadPinyinSyllables collects every toneless syllable of the pinyin dictionary.
+func loadPinyinSyllables() {
+	pinyinSyllables = map[string]bool{}
+	for r := range pinyin.PinyinDict {
+		for _, p := range pinyin.SinglePinyin(rune(r), pinyinHeteronymArgs) {
+			if isPinyinLetters(p) {
+				pinyinSyllables[p] = true
+				if len(p) > pinyinSyllableMax {
+					pinyinSyllableMax = len(p)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package utils
+
+import "testing"
+
+// pinyinMatches runs the pinyin half of FilterText for a single rule.
+func pinyinMatches(rule, text string) []string {
+	var patterns [][]rune
+	for _, v := range PinyinVariants(rule) {
+		patterns = append(patterns, []rune(v))
+	}
+	if len(patterns) == 0 {
+		return nil
+	}
+	runes := []rune(text)
+	letters, pos, boundary := pinyinStream(runes)
+	var out []string
+	newAhoCorasick(patterns).find(letters, func(_, start, end int) {
+		if boundary[start] && boundary[end] {
+			out = append(out, string(runes[pos[start]:pos[end-1]+1]))
+		}
+	})
+	return out
+}
+
+func TestPinyinRules(t *testing.T) {
+	cases := []struct {
+		rule, text string
+		hit        bool
+	}{
+		{"fanqiang", "教你 fan qiang 的方法", true},
+		{"fanqiang", "F-A-N.Qiang", true},
+		{"fanqiang", "FanQiang", true},
+		{"fanqiang", "怎么翻墙", true},
+		{"翻墙", "帆强软件", true},
+		{"翻墙", "fanqiang", true},
+		{"sb", "你是SB吗", true},
+		{"sb", "s.b", true},
+		{"sb", "this is bad", false},
+		{"sb", "usb", false},
+		{"xian", "xi an", true},
+		{"an", "xian", true}, // xi'an splits as xi + an
+		{"an", "bank", false},
+	}
+	for _, c := range cases {
+		if got := len(pinyinMatches(c.rule, c.text)) > 0; got != c.hit {
+			t.Errorf("rule %q on %q: hit = %v, want %v", c.rule, c.text, got, c.hit)
+		}
+	}
+}
+
+func TestPinyinVariants(t *testing.T) {
+	if v := PinyinVariants("F-a n"); len(v) != 1 || v[0] != "fan" {
+		t.Errorf("latin pattern variants = %v", v)
+	}
+	if v := PinyinVariants("12 !"); v != nil {
+		t.Errorf("pattern without letters or Chinese gave %v", v)
+	}
+	// 行 reads xing or hang; both spellings must be matched
+	v := PinyinVariants("银行")
+	if !containsVariant(v, "yinhang") || !containsVariant(v, "yinxing") {
+		t.Errorf("polyphonic variants = %v", v)
+	}
+}
+
+func containsVariant(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
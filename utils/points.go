@@ -0,0 +1,156 @@
+package utils
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/cppla/aibbs/models"
+)
+
+// ErrInsufficientPoints is returned when a charge would take a balance below zero.
+var ErrInsufficientPoints = errors.New("insufficient points")
+
+// PointsChange is a movement of points between a user and the system account.
+type PointsChange struct {
+	UserID  uint
+	Amount  int // positive awards, negative charges
+	Reason  string
+	RefType string
+	RefID   uint
+	ActorID uint
+	Note    string
+	// AllowNegative lets a charge take the balance below zero, e.g. clawbacks and admin corrections
+	AllowNegative bool
+}
+
+// ApplyPoints applies c within tx, which must be a transaction: the user row is locked, users.points
+// updated and a balanced pair of ledger entries written. It returns the user's new balance.
+// All point changes go through here so the ledger and users.points never drift apart. Start tx with
+// Transaction so the leaderboards only see the change once it commits.
+func ApplyPoints(tx *gorm.DB, c PointsChange) (int, error) {
+	current, err := lockPoints(tx, c.UserID)
+	if err != nil {
+		return 0, err
+	}
+	if c.Amount == 0 {
+		return current, nil
+	}
+	balance := current + c.Amount
+	if c.Amount < 0 && balance < 0 && !c.AllowNegative {
+		return current, ErrInsufficientPoints
+	}
+	if err := tx.Model(&models.User{}).Where("id = ?", c.UserID).UpdateColumn("points", balance).Error; err != nil {
+		return 0, err
+	}
+	entry := models.PointsTransaction{
+		TxnID:          uuid.NewString(),
+		UserID:         c.UserID,
+		CounterpartyID: models.SystemAccountID,
+		Amount:         c.Amount,
+		BalanceAfter:   balance,
+		Reason:         c.Reason,
+		RefType:        c.RefType,
+		RefID:          c.RefID,
+		ActorID:        c.ActorID,
+		Note:           c.Note,
+	}
+	system := entry
+	system.UserID, system.CounterpartyID = models.SystemAccountID, c.UserID
+	system.Amount, system.BalanceAfter = -c.Amount, 0
+	if err := tx.Create(&[]models.PointsTransaction{entry, system}).Error; err != nil {
+		return 0, err
+	}
+	AfterCommit(tx, func() { LeaderboardPointsChanged(c.UserID, c.Amount, balance) })
+	return balance, nil
+}
+
+// ErrInvalidTransfer is returned for transfers to oneself or of a non-positive amount.
+var ErrInvalidTransfer = errors.New("invalid points transfer")
+
+// PointsTransfer is a movement of points from one user to another, e.g. paying an author.
+type PointsTransfer struct {
+	FromID  uint
+	ToID    uint
+	Amount  int // must be positive
+	Reason  string
+	RefType string
+	RefID   uint
+	Note    string
+}
+
+// TransferPoints applies t within tx, which must be a transaction. Both user rows are locked with
+// LockUsers; the payer may not go below zero.
+// The ledger pair is the two users' entries, each naming the other as counterparty. It returns the
+// payer's new balance and the transaction id. Like ApplyPoints, start tx with Transaction.
+func TransferPoints(tx *gorm.DB, t PointsTransfer) (int, string, error) {
+	if t.Amount <= 0 || t.FromID == t.ToID || t.FromID == models.SystemAccountID || t.ToID == models.SystemAccountID {
+		return 0, "", ErrInvalidTransfer
+	}
+	balances, err := LockUsers(tx, t.FromID, t.ToID)
+	if err != nil {
+		return 0, "", err
+	}
+	fromBalance, toBalance := balances[t.FromID]-t.Amount, balances[t.ToID]+t.Amount
+	if fromBalance < 0 {
+		return balances[t.FromID], "", ErrInsufficientPoints
+	}
+	if err := tx.Model(&models.User{}).Where("id = ?", t.FromID).UpdateColumn("points", fromBalance).Error; err != nil {
+		return 0, "", err
+	}
+	if err := tx.Model(&models.User{}).Where("id = ?", t.ToID).UpdateColumn("points", toBalance).Error; err != nil {
+		return 0, "", err
+	}
+	txnID := uuid.NewString()
+	debit := models.PointsTransaction{
+		TxnID:          txnID,
+		UserID:         t.FromID,
+		CounterpartyID: t.ToID,
+		Amount:         -t.Amount,
+		BalanceAfter:   fromBalance,
+		Reason:         t.Reason,
+		RefType:        t.RefType,
+		RefID:          t.RefID,
+		Note:           t.Note,
+	}
+	credit := debit
+	credit.UserID, credit.CounterpartyID = t.ToID, t.FromID
+	credit.Amount, credit.BalanceAfter = t.Amount, toBalance
+	if err := tx.Create(&[]models.PointsTransaction{debit, credit}).Error; err != nil {
+		return 0, "", err
+	}
+	AfterCommit(tx, func() {
+		LeaderboardPointsChanged(t.FromID, -t.Amount, fromBalance)
+		LeaderboardPointsChanged(t.ToID, t.Amount, toBalance)
+	})
+	return fromBalance, txnID, nil
+}
+
+// LockUsers locks the given users' rows for the rest of tx in ascending id order and returns their
+// balances. Every multi-user lock goes through here, so two transactions locking the same users always
+// queue instead of deadlocking. Locking rows tx already holds is a no-op.
+func LockUsers(tx *gorm.DB, ids ...uint) (map[uint]int, error) {
+	ids = UniqueUint(ids)
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	balances := make(map[uint]int, len(ids))
+	for _, id := range ids {
+		current, err := lockPoints(tx, id)
+		if err != nil {
+			return nil, err
+		}
+		balances[id] = current
+	}
+	return balances, nil
+}
+
+// lockPoints locks the user row for the rest of tx and returns the current balance.
+func lockPoints(tx *gorm.DB, userID uint) (int, error) {
+	var user models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "points").First(&user, userID).Error; err != nil {
+		return 0, err
+	}
+	return user.Points, nil
+}
@@ -0,0 +1,127 @@
+package utils
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/cppla/aibbs/config"
+	"github.com/cppla/aibbs/models"
+)
+
+// Forum events that points rules (config points.Rules) can be attached to. The event name is also the
+// ledger reason code of the resulting entries.
+const (
+	PointsEventPostCreated    = "post_created"
+	PointsEventReplyReceived  = "reply_received"
+	PointsEventContentRemoved = "content_removed"
+)
+
+// PointsReasonClawback reverses rule rewards earned by content that was later deleted.
+const PointsReasonClawback = "clawback"
+
+// clawbackEvents are the earning rules reversed when the content they were earned by is deleted.
+var clawbackEvents = []string{PointsEventPostCreated, PointsEventReplyReceived}
+
+// AwardPointsForEvent applies the configured rule for event to userID, limited by the rule's daily cap.
+// Deductions stop at a zero balance. refType/refID identify the content the points relate to,
+// which is what ClawbackPoints later matches on; an event is applied at most once per user and
+// reference, so content published again after review does not earn twice. Errors are logged, never
+// returned: point rules must not fail the action that triggered them.
+func AwardPointsForEvent(db *gorm.DB, event string, userID uint, refType string, refID uint) {
+	rule, ok := config.Get().PointsRules[event]
+	if !ok || rule.Points == 0 || userID == 0 {
+		return
+	}
+	err := Transaction(db, func(tx *gorm.DB) error {
+		amount := rule.Points
+		// Lock the user first so concurrent events cannot both pass the checks below
+		if _, err := lockPoints(tx, userID); err != nil {
+			return err
+		}
+		if refID != 0 {
+			var done int64
+			if err := tx.Model(&models.PointsTransaction{}).
+				Where("user_id = ? AND reason = ? AND ref_type = ? AND ref_id = ?", userID, event, refType, refID).
+				Count(&done).Error; err != nil || done > 0 {
+				return err
+			}
+		}
+		if rule.DailyCap > 0 {
+			var used int
+			// Caps reset at midnight in the site's signin.Timezone
+			now := time.Now().In(SiteLocation())
+			dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+			if err := tx.Model(&models.PointsTransaction{}).
+				Where("user_id = ? AND reason = ? AND created_at >= ?", userID, event, dayStart).
+				Select("COALESCE(SUM(ABS(amount)), 0)").Scan(&used).Error; err != nil {
+				return err
+			}
+			left := rule.DailyCap - used
+			if left <= 0 {
+				return nil
+			}
+			if amount > left {
+				amount = left
+			} else if amount < -left {
+				amount = -left
+			}
+		}
+		change := PointsChange{UserID: userID, Amount: amount, Reason: event, RefType: refType, RefID: refID}
+		balance, err := ApplyPoints(tx, change)
+		if errors.Is(err, ErrInsufficientPoints) {
+			if balance <= 0 {
+				return nil
+			}
+			change.Amount = -balance
+			_, err = ApplyPoints(tx, change)
+		}
+		return err
+	})
+	if err != nil && Sugar != nil {
+		Sugar.Warnf("points rule %s for user %d failed: %v", event, userID, err)
+	}
+}
+
+// ClawbackPoints reverses the rule rewards earned by a deleted post or comment. Each reference is
+// clawed back at most once; the balance may go negative.
+func ClawbackPoints(db *gorm.DB, refType string, refID uint) {
+	err := Transaction(db, func(tx *gorm.DB) error {
+		var done int64
+		if err := tx.Model(&models.PointsTransaction{}).
+			Where("reason = ? AND ref_type = ? AND ref_id = ? AND user_id <> ?", PointsReasonClawback, refType, refID, models.SystemAccountID).
+			Count(&done).Error; err != nil || done > 0 {
+			return err
+		}
+		var earned []struct {
+			UserID uint
+			Total  int
+		}
+		if err := tx.Model(&models.PointsTransaction{}).
+			Select("user_id, SUM(amount) AS total").
+			Where("reason IN ? AND ref_type = ? AND ref_id = ? AND user_id <> ?", clawbackEvents, refType, refID, models.SystemAccountID).
+			Group("user_id").Scan(&earned).Error; err != nil {
+			return err
+		}
+		for _, e := range earned {
+			if e.Total <= 0 {
+				continue
+			}
+			if _, err := ApplyPoints(tx, PointsChange{
+				UserID:        e.UserID,
+				Amount:        -e.Total,
+				Reason:        PointsReasonClawback,
+				RefType:       refType,
+				RefID:         refID,
+				AllowNegative: true,
+			}); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil && Sugar != nil {
+		Sugar.Warnf("points clawback for %s:%d failed: %v", refType, refID, err)
+	}
+}
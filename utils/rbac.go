@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/cppla/aibbs/models"
+)
+
+const permissionCacheTTL = 5 * time.Minute
+
+// PermissionGrant is one permission held by a user; an empty Category means site-wide.
+type PermissionGrant struct {
+	Role       string `json:"role"`
+	Permission string `json:"permission"`
+	Category   string `json:"category,omitempty"`
+}
+
+func permissionCacheKey(userID uint) string {
+	return "rbac:user:" + strconv.FormatUint(uint64(userID), 10)
+}
+
+// UserGrants returns the permissions a user holds through their roles, cached in Redis.
+func UserGrants(db *gorm.DB, userID uint) ([]PermissionGrant, error) {
+	if b, ok := CacheGetBytes(permissionCacheKey(userID)); ok {
+		var grants []PermissionGrant
+		if json.Unmarshal(b, &grants) == nil {
+			return grants, nil
+		}
+	}
+	// LEFT JOIN keeps roles without permission rows so they still appear in the user's role list
+	grants := []PermissionGrant{}
+	err := db.Table("user_roles").
+		Select("roles.name AS role, COALESCE(permissions.name, '') AS permission, user_roles.category AS category").
+		Joins("JOIN roles ON roles.id = user_roles.role_id").
+		Joins("LEFT JOIN role_permissions ON role_permissions.role_id = roles.id").
+		Joins("LEFT JOIN permissions ON permissions.id = role_permissions.permission_id").
+		Where("user_roles.user_id = ?", userID).
+		Scan(&grants).Error
+	if err != nil {
+		return nil, err
+	}
+	CacheSetJSON(permissionCacheKey(userID), grants, permissionCacheTTL)
+	return grants, nil
+}
+
+// HasPermission reports whether the user holds perm site-wide, or for the given category when non-empty.
+func HasPermission(db *gorm.DB, userID uint, perm, category string) bool {
+	if userID == 0 {
+		return false
+	}
+	grants, err := UserGrants(db, userID)
+	if err != nil {
+		if Sugar != nil {
+			Sugar.Warnf("load permissions failed user=%d err=%v", userID, err)
+		}
+		return false
+	}
+	for _, g := range grants {
+		// Only a site-wide admin assignment holds every permission
+		if g.Role == models.RoleAdmin && g.Category == "" {
+			return true
+		}
+		if g.Permission != perm {
+			continue
+		}
+		if g.Category == "" || (category != "" && g.Category == category) {
+			return true
+		}
+	}
+	return false
+}
+
+// InvalidateUserPermissions drops the cached grants after a role change.
+func InvalidateUserPermissions(userID uint) {
+	rc := GetRedis()
+	if rc == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_ = rc.Del(ctx, permissionCacheKey(userID)).Err()
+}
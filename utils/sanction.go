@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/cppla/aibbs/models"
+)
+
+const sanctionCacheTTL = time.Minute
+
+// SanctionStatus holds the sanctions currently in force for a user, keyed by type.
+type SanctionStatus map[string]models.UserSanction
+
+func sanctionCacheKey(userID uint) string {
+	return "sanction:user:" + strconv.FormatUint(uint64(userID), 10)
+}
+
+func sessionRevokeKey(userID uint) string {
+	return "jwt:revoke:user:" + strconv.FormatUint(uint64(userID), 10)
+}
+
+// ActiveSanctions returns the user's active sanctions, cached briefly in Redis.
+// When several sanctions of one type overlap, the longest-lasting one is reported.
+func ActiveSanctions(db *gorm.DB, userID uint) SanctionStatus {
+	if b, ok := CacheGetBytes(sanctionCacheKey(userID)); ok {
+		var status SanctionStatus
+		if json.Unmarshal(b, &status) == nil {
+			return status
+		}
+	}
+	now := time.Now()
+	var rows []models.UserSanction
+	if err := db.Where("user_id = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", userID, now).
+		Find(&rows).Error; err != nil {
+		if Sugar != nil {
+			Sugar.Warnf("load sanctions failed user=%d err=%v", userID, err)
+		}
+		return SanctionStatus{}
+	}
+	status := SanctionStatus{}
+	for _, s := range rows {
+		cur, ok := status[s.Type]
+		if !ok || (cur.ExpiresAt != nil && (s.ExpiresAt == nil || s.ExpiresAt.After(*cur.ExpiresAt))) {
+			status[s.Type] = s
+		}
+	}
+	// Never cache past the earliest expiry so lifted sanctions take effect on time
+	ttl := sanctionCacheTTL
+	for _, s := range status {
+		if s.ExpiresAt != nil {
+			if d := time.Until(*s.ExpiresAt); d < ttl {
+				ttl = d
+			}
+		}
+	}
+	if ttl > 0 {
+		CacheSetJSON(sanctionCacheKey(userID), status, ttl)
+	}
+	return status
+}
+
+// InvalidateSanctions drops the cached sanction status after a change.
+func InvalidateSanctions(userID uint) {
+	rc := GetRedis()
+	if rc == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_ = rc.Del(ctx, sanctionCacheKey(userID)).Err()
+}
+
+// RevokeUserSessions invalidates every token issued to the user up to now.
+func RevokeUserSessions(userID uint, ttl time.Duration) {
+	rc := GetRedis()
+	if rc == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_ = rc.Set(ctx, sessionRevokeKey(userID), time.Now().Unix(), ttl).Err()
+}
+
+// SessionsRevokedSince returns the cutoff set by RevokeUserSessions, or 0 when none applies.
+func SessionsRevokedSince(userID uint) int64 {
+	rc := GetRedis()
+	if rc == nil {
+		return 0
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	v, err := rc.Get(ctx, sessionRevokeKey(userID)).Int64()
+	if err != nil {
+		return 0 // fail-open, like IsTokenBlacklisted
+	}
+	return v
+}
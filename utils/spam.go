@@ -0,0 +1,260 @@
+package utils
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"gorm.io/gorm"
+
+	"github.com/cppla/aibbs/models"
+)
+
+// SpamInput describes a submission to score.
+type SpamInput struct {
+	Kind      string // "post" or "comment"
+	UserID    uint
+	Title     string
+	Content   string
+	IP        string
+	CreatedAt time.Time // account creation time
+}
+
+// SpamSignal is one classifier's verdict: Score is in [0,1], 0 meaning no evidence of spam.
+type SpamSignal struct {
+	Name   string  `json:"name"`
+	Score  float64 `json:"score"`
+	Detail string  `json:"detail,omitempty"`
+}
+
+// SpamClassifier scores a submission. Implementations must be safe for concurrent use
+// and should fail open (return a zero score) when their backing store is unavailable.
+type SpamClassifier interface {
+	Name() string
+	Score(db *gorm.DB, in *SpamInput) SpamSignal
+}
+
+var spamClassifiers struct {
+	sync.RWMutex
+	list []SpamClassifier
+}
+
+// RegisterSpamClassifier adds a classifier to the pipeline used by ScoreSpam.
+func RegisterSpamClassifier(c SpamClassifier) {
+	spamClassifiers.Lock()
+	spamClassifiers.list = append(spamClassifiers.list, c)
+	spamClassifiers.Unlock()
+}
+
+func init() {
+	RegisterSpamClassifier(linkDensityClassifier{})
+	RegisterSpamClassifier(duplicateContentClassifier{})
+	RegisterSpamClassifier(velocityClassifier{})
+	RegisterSpamClassifier(accountAgeClassifier{})
+	RegisterSpamClassifier(countryChangeClassifier{})
+	RegisterSpamClassifier(BayesClassifier{})
+}
+
+// ScoreSpam runs every registered classifier and combines their scores as independent
+// evidence: 1 - Π(1 - score). Signals with a zero score are omitted from the result.
+func ScoreSpam(db *gorm.DB, in *SpamInput) (float64, []SpamSignal) {
+	spamClassifiers.RLock()
+	list := append([]SpamClassifier(nil), spamClassifiers.list...)
+	spamClassifiers.RUnlock()
+
+	clean := 1.0
+	var signals []SpamSignal
+	for _, c := range list {
+		s := c.Score(db, in)
+		if s.Score <= 0 {
+			continue
+		}
+		if s.Score > 1 {
+			s.Score = 1
+		}
+		if s.Name == "" {
+			s.Name = c.Name()
+		}
+		clean *= 1 - s.Score
+		signals = append(signals, s)
+	}
+	return 1 - clean, signals
+}
+
+// FormatSpamSignals renders signals for report details and logs.
+func FormatSpamSignals(score float64, signals []SpamSignal) string {
+	parts := make([]string, 0, len(signals))
+	for _, s := range signals {
+		p := fmt.Sprintf("%s=%.2f", s.Name, s.Score)
+		if s.Detail != "" {
+			p += " (" + s.Detail + ")"
+		}
+		parts = append(parts, p)
+	}
+	return fmt.Sprintf("spam score %.2f: %s", score, strings.Join(parts, ", "))
+}
+
+var linkPattern = regexp.MustCompile(`(?i)(https?://|www\.)[^\s"'<>]+`)
+
+// linkDensityClassifier flags text that is mostly links.
+type linkDensityClassifier struct{}
+
+func (linkDensityClassifier) Name() string { return "links" }
+
+func (linkDensityClassifier) Score(_ *gorm.DB, in *SpamInput) SpamSignal {
+	text := in.Title + " " + in.Content
+	links := linkPattern.FindAllString(text, -1)
+	if len(links) == 0 {
+		return SpamSignal{}
+	}
+	linkChars := 0
+	for _, l := range links {
+		linkChars += len(l)
+	}
+	density := float64(linkChars) / float64(len(text))
+	score := 0.1*float64(len(links)) + 0.5*density
+	if score > 0.9 {
+		score = 0.9
+	}
+	return SpamSignal{Name: "links", Score: score, Detail: fmt.Sprintf("%d links, %.0f%% of text", len(links), density*100)}
+}
+
+// duplicateContentClassifier flags the same text being posted by several accounts within a day.
+type duplicateContentClassifier struct{}
+
+const duplicateWindow = 24 * time.Hour
+
+func (duplicateContentClassifier) Name() string { return "duplicate" }
+
+func (duplicateContentClassifier) Score(_ *gorm.DB, in *SpamInput) SpamSignal {
+	normalized := normalizeForHash(in.Title + in.Content)
+	rc := GetRedis()
+	if rc == nil || len([]rune(normalized)) < 20 {
+		return SpamSignal{}
+	}
+	sum := sha1.Sum([]byte(normalized))
+	key := "spam:hash:" + hex.EncodeToString(sum[:])
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	pipe := rc.TxPipeline()
+	pipe.SAdd(ctx, key, in.UserID)
+	pipe.Expire(ctx, key, duplicateWindow)
+	card := pipe.SCard(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return SpamSignal{}
+	}
+	others := card.Val() - 1
+	if others <= 0 {
+		return SpamSignal{}
+	}
+	score := 0.5 + 0.15*float64(others-1)
+	if score > 0.95 {
+		score = 0.95
+	}
+	return SpamSignal{Name: "duplicate", Score: score, Detail: fmt.Sprintf("same text from %d other accounts", others)}
+}
+
+// normalizeForHash keeps letters and digits only, lowercased, so trivial edits hash the same.
+func normalizeForHash(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// velocityClassifier flags bursts of submissions from one account.
+type velocityClassifier struct{}
+
+const velocityWindow = 10 * time.Minute
+
+func (velocityClassifier) Name() string { return "velocity" }
+
+func (velocityClassifier) Score(_ *gorm.DB, in *SpamInput) SpamSignal {
+	rc := GetRedis()
+	if rc == nil {
+		return SpamSignal{}
+	}
+	key := "spam:rate:" + strconv.FormatUint(uint64(in.UserID), 10)
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	n, err := rc.Incr(ctx, key).Result()
+	if err != nil {
+		return SpamSignal{}
+	}
+	if n == 1 {
+		_ = rc.Expire(ctx, key, velocityWindow).Err()
+	}
+	var score float64
+	switch {
+	case n > 20:
+		score = 0.8
+	case n > 10:
+		score = 0.5
+	case n > 5:
+		score = 0.2
+	default:
+		return SpamSignal{}
+	}
+	return SpamSignal{Name: "velocity", Score: score, Detail: fmt.Sprintf("%d submissions in %s", n, velocityWindow)}
+}
+
+// accountAgeClassifier adds weak evidence for very new accounts; on its own it never reaches review.
+type accountAgeClassifier struct{}
+
+func (accountAgeClassifier) Name() string { return "account_age" }
+
+func (accountAgeClassifier) Score(_ *gorm.DB, in *SpamInput) SpamSignal {
+	if in.CreatedAt.IsZero() {
+		return SpamSignal{}
+	}
+	age := time.Since(in.CreatedAt)
+	switch {
+	case age < time.Hour:
+		return SpamSignal{Name: "account_age", Score: 0.3, Detail: "under 1 hour"}
+	case age < 24*time.Hour:
+		return SpamSignal{Name: "account_age", Score: 0.15, Detail: "under 1 day"}
+	case age < 7*24*time.Hour:
+		return SpamSignal{Name: "account_age", Score: 0.05, Detail: "under 7 days"}
+	}
+	return SpamSignal{}
+}
+
+// countryChangeClassifier flags submissions from a country the account has never logged in from.
+type countryChangeClassifier struct{}
+
+func (countryChangeClassifier) Name() string { return "country_change" }
+
+func (countryChangeClassifier) Score(db *gorm.DB, in *SpamInput) SpamSignal {
+	if in.IP == "" || db == nil {
+		return SpamSignal{}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	country, err := GetIPCountry(ctx, in.IP)
+	if err != nil || country == "" {
+		return SpamSignal{}
+	}
+	var countries []string
+	// "success" is the outcome recorded for completed logins
+	if err := db.Model(&models.LoginEvent{}).
+		Where("user_id = ? AND outcome = ? AND country <> ''", in.UserID, "success").
+		Distinct().Pluck("country", &countries).Error; err != nil || len(countries) == 0 {
+		return SpamSignal{}
+	}
+	for _, c := range countries {
+		if c == country {
+			return SpamSignal{}
+		}
+	}
+	return SpamSignal{Name: "country_change", Score: 0.25, Detail: "new country " + country}
+}
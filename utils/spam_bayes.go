@@ -0,0 +1,200 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+
+	"github.com/cppla/aibbs/config"
+)
+
+// Redis layout of the Bayesian filter: token counts per class and the number of trained documents.
+const (
+	bayesSpamKey = "spam:bayes:spam"
+	bayesHamKey  = "spam:bayes:ham"
+	bayesDocsKey = "spam:bayes:docs"
+	// bayesLabelsKey maps each trained item ("post:12") to its class and the tokens it was trained with
+	bayesLabelsKey = "spam:bayes:labels"
+	// bayesMinDocs is how many documents of each class must be trained before the filter votes
+	bayesMinDocs = 20
+	// bayesTokens caps the number of most significant tokens combined per document
+	bayesTokens = 15
+)
+
+// BayesClassifier is a local naive Bayes filter kept in Redis and trained from moderator decisions.
+// It only scores when spam.BayesEnabled is set and enough documents have been trained.
+type BayesClassifier struct{}
+
+// Name implements SpamClassifier.
+func (BayesClassifier) Name() string { return "bayes" }
+
+// Score implements SpamClassifier using Graham-style combination of the most telling tokens.
+func (BayesClassifier) Score(_ *gorm.DB, in *SpamInput) SpamSignal {
+	rc := GetRedis()
+	if rc == nil || !config.Get().SpamBayesEnabled {
+		return SpamSignal{}
+	}
+	tokens := bayesTokenize(in.Title + " " + in.Content)
+	if len(tokens) == 0 {
+		return SpamSignal{}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	docs, err := rc.HMGet(ctx, bayesDocsKey, "spam", "ham").Result()
+	if err != nil {
+		return SpamSignal{}
+	}
+	nSpam, nHam := redisNumber(docs[0]), redisNumber(docs[1])
+	if nSpam < bayesMinDocs || nHam < bayesMinDocs {
+		return SpamSignal{}
+	}
+	spamCounts, err1 := rc.HMGet(ctx, bayesSpamKey, tokens...).Result()
+	hamCounts, err2 := rc.HMGet(ctx, bayesHamKey, tokens...).Result()
+	if err1 != nil || err2 != nil {
+		return SpamSignal{}
+	}
+
+	probs := make([]float64, 0, len(tokens))
+	for i := range tokens {
+		s, h := redisNumber(spamCounts[i]), redisNumber(hamCounts[i])
+		if s+h < 2 {
+			continue
+		}
+		ps := s / nSpam
+		ph := h / nHam
+		p := ps / (ps + ph)
+		probs = append(probs, math.Min(0.99, math.Max(0.01, p)))
+	}
+	if len(probs) == 0 {
+		return SpamSignal{}
+	}
+	// keep the tokens furthest from neutral
+	sort.Slice(probs, func(i, j int) bool { return math.Abs(probs[i]-0.5) > math.Abs(probs[j]-0.5) })
+	if len(probs) > bayesTokens {
+		probs = probs[:bayesTokens]
+	}
+	logSpam, logHam := 0.0, 0.0
+	for _, p := range probs {
+		logSpam += math.Log(p)
+		logHam += math.Log(1 - p)
+	}
+	score := 1 / (1 + math.Exp(logHam-logSpam))
+	if score < 0.5 {
+		return SpamSignal{}
+	}
+	// Rescale so only the part above neutral counts as evidence
+	return SpamSignal{Name: "bayes", Score: (score - 0.5) * 2, Detail: fmt.Sprintf("%d tokens", len(probs))}
+}
+
+// bayesTrainScript applies an item's label: unchanged labels are skipped, and a changed label first
+// removes the tokens the item was trained with from the old class.
+// KEYS: labels, spam counts, ham counts, docs. ARGV: item, class, tokens...
+var bayesTrainScript = redis.NewScript(`
+local prev = redis.call('HGET', KEYS[1], ARGV[1])
+if prev then
+	local old = {}
+	for w in string.gmatch(prev, '%S+') do old[#old + 1] = w end
+	if old[1] == ARGV[2] then
+		return 0
+	end
+	local oldKey = KEYS[3]
+	if old[1] == 'spam' then oldKey = KEYS[2] end
+	for i = 2, #old do redis.call('HINCRBY', oldKey, old[i], -1) end
+	redis.call('HINCRBY', KEYS[4], old[1], -1)
+end
+local key = KEYS[3]
+if ARGV[2] == 'spam' then key = KEYS[2] end
+local entry = {ARGV[2]}
+for i = 3, #ARGV do
+	redis.call('HINCRBY', key, ARGV[i], 1)
+	entry[#entry + 1] = ARGV[i]
+end
+redis.call('HINCRBY', KEYS[4], ARGV[2], 1)
+redis.call('HSET', KEYS[1], ARGV[1], table.concat(entry, ' '))
+return 1
+`)
+
+// TrainSpam records the text of item (e.g. "post:12") as spam (isSpam) or ham in the Bayesian filter.
+// Each item counts once: training it again with the same label does nothing, and a new label moves
+// the item from one class to the other.
+func TrainSpam(item, title, content string, isSpam bool) {
+	rc := GetRedis()
+	if rc == nil || !config.Get().SpamBayesEnabled {
+		return
+	}
+	tokens := bayesTokenize(title + " " + content)
+	if len(tokens) == 0 {
+		return
+	}
+	class := "ham"
+	if isSpam {
+		class = "spam"
+	}
+	args := make([]interface{}, 0, len(tokens)+2)
+	args = append(args, item, class)
+	for _, t := range tokens {
+		args = append(args, t)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	keys := []string{bayesLabelsKey, bayesSpamKey, bayesHamKey, bayesDocsKey}
+	if err := bayesTrainScript.Run(ctx, rc, keys, args...).Err(); err != nil && Sugar != nil {
+		Sugar.Warnf("train bayes filter failed: %v", err)
+	}
+}
+
+// bayesTokenize returns the distinct tokens of text: lowercased latin words and digit runs,
+// plus overlapping bigrams for CJK text which has no word separators.
+func bayesTokenize(text string) []string {
+	seen := map[string]bool{}
+	var out []string
+	add := func(t string) {
+		if t != "" && !seen[t] && len(t) <= 64 {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	var word []rune
+	var prevHan rune
+	flush := func() {
+		if len(word) >= 2 {
+			add(string(word))
+		}
+		word = word[:0]
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flush()
+			if prevHan != 0 {
+				add(string([]rune{prevHan, r}))
+			}
+			prevHan = r
+			continue
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word = append(word, r)
+		default:
+			flush()
+		}
+		prevHan = 0
+	}
+	flush()
+	return out
+}
+
+func redisNumber(v interface{}) float64 {
+	s, ok := v.(string)
+	if !ok {
+		return 0
+	}
+	var f float64
+	_, _ = fmt.Sscan(s, &f)
+	return f
+}
@@ -7,6 +7,7 @@ import (
 )
 
 type stateEntry struct {
+	value     string
 	expiresAt time.Time
 }
 
@@ -17,38 +18,54 @@ var (
 
 // SaveState stores an OAuth state token with TTL to mitigate CSRF.
 func SaveState(state string, ttl time.Duration) {
+	SaveStateValue(state, "1", ttl)
+}
+
+// ConsumeState validates and removes a state token.
+func ConsumeState(state string) bool {
+	_, ok := ConsumeStateValue(state)
+	return ok
+}
+
+// SaveStateValue stores a state token carrying a small payload (e.g. the account being linked).
+func SaveStateValue(state, value string, ttl time.Duration) {
 	if ttl <= 0 {
 		ttl = 10 * time.Minute
 	}
+	if value == "" {
+		value = "1"
+	}
 	// Prefer Redis for distributed consistency
 	if rc := GetRedis(); rc != nil {
 		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 		defer cancel()
-		_ = rc.Set(ctx, "oauth:state:"+state, "1", ttl).Err()
+		_ = rc.Set(ctx, "oauth:state:"+state, value, ttl).Err()
 		return
 	}
 	// Fallback to in-memory (single-instance only)
 	stateStoreMu.Lock()
-	stateStore[state] = stateEntry{expiresAt: time.Now().Add(ttl)}
+	stateStore[state] = stateEntry{value: value, expiresAt: time.Now().Add(ttl)}
 	stateStoreMu.Unlock()
 }
 
-// ConsumeState validates and removes a state token.
-func ConsumeState(state string) bool {
+// ConsumeStateValue validates and removes a state token, returning its payload.
+func ConsumeStateValue(state string) (string, bool) {
 	// Prefer Redis: GETDEL to ensure single-use
 	if rc := GetRedis(); rc != nil {
 		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 		defer cancel()
 		key := "oauth:state:" + state
 		if v, err := rc.GetDel(ctx, key).Result(); err == nil {
-			return v != ""
+			return v, v != ""
 		}
 		// Fallback to Lua to attempt atomic get+del when GETDEL not available
 		script := `local v=redis.call('GET', KEYS[1]); if v then redis.call('DEL', KEYS[1]); end; return v`
 		if res, err := rc.Eval(ctx, script, []string{key}).Result(); err == nil {
-			return res != nil
+			if s, ok := res.(string); ok {
+				return s, s != ""
+			}
 		}
-		return false
+		return "", false
 	}
 	// Fallback to in-memory
 	stateStoreMu.Lock()
@@ -57,8 +74,8 @@ func ConsumeState(state string) bool {
 		delete(stateStore, state)
 	}
 	stateStoreMu.Unlock()
-	if !ok {
-		return false
+	if !ok || !time.Now().Before(entry.expiresAt) {
+		return "", false
 	}
-	return time.Now().Before(entry.expiresAt)
+	return entry.value, true
 }
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cppla/aibbs/config"
+)
+
+var locations sync.Map // name -> *time.Location
+
+// LoadLocation is time.LoadLocation with a cache; it returns nil for unknown zone names.
+func LoadLocation(name string) *time.Location {
+	if name == "" {
+		return nil
+	}
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil
+	}
+	locations.Store(name, loc)
+	return loc
+}
+
+// SiteLocation is the zone in which the site's days start (signin.Timezone), or the server's zone.
+func SiteLocation() *time.Location {
+	if loc := LoadLocation(config.Get().SigninTimezone); loc != nil {
+		return loc
+	}
+	return time.Local
+}
+
+// UserLocation is the user's profile timezone when set and valid, otherwise SiteLocation.
+func UserLocation(tz string) *time.Location {
+	if loc := LoadLocation(tz); loc != nil {
+		return loc
+	}
+	return SiteLocation()
+}
+
+// CalendarDate returns the calendar day of t as seen in loc, expressed as midnight in time.Local.
+// That is the form DATE columns are written and read in, since the DSN uses loc=Local.
+func CalendarDate(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
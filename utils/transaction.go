@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type afterCommitKey struct{}
+
+// Transaction runs fc in a database transaction like db.Transaction, then runs the functions queued
+// with AfterCommit once it has committed. Side effects outside MySQL, such as leaderboard updates,
+// are queued that way so a rolled back transaction leaves no trace. Nested calls join the outer
+// transaction's queue.
+func Transaction(db *gorm.DB, fc func(tx *gorm.DB) error) error {
+	if outer, nested := db.Statement.Context.Value(afterCommitKey{}).(*[]func()); nested {
+		// A nested transaction that rolls back to its savepoint drops what it queued
+		mark := len(*outer)
+		if err := db.Transaction(fc); err != nil {
+			*outer = (*outer)[:mark]
+			return err
+		}
+		return nil
+	}
+	var queued []func()
+	ctx := context.WithValue(db.Statement.Context, afterCommitKey{}, &queued)
+	if err := db.WithContext(ctx).Transaction(fc); err != nil {
+		return err
+	}
+	for _, fn := range queued {
+		fn()
+	}
+	return nil
+}
+
+// AfterCommit queues fn to run after the Transaction that tx belongs to commits; it is dropped if the
+// transaction rolls back. Outside Transaction fn runs immediately.
+func AfterCommit(tx *gorm.DB, fn func()) {
+	if queued, ok := tx.Statement.Context.Value(afterCommitKey{}).(*[]func()); ok {
+		*queued = append(*queued, fn)
+		return
+	}
+	fn()
+}